@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Longueur maximale d'un message, en caractères
+	maxChatLength = 200
+	// Nombre de messages autorisés par utilisateur sur la fenêtre glissante
+	chatRateLimit  = 5
+	chatRateWindow = 10 * time.Second
+)
+
+// Réactions rapides disponibles, indexées par le code envoyé par le client
+var chatEmotes = map[string]string{
+	"gg":    "👏",
+	"wow":   "😮",
+	"lol":   "😂",
+	"oops":  "😅",
+	"think": "🤔",
+	"fire":  "🔥",
+}
+
+// Liste utilisée si CHAT_BLOCKLIST ne désigne aucun fichier
+var defaultChatBlocklist = []string{
+	"connard", "connasse", "conne", "con", "encule", "enculé", "merde", "pute", "salope", "batard", "bâtard", "fdp", "ntm",
+}
+
+// Mots filtrés dans le chat, en minuscules
+var chatBlocklist = make(map[string]bool)
+
+// loadChatBlocklist charge la liste des mots interdits depuis le fichier
+// indiqué (un mot par ligne, les lignes commençant par # sont ignorées)
+func loadChatBlocklist(path string) {
+	words := defaultChatBlocklist
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Printf("Impossible de lire la liste de mots du chat %s: %v", path, err)
+		} else {
+			defer file.Close()
+			words = nil
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if line != "" && !strings.HasPrefix(line, "#") {
+					words = append(words, line)
+				}
+			}
+		}
+	}
+
+	for _, word := range words {
+		chatBlocklist[strings.ToLower(word)] = true
+	}
+}
+
+// filterChatMessage masque les mots interdits par des astérisques
+func filterChatMessage(message string) (string, bool) {
+	filtered := false
+	var result strings.Builder
+	var word []rune
+
+	flush := func() {
+		if len(word) > 0 && chatBlocklist[strings.ToLower(string(word))] {
+			result.WriteString(strings.Repeat("*", len(word)))
+			filtered = true
+		} else {
+			result.WriteString(string(word))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range message {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		result.WriteRune(r)
+	}
+	flush()
+
+	return result.String(), filtered
+}
+
+// ChatLimiter applique la limite de débit du chat par utilisateur : elle le
+// suit d'une connexion et d'une partie à l'autre, revanches comprises. Les
+// invités sont limités par connexion. Partagé entre les parties, d'où le verrou.
+type ChatLimiter struct {
+	mu        sync.Mutex
+	sent      map[string][]time.Time // Envois récents, indexés par chatLimitKey
+	lastPrune time.Time
+}
+
+func NewChatLimiter() *ChatLimiter {
+	return &ChatLimiter{sent: make(map[string][]time.Time)}
+}
+
+// chatLimitKey identifie l'auteur d'un message pour la limite de débit
+func chatLimitKey(p *Player) string {
+	if p.UserID != 0 {
+		return "user:" + strconv.Itoa(p.UserID)
+	}
+	return "player:" + p.ID
+}
+
+// Allow enregistre un envoi de player à l'instant now, ou renvoie false s'il
+// a déjà atteint la limite sur la fenêtre glissante
+func (l *ChatLimiter) Allow(player *Player, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	key := chatLimitKey(player)
+	recent := recentChat(l.sent[key], now)
+	if len(recent) >= chatRateLimit {
+		l.sent[key] = recent
+		return false
+	}
+	l.sent[key] = append(recent, now)
+	return true
+}
+
+// prune oublie les auteurs sans envoi récent. Appelée avec l.mu verrouillé.
+func (l *ChatLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < chatRateWindow {
+		return
+	}
+	l.lastPrune = now
+	for key, sent := range l.sent {
+		if recent := recentChat(sent, now); len(recent) > 0 {
+			l.sent[key] = recent
+		} else {
+			delete(l.sent, key)
+		}
+	}
+}
+
+// recentChat ne garde que les envois de la fenêtre glissante
+func recentChat(sent []time.Time, now time.Time) []time.Time {
+	recent := sent[:0]
+	for _, at := range sent {
+		if now.Sub(at) < chatRateWindow {
+			recent = append(recent, at)
+		}
+	}
+	return recent
+}
+
+// validateChat vérifie un message ou une réaction et renvoie le texte à
+// diffuser, ou un message d'erreur pour le joueur
+func validateChat(message, emote string) (string, string) {
+	if emote != "" {
+		if _, ok := chatEmotes[emote]; !ok {
+			return "", "Réaction inconnue."
+		}
+		return "", ""
+	}
+
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return "", "Le message est vide."
+	}
+	if utf8.RuneCountInString(message) > maxChatLength {
+		return "", "Le message est trop long."
+	}
+	return message, ""
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatLimiterPerUser(t *testing.T) {
+	limiter := NewChatLimiter()
+	t0 := time.Now()
+	alice := &Player{ID: "connexion-1", UserID: 1}
+
+	for i := 0; i < chatRateLimit; i++ {
+		if !limiter.Allow(alice, t0.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("message %d refusé", i+1)
+		}
+	}
+	if limiter.Allow(alice, t0.Add(5*time.Second)) {
+		t.Error("6e message en 10 secondes accepté")
+	}
+
+	// Une nouvelle connexion du même compte (revanche, reconnexion) partage la limite
+	reconnected := &Player{ID: "connexion-2", UserID: 1}
+	if limiter.Allow(reconnected, t0.Add(6*time.Second)) {
+		t.Error("nouvelle connexion du même compte: message accepté, limite partagée attendue")
+	}
+
+	// Les autres utilisateurs et les invités ont chacun leur limite
+	if !limiter.Allow(&Player{ID: "connexion-3", UserID: 2}, t0.Add(6*time.Second)) {
+		t.Error("message d'un autre utilisateur refusé")
+	}
+	if !limiter.Allow(&Player{ID: "invite-1"}, t0.Add(6*time.Second)) {
+		t.Error("message d'un invité refusé")
+	}
+
+	// Fenêtre glissante : le premier envoi sort de la fenêtre au bout de 10 secondes
+	if !limiter.Allow(alice, t0.Add(chatRateWindow)) {
+		t.Error("message après la fenêtre refusé")
+	}
+	if limiter.Allow(alice, t0.Add(chatRateWindow)) {
+		t.Error("un seul envoi sorti de la fenêtre, le suivant doit être refusé")
+	}
+}
+
+func TestChatLimiterForgetsIdleUsers(t *testing.T) {
+	limiter := NewChatLimiter()
+	t0 := time.Now()
+	for id := 1; id <= 3; id++ {
+		limiter.Allow(&Player{UserID: id}, t0)
+	}
+
+	limiter.Allow(&Player{UserID: 4}, t0.Add(chatRateWindow))
+	if len(limiter.sent) != 1 {
+		t.Errorf("%d auteurs suivis après expiration, 1 attendu", len(limiter.sent))
+	}
+}
+
+func TestValidateChat(t *testing.T) {
+	tests := []struct {
+		name, message, emote string
+		text                 string
+		valid                bool
+	}{
+		{"message", "  bien joué  ", "", "bien joué", true},
+		{"message vide", "   ", "", "", false},
+		{"longueur maximale", strings.Repeat("é", maxChatLength), "", strings.Repeat("é", maxChatLength), true},
+		{"trop long", strings.Repeat("a", maxChatLength+1), "", "", false},
+		{"réaction", "", "gg", "", true},
+		{"réaction inconnue", "", "inconnue", "", false},
+		{"réaction prioritaire sur le texte", "ignoré", "fire", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, errMsg := validateChat(test.message, test.emote)
+			if (errMsg == "") != test.valid || (test.valid && text != test.text) {
+				t.Errorf("validateChat(%q, %q) = %q, %q", test.message, test.emote, text, errMsg)
+			}
+		})
+	}
+}
+
+func TestFilterChatMessage(t *testing.T) {
+	loadChatBlocklist("")
+
+	tests := []struct {
+		message, expected string
+		filtered          bool
+	}{
+		{"bonne partie", "bonne partie", false},
+		{"quel con !", "quel *** !", true},
+		{"MERDE alors", "***** alors", true},
+		{"espèce d'enculé", "espèce d'******", true},
+		{"le conte de fées", "le conte de fées", false},
+		{"con,con", "***,***", true},
+	}
+	for _, test := range tests {
+		result, filtered := filterChatMessage(test.message)
+		if result != test.expected || filtered != test.filtered {
+			t.Errorf("filterChatMessage(%q) = %q, %v ; %q, %v attendus", test.message, result, filtered, test.expected, test.filtered)
+		}
+	}
+}
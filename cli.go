@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"motzarella/database"
+	"motzarella/oidc"
+	"motzarella/validation"
+)
+
+// runCommand exécute une sous-commande d'administration
+func runCommand(args []string) {
+	switch args[0] {
+	case "reset-admin":
+		resetAdminCommand(args[1:])
+	case "mock-oidc":
+		mockOIDCCommand(args[1:])
+	case "migrate":
+		migrateCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Commande inconnue : %s\n\nCommandes disponibles :\n  reset-admin  réinitialise les identifiants d'un administrateur\n  mock-oidc    lance un fournisseur d'identité OpenID Connect de test\n  migrate      applique, annule ou liste les migrations du schéma\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// resetAdminCommand réinitialise le mot de passe d'un administrateur (ou le
+// crée) et révoque ses sessions. Sans -password, un mot de passe aléatoire
+// est généré et affiché.
+func resetAdminCommand(args []string) {
+	flags := flag.NewFlagSet("reset-admin", flag.ExitOnError)
+	username := flags.String("username", "admin", "nom de l'administrateur")
+	password := flags.String("password", "", "nouveau mot de passe (généré si vide)")
+	flags.Parse(args)
+
+	store := database.InitDB()
+	defer store.Close()
+
+	if *password != "" {
+		if message := validation.Password(*password, *username, ""); message != "" {
+			log.Fatal(message)
+		}
+	} else {
+		generated, err := database.GeneratePassword()
+		if err != nil {
+			log.Fatal(err)
+		}
+		*password = generated
+	}
+
+	if err := database.ResetAdmin(store, *username, *password); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Identifiants de %s réinitialisés. Mot de passe : %s\n", *username, *password)
+	fmt.Println("Le mot de passe devra être changé à la prochaine connexion.")
+}
+
+// mockOIDCCommand lance un fournisseur d'identité de test, sans mot de passe,
+// pour essayer la connexion OpenID Connect en local
+func mockOIDCCommand(args []string) {
+	flags := flag.NewFlagSet("mock-oidc", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:9000", "adresse d'écoute")
+	clientID := flags.String("client-id", "motzarella", "identifiant du client attendu")
+	flags.Parse(args)
+
+	issuer, err := oidc.NewMockIssuer("http://"+*addr, *clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Fournisseur d'identité de test sur http://%s (client %s)\n", *addr, *clientID)
+	fmt.Printf("Configuration : OIDC_PROVIDERS=mock OIDC_MOCK_ISSUER=http://%s OIDC_MOCK_CLIENT_ID=%s\n", *addr, *clientID)
+	log.Fatal(http.ListenAndServe(*addr, issuer))
+}
+
+// migrateCommand gère les migrations du schéma : "migrate up" (par défaut)
+// applique les migrations en attente, "migrate down [-steps N]" annule les
+// dernières et "migrate status" les liste
+func migrateCommand(args []string) {
+	action := "up"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	flags := flag.NewFlagSet("migrate "+action, flag.ExitOnError)
+	steps := flags.Int("steps", 1, "nombre de migrations à annuler (down)")
+	flags.Parse(args)
+
+	store := database.Open()
+	defer store.Close()
+
+	switch action {
+	case "up":
+		applied, err := store.Migrate()
+		for _, migration := range applied {
+			fmt.Printf("Appliquée : %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Aucune migration en attente.")
+		}
+
+	case "down":
+		if *steps <= 0 {
+			log.Fatal("-steps doit être positif")
+		}
+		rolledBack, err := store.Rollback(*steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("Annulée : %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("Aucune migration à annuler.")
+		}
+
+	case "status":
+		states, err := store.MigrationStatus()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, state := range states {
+			status := "en attente"
+			if state.AppliedAt != nil {
+				status = "appliquée le " + state.AppliedAt.Local().Format("02/01/2006 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", state.Version, state.Name, status)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Action inconnue : %s (up, down ou status)\n", action)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrUsernameTaken = errors.New("username already taken")
+	ErrEmailTaken    = errors.New("email already in use")
+)
+
+// UpdateUsername renomme un utilisateur, y compris dans l'historique de ses
+// parties. Renvoie ErrUsernameTaken si le nom est déjà utilisé.
+func (s *SQLStore) UpdateUsername(id int, username string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	if err := tx.QueryRow("SELECT username FROM users WHERE id = ?", id).Scan(&current); err != nil {
+		return err
+	}
+
+	var existing int
+	err = tx.QueryRow("SELECT id FROM users WHERE username = ? AND id != ?", username, id).Scan(&existing)
+	if err == nil {
+		return ErrUsernameTaken
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET username = ? WHERE id = ?", username, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE match_players SET username = ? WHERE username = ?", username, current); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateEmail change l'adresse d'un utilisateur, qui devra être vérifiée à
+// nouveau. Renvoie ErrEmailTaken si l'adresse est déjà utilisée.
+func (s *SQLStore) UpdateEmail(id int, email string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRow("SELECT id FROM users WHERE email = ? AND id != ?", email, id).Scan(&existing)
+	if err == nil {
+		return ErrEmailTaken
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET email = ?, email_verified = FALSE WHERE id = ?", email, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateAvatar enregistre le fichier d'avatar d'un utilisateur (vide pour le retirer)
+func (s *SQLStore) UpdateAvatar(id int, avatar string) error {
+	_, err := s.db.Exec("UPDATE users SET avatar = ? WHERE id = ?", nullString(avatar), id)
+	return err
+}
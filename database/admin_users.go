@@ -0,0 +1,355 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// AdminUser est un utilisateur tel que présenté aux administrateurs
+type AdminUser struct {
+	User
+	Suspension *Suspension `json:"suspension,omitempty"` // Suspension en cours
+}
+
+type Suspension struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Reason    string     `json:"reason"`
+	CreatedBy *int       `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil pour un bannissement définitif
+	LiftedAt  *time.Time `json:"lifted_at,omitempty"`
+}
+
+type UserStats struct {
+	MatchesPlayed  int        `json:"matches_played"`
+	MatchesWon     int        `json:"matches_won"`
+	Guesses        int        `json:"guesses"`
+	ChatMessages   int        `json:"chat_messages"`
+	ActiveSessions int        `json:"active_sessions"`
+	LastLoginAt    *time.Time `json:"last_login_at,omitempty"`
+}
+
+// UserFilter décrit une page de la liste des utilisateurs
+type UserFilter struct {
+	Search string // Recherche dans le pseudo et l'email
+	Status string // "active", "suspended", "deleted", "admins", "all" ; par défaut les comptes non supprimés
+	Sort   string // "id", "username", "email" ou "created_at"
+	Desc   bool
+	Limit  int
+	Offset int
+}
+
+// Colonnes de tri autorisées, pour ne jamais insérer la saisie dans la requête
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"username":   "LOWER(username)",
+	"email":      "LOWER(email)",
+	"created_at": "created_at",
+}
+
+// Condition vraie pour un utilisateur (alias users) suspendu à l'instant ?
+const suspendedCondition = `EXISTS (SELECT 1 FROM user_suspensions s WHERE s.user_id = users.id
+	AND s.lifted_at IS NULL AND (s.expires_at IS NULL OR s.expires_at > ?))`
+
+// ListUsers renvoie une page d'utilisateurs avec leurs rôles et leur
+// suspension en cours, ainsi que le nombre total de résultats
+func (s *SQLStore) ListUsers(filter UserFilter) ([]AdminUser, int, error) {
+	var conditions []string
+	var args []interface{}
+	now := time.Now()
+
+	switch filter.Status {
+	case "active":
+		conditions = append(conditions, "deleted_at IS NULL", "NOT "+suspendedCondition)
+		args = append(args, now)
+	case "suspended":
+		conditions = append(conditions, "deleted_at IS NULL", suspendedCondition)
+		args = append(args, now)
+	case "deleted":
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	case "admins":
+		conditions = append(conditions, "deleted_at IS NULL", "is_admin = TRUE")
+	case "all":
+	default:
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		// LIKE ne distingue pas la casse sous SQLite mais la distingue sous PostgreSQL
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		conditions = append(conditions, `(LOWER(username) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order, ok := userSortColumns[filter.Sort]
+	if !ok {
+		order = userSortColumns["id"]
+	}
+	if filter.Desc {
+		order += " DESC"
+	}
+
+	rows, err := s.db.Query("SELECT "+userColumns+" FROM users"+where+" ORDER BY "+order+", id LIMIT ? OFFSET ?",
+		append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []AdminUser{}
+	for rows.Next() {
+		var user AdminUser
+		if err := rows.Scan(user.scanFields()...); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	for i := range users {
+		if err := s.loadAdminDetails(&users[i]); err != nil {
+			return nil, 0, err
+		}
+	}
+	return users, total, nil
+}
+
+// GetAdminUser renvoie un utilisateur, même supprimé, avec ses rôles et sa
+// suspension en cours, ou nil s'il n'existe pas
+func (s *SQLStore) GetAdminUser(id int) (*AdminUser, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	adminUser := &AdminUser{User: *user}
+	if err := s.loadAdminDetails(adminUser); err != nil {
+		return nil, err
+	}
+	return adminUser, nil
+}
+
+func (s *SQLStore) loadAdminDetails(user *AdminUser) error {
+	var err error
+	user.Roles, err = s.GetUserRoles(user.ID)
+	if err != nil {
+		return err
+	}
+	user.Suspension, err = s.GetActiveSuspension(user.ID)
+	return err
+}
+
+// GetUserStats renvoie l'activité d'un utilisateur
+func (s *SQLStore) GetUserStats(user *User) (*UserStats, error) {
+	stats := &UserStats{}
+	err := s.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(CASE WHEN m.winner_id = p.player_id THEN 1 ELSE 0 END), 0)
+		FROM match_players p JOIN matches m ON m.id = p.match_id WHERE p.username = ?`, user.Username).
+		Scan(&stats.MatchesPlayed, &stats.MatchesWon)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM match_guesses g
+		JOIN match_players p ON p.match_id = g.match_id AND p.player_id = g.player_id WHERE p.username = ?`, user.Username).
+		Scan(&stats.Guesses)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM match_chat c
+		JOIN match_players p ON p.match_id = c.match_id AND p.player_id = c.player_id WHERE p.username = ?`, user.Username).
+		Scan(&stats.ChatMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.db.QueryRow("SELECT COUNT(*) FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, now).
+		Scan(&stats.ActiveSessions)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastLogin sql.NullTime
+	err = s.db.QueryRow("SELECT created_at FROM sessions WHERE user_id = ? ORDER BY created_at DESC LIMIT 1", user.ID).Scan(&lastLogin)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if lastLogin.Valid {
+		stats.LastLoginAt = &lastLogin.Time
+	}
+	return stats, nil
+}
+
+// GetActiveSuspension renvoie la suspension en cours d'un utilisateur, ou nil
+func (s *SQLStore) GetActiveSuspension(userID int) (*Suspension, error) {
+	suspension := &Suspension{UserID: userID}
+	var createdBy sql.NullInt64
+	var expiresAt sql.NullTime
+	err := s.db.QueryRow(`SELECT id, reason, created_by, created_at, expires_at FROM user_suspensions
+		WHERE user_id = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY expires_at IS NULL DESC, expires_at DESC LIMIT 1`, userID, time.Now()).
+		Scan(&suspension.ID, &suspension.Reason, &createdBy, &suspension.CreatedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		suspension.CreatedBy = &id
+	}
+	if expiresAt.Valid {
+		suspension.ExpiresAt = &expiresAt.Time
+	}
+	return suspension, nil
+}
+
+// SuspendUser suspend un utilisateur jusqu'à expiresAt, ou définitivement si
+// expiresAt est nil, et révoque ses sessions. La suspension remplace celle en
+// cours. Suspendre le dernier administrateur actif est refusé.
+func (s *SQLStore) SuspendUser(userID int, reason string, expiresAt *time.Time, createdBy int) (*Suspension, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkNotLastAdmin(tx, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`UPDATE user_suspensions SET lifted_at = ?, lifted_by = ?
+		WHERE user_id = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`, now, createdBy, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	suspension := &Suspension{
+		UserID:    userID,
+		Reason:    reason,
+		CreatedBy: &createdBy,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	err = tx.QueryRow("INSERT INTO user_suspensions (user_id, reason, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		userID, reason, createdBy, now, expiresAt).Scan(&suspension.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userID)
+	if err != nil {
+		return nil, err
+	}
+	return suspension, tx.Commit()
+}
+
+// LiftSuspension lève la suspension en cours d'un utilisateur. Renvoie
+// sql.ErrNoRows s'il n'est pas suspendu.
+func (s *SQLStore) LiftSuspension(userID, liftedBy int) error {
+	now := time.Now()
+	result, err := s.db.Exec(`UPDATE user_suspensions SET lifted_at = ?, lifted_by = ?
+		WHERE user_id = ? AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`, now, liftedBy, userID, now)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SoftDeleteUser marque un utilisateur comme supprimé sans effacer ses
+// données et révoque ses sessions. Son pseudo et son email restent réservés.
+// Supprimer le dernier administrateur actif est refusé.
+func (s *SQLStore) SoftDeleteUser(userID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkNotLastAdmin(tx, userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result, err := tx.Exec("UPDATE users SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", now, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = tx.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RestoreUser annule la suppression d'un utilisateur
+func (s *SQLStore) RestoreUser(userID int) error {
+	result, err := s.db.Exec("UPDATE users SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL", userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// checkNotLastAdmin renvoie ErrLastAdmin si l'utilisateur est administrateur
+// et qu'aucun autre administrateur actif (ni supprimé, ni suspendu) ne
+// resterait, et sql.ErrNoRows s'il n'existe pas. Le verrou est conservé
+// jusqu'à la fin de la transaction, qui doit retirer les droits.
+func checkNotLastAdmin(tx *sqlTx, userID int) error {
+	if tx.dialect.adminLockQuery != "" {
+		if _, err := tx.Exec(tx.dialect.adminLockQuery); err != nil {
+			return err
+		}
+	}
+
+	var isAdmin bool
+	err := tx.QueryRow("SELECT is_admin FROM users WHERE id = ?", userID).Scan(&isAdmin)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return nil
+	}
+
+	var others int
+	err = tx.QueryRow("SELECT COUNT(*) FROM users WHERE is_admin = TRUE AND deleted_at IS NULL AND id != ? AND NOT "+suspendedCondition,
+		userID, time.Now()).Scan(&others)
+	if err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// escapeLike protège les caractères spéciaux d'un motif LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
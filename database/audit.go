@@ -0,0 +1,135 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	Action    string                 `json:"action"`
+	ActorID   *int                   `json:"actor_id,omitempty"`
+	Actor     string                 `json:"actor,omitempty"`
+	Target    string                 `json:"target,omitempty"`
+	IP        string                 `json:"ip,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AddAuditEntry ajoute une entrée au journal d'audit
+func (s *SQLStore) AddAuditEntry(entry *AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	var details sql.NullString
+	if len(entry.Details) > 0 {
+		encoded, err := json.Marshal(entry.Details)
+		if err != nil {
+			return err
+		}
+		details = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	var actorID sql.NullInt64
+	if entry.ActorID != nil {
+		actorID = sql.NullInt64{Int64: int64(*entry.ActorID), Valid: true}
+	}
+
+	return s.db.QueryRow("INSERT INTO audit_log (action, actor_id, actor, target, ip, details, created_at) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id",
+		entry.Action, actorID, nullString(entry.Actor), nullString(entry.Target), nullString(entry.IP), details, entry.CreatedAt).Scan(&entry.ID)
+}
+
+// AuditFilter sélectionne des entrées du journal d'audit. Les champs vides
+// sont ignorés.
+type AuditFilter struct {
+	Action string // Action exacte, ou préfixe suivi de "*" ("user.*")
+	Actor  string // Pseudo de l'auteur
+	Target string
+	IP     string
+	Since  *time.Time
+	Until  *time.Time
+	Limit  int
+	Offset int
+}
+
+// ListAuditEntries renvoie les entrées correspondant au filtre, de la plus
+// récente à la plus ancienne, ainsi que leur nombre total
+func (s *SQLStore) ListAuditEntries(filter AuditFilter) ([]AuditEntry, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Action != "" {
+		if strings.HasSuffix(filter.Action, "*") {
+			conditions = append(conditions, `action LIKE ? ESCAPE '\'`)
+			args = append(args, escapeLike(strings.TrimSuffix(filter.Action, "*"))+"%")
+		} else {
+			conditions = append(conditions, "action = ?")
+			args = append(args, filter.Action)
+		}
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Target != "" {
+		conditions = append(conditions, "target = ?")
+		args = append(args, filter.Target)
+	}
+	if filter.IP != "" {
+		conditions = append(conditions, "ip = ?")
+		args = append(args, filter.IP)
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, *filter.Until)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query("SELECT id, action, actor_id, actor, target, ip, details, created_at FROM audit_log"+where+
+		" ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var actorID sql.NullInt64
+		var actor, target, ip, details sql.NullString
+		err := rows.Scan(&entry.ID, &entry.Action, &actorID, &actor, &target, &ip, &details, &entry.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			entry.ActorID = &id
+		}
+		entry.Actor = actor.String
+		entry.Target = target.String
+		entry.IP = ip.String
+		if details.Valid {
+			if err := json.Unmarshal([]byte(details.String), &entry.Details); err != nil {
+				return nil, 0, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	bootstrapAdminUsername = "admin"
+	bootstrapAdminEmail    = "admin@motzarella.com"
+	// Mot de passe historique du compte admin, semé par les anciennes versions
+	legacyAdminPassword = "root"
+)
+
+// BootstrapAdmin crée le compte administrateur initial s'il n'existe aucun
+// administrateur. Le mot de passe vient de ADMIN_PASSWORD ou est généré
+// aléatoirement et affiché une seule fois ; dans les deux cas il devra être
+// changé à la première connexion.
+func BootstrapAdmin(store Store) error {
+	admins, err := store.CountAdmins()
+	if err != nil {
+		return err
+	}
+
+	if admins > 0 {
+		return flagLegacyAdminPassword(store)
+	}
+
+	password := os.Getenv("ADMIN_PASSWORD")
+	generated := password == ""
+	if generated {
+		var err error
+		password, err = GeneratePassword()
+		if err != nil {
+			return err
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	err = createAdmin(store, bootstrapAdminUsername, bootstrapAdminEmail, hashedPassword)
+	if err == errAdminCreated {
+		// Un autre serveur partageant la base l'a créé au même moment
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if generated {
+		log.Printf("Compte administrateur créé : %s / %s (à changer à la première connexion, ce mot de passe ne sera plus affiché)",
+			bootstrapAdminUsername, password)
+	} else {
+		log.Printf("Compte administrateur créé : %s, mot de passe issu de ADMIN_PASSWORD (à changer à la première connexion)",
+			bootstrapAdminUsername)
+	}
+	return nil
+}
+
+// errAdminCreated indique que le compte a été créé entre-temps par ailleurs
+var errAdminCreated = errors.New("admin account created concurrently")
+
+// createAdmin crée un administrateur qui devra changer son mot de passe à
+// la première connexion
+func createAdmin(store Store, username, email string, hashedPassword []byte) error {
+	if err := store.CreateUser(username, email, string(hashedPassword)); err != nil {
+		if existing, _ := store.GetUserByUsername(username); existing != nil {
+			return errAdminCreated
+		}
+		return err
+	}
+	user, err := store.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+	if err := store.SetUserRoles(user.ID, []string{AdminRole}); err != nil {
+		return err
+	}
+	return store.UpdatePassword(user.ID, hashedPassword, true)
+}
+
+// flagLegacyAdminPassword impose le changement de mot de passe du compte
+// admin des bases créées avec l'ancien mot de passe par défaut
+func flagLegacyAdminPassword(store Store) error {
+	user, err := store.GetUserByUsername(bootstrapAdminUsername)
+	if err != nil || user == nil || user.MustChangePassword {
+		return err
+	}
+	if TestPassword(user.Password, legacyAdminPassword) != nil {
+		return nil
+	}
+
+	log.Printf("Le compte %s utilise encore le mot de passe par défaut : il devra être changé à la prochaine connexion", user.Username)
+	return store.UpdatePassword(user.ID, user.Password, true)
+}
+
+// ResetAdmin réinitialise le mot de passe d'un administrateur, ou le crée
+// s'il n'existe pas, et révoque ses sessions. Le nouveau mot de passe devra
+// être changé à la prochaine connexion.
+func ResetAdmin(store Store, username, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user, err := store.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return createAdmin(store, username, username+"@motzarella.com", hashedPassword)
+	}
+
+	if err := store.UpdatePassword(user.ID, hashedPassword, true); err != nil {
+		return err
+	}
+	roles, err := store.GetUserRoles(user.ID)
+	if err != nil {
+		return err
+	}
+	if !containsString(roles, AdminRole) {
+		if err := store.SetUserRoles(user.ID, append(roles, AdminRole)); err != nil {
+			return err
+		}
+	}
+	return store.RevokeUserSessions(user.ID)
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GeneratePassword génère un mot de passe aléatoire de 16 caractères
+func GeneratePassword() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -7,58 +7,167 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var db *sql.DB
-
 type User struct {
-	ID        int    `json:"id"`
-	Username  string `json:"username"`
-	Email     string `json:"email"`
-	Password  []byte `json:"-"` // Changer le type en []byte
-	IsAdmin   bool   `json:"is_admin"`
-	CreatedAt string `json:"created_at"`
+	ID                 int        `json:"id"`
+	Username           string     `json:"username"`
+	Email              string     `json:"email"`
+	Password           []byte     `json:"-"` // Changer le type en []byte
+	IsAdmin            bool       `json:"is_admin"`
+	MustChangePassword bool       `json:"must_change_password"`
+	EmailVerified      bool       `json:"email_verified"`
+	Avatar             string     `json:"avatar,omitempty"` // Nom du fichier dans data/avatars
+	CreatedAt          string     `json:"created_at"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty"` // Suppression par un administrateur
+	Roles              []string   `json:"roles,omitempty"`
+	Permissions        []string   `json:"-"` // Chargées par AuthenticateToken
+}
+
+// Chemin de la base SQLite utilisée par le serveur si DATABASE_URL n'est
+// pas définie
+var DefaultPath = filepath.Join("data", "motzarella.db")
+
+// SQLStore est l'implémentation de Store sur une base SQLite ou PostgreSQL
+type SQLStore struct {
+	db *sqlDB
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// OpenSQLite ouvre une base SQLite sans modifier son schéma, en créant son
+// dossier si nécessaire
+func OpenSQLite(path string) (*SQLStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: &sqlDB{DB: db, dialect: sqliteDialect}}, nil
+}
+
+// OpenPostgres ouvre une base PostgreSQL sans modifier son schéma. url est
+// de la forme postgres://utilisateur:motdepasse@hôte:5432/base?sslmode=disable.
+func OpenPostgres(url string) (*SQLStore, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	// Contrairement à SQLite, la connexion peut échouer : mieux vaut le
+	// savoir au démarrage qu'à la première requête
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLStore{db: &sqlDB{DB: db, dialect: postgresDialect}}, nil
 }
 
-func InitDB() {
-	// Créer le dossier data s'il n'existe pas
-	os.MkdirAll("data", os.ModePerm)
+// Connect ouvre la base désignée par url : postgres:// (ou postgresql://)
+// pour PostgreSQL, sqlite:chemin pour SQLite, ou la base SQLite DefaultPath
+// si url est vide
+func Connect(url string) (*SQLStore, error) {
+	switch {
+	case url == "":
+		return OpenSQLite(DefaultPath)
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return OpenPostgres(url)
+	case strings.HasPrefix(url, "sqlite:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(url, "sqlite:"), "//")
+		if path == "" {
+			return nil, fmt.Errorf("DATABASE_URL: chemin de la base SQLite manquant")
+		}
+		return OpenSQLite(path)
+	}
+	return nil, fmt.Errorf("DATABASE_URL: postgres://... ou sqlite:chemin attendu")
+}
 
-	var err error
-	db, err = sql.Open("sqlite3", filepath.Join("data", "motzarella.db"))
+// Open ouvre la base de données du serveur, désignée par la variable
+// d'environnement DATABASE_URL, sans modifier son schéma
+func Open() *SQLStore {
+	store, err := Connect(os.Getenv("DATABASE_URL"))
 	if err != nil {
 		log.Fatal(err)
 	}
+	return store
+}
+
+// Dialect renvoie le nom de la base : "sqlite" ou "postgres"
+func (s *SQLStore) Dialect() string {
+	return s.db.dialect.name
+}
+
+// InitDB ouvre la base de données du serveur, applique les migrations en
+// attente et crée le compte administrateur initial si nécessaire
+func InitDB() *SQLStore {
+	store := Open()
 
-	// Lire et exécuter le fichier init.sql
-	sqlInit, err := os.ReadFile("database/init.sql")
+	applied, err := store.Migrate()
 	if err != nil {
 		log.Fatal(err)
 	}
+	for _, migration := range applied {
+		log.Printf("Migration %04d_%s appliquée", migration.Version, migration.Name)
+	}
 
-	// Hasher le mot de passe admin par défaut
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("root"), bcrypt.DefaultCost)
+	// Créer le compte administrateur initial si nécessaire
+	err = BootstrapAdmin(store)
 	if err != nil {
 		log.Fatal(err)
 	}
+	return store
+}
 
-	// Remplacer le placeholder par le vrai mot de passe hashé
-	sqlString := string(sqlInit)
-	sqlString = strings.Replace(sqlString, "$2a$10$YOUR_HASHED_PASSWORD", string(hashedPassword), 1)
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
 
-	_, err = db.Exec(sqlString)
+// ensureColumn ajoute une colonne à une table existante si elle n'y est pas
+// encore : CREATE TABLE IF NOT EXISTS ne modifie pas les tables déjà créées.
+func (s *SQLStore) ensureColumn(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
 	}
+	rows.Close()
+
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// Colonnes lues par les fonctions GetUserBy*, dans l'ordre de scanFields
+const userColumns = "id, username, email, password, is_admin, must_change_password, email_verified, COALESCE(avatar, ''), created_at, deleted_at"
+
+func (user *User) scanFields() []interface{} {
+	return []interface{}{&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.MustChangePassword, &user.EmailVerified, &user.Avatar, &user.CreatedAt, &user.DeletedAt}
 }
 
-func GetUserByUsername(username string) (*User, error) {
+func (s *SQLStore) GetUserByUsername(username string) (*User, error) {
 	user := &User{}
-	err := db.QueryRow("SELECT id, username, email, password, is_admin, created_at FROM users WHERE username = ?", username).
-		Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.CreatedAt)
+	err := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username).
+		Scan(user.scanFields()...)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -70,10 +179,10 @@ func GetUserByUsername(username string) (*User, error) {
 	return user, nil
 }
 
-func GetUserByEmail(email string) (*User, error) {
+func (s *SQLStore) GetUserByID(id int) (*User, error) {
 	user := &User{}
-	err := db.QueryRow("SELECT id, username, email, password, is_admin, created_at FROM users WHERE email = ?", email).
-		Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsAdmin, &user.CreatedAt)
+	err := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id).
+		Scan(user.scanFields()...)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -83,12 +192,25 @@ func GetUserByEmail(email string) (*User, error) {
 	return user, nil
 }
 
-func CreateUser(username, email, password string) error {
+func (s *SQLStore) GetUserByEmail(email string) (*User, error) {
+	user := &User{}
+	err := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE email = ?", email).
+		Scan(user.scanFields()...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *SQLStore) CreateUser(username, email, password string) error {
 	// Le mot de passe est déjà hashé par le handler, pas besoin de le hasher à nouveau
 	hashedPassword := []byte(password)
 
 	// Stocker le hash directement en tant que BLOB
-	_, err := db.Exec("INSERT INTO users (username, email, password) VALUES (?, ?, ?)",
+	_, err := s.db.Exec("INSERT INTO users (username, email, password) VALUES (?, ?, ?)",
 		username, email, hashedPassword)
 
 	if err != nil {
@@ -96,7 +218,7 @@ func CreateUser(username, email, password string) error {
 	}
 
 	// Vérifier immédiatement que l'utilisateur peut être récupéré
-	_, err = GetUserByUsername(username)
+	_, err = s.GetUserByUsername(username)
 	if err != nil {
 		return err
 	}
@@ -104,34 +226,44 @@ func CreateUser(username, email, password string) error {
 	return nil
 }
 
+// UpdatePassword remplace le hash du mot de passe d'un utilisateur et
+// positionne l'obligation de le changer à la prochaine connexion
+func (s *SQLStore) UpdatePassword(id int, hashedPassword []byte, mustChange bool) error {
+	_, err := s.db.Exec("UPDATE users SET password = ?, must_change_password = ? WHERE id = ?", hashedPassword, mustChange, id)
+	return err
+}
+
+// SetEmailVerified marque l'adresse d'un utilisateur comme vérifiée, si
+// elle n'a pas changé depuis l'envoi du lien
+func (s *SQLStore) SetEmailVerified(id int, email string) error {
+	_, err := s.db.Exec("UPDATE users SET email_verified = TRUE WHERE id = ? AND email = ?", id, email)
+	return err
+}
+
+// CountAdmins renvoie le nombre d'administrateurs, supprimés compris
+func (s *SQLStore) CountAdmins() (int, error) {
+	var admins int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM users WHERE is_admin = TRUE").Scan(&admins)
+	return admins, err
+}
+
 // Fonction pour tester un mot de passe
 func TestPassword(hashedPassword []byte, password string) error {
 	return bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
 }
 
-func GetAllUsers() ([]User, error) {
-	rows, err := db.Query("SELECT id, username, email, is_admin, created_at FROM users")
+func (s *SQLStore) DeleteUser(id int) error {
+	// Tout est supprimé dans une seule transaction : un échec en cours de
+	// route laisse le compte intact
+	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var user User
-		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.IsAdmin, &user.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, user)
+		return err
 	}
-	return users, nil
-}
+	defer tx.Rollback()
 
-func DeleteUser(id int) error {
 	// D'abord vérifier si l'utilisateur existe
 	var isAdmin bool
-	err := db.QueryRow("SELECT is_admin FROM users WHERE id = ?", id).Scan(&isAdmin)
+	err = tx.QueryRow("SELECT is_admin FROM users WHERE id = ?", id).Scan(&isAdmin)
 	if err == sql.ErrNoRows {
 		return sql.ErrNoRows // L'utilisateur n'existe pas
 	}
@@ -144,11 +276,35 @@ func DeleteUser(id int) error {
 		return fmt.Errorf("cannot delete admin user")
 	}
 
-	// Supprimer l'utilisateur
-	_, err = db.Exec("DELETE FROM users WHERE id = ?", id)
-	if err != nil {
-		return err
+	statements := []string{
+		// Supprimer ses sessions, ce qui invalide immédiatement ses tokens
+		"DELETE FROM sessions WHERE user_id = ?",
+		// Oublier l'auteur des mots, suspensions et décisions de modération
+		// qu'il a pu prononcer : PostgreSQL vérifie les clés étrangères
+		"UPDATE dictionary_words SET added_by = NULL WHERE added_by = ?",
+		"UPDATE user_suspensions SET created_by = NULL WHERE created_by = ?",
+		"UPDATE user_suspensions SET lifted_by = NULL WHERE lifted_by = ?",
+		"UPDATE word_reports SET resolved_by = NULL WHERE resolved_by = ?",
+		// Supprimer ses liens envoyés par email, ses identités externes, ses
+		// rôles, ses suspensions et ses signalements de mots
+		"DELETE FROM email_tokens WHERE user_id = ?",
+		"DELETE FROM user_identities WHERE user_id = ?",
+		"DELETE FROM user_roles WHERE user_id = ?",
+		"DELETE FROM user_suspensions WHERE user_id = ?",
+		"DELETE FROM word_reports WHERE reporter_id = ?",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, id); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	// Puis sa double authentification et l'utilisateur lui-même
+	if err := deleteMFA(tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
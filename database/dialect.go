@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// dialect décrit les différences entre les bases prises en charge. Les
+// requêtes sont écrites une seule fois, avec des paramètres "?", dans le
+// SQL commun à SQLite et PostgreSQL (ON CONFLICT, RETURNING, TRUE/FALSE).
+type dialect struct {
+	name string
+	// Dossier des migrations embarquées, propres à chaque base
+	migrationsDir string
+	// Type des colonnes de date créées hors des migrations
+	timestampType string
+	// Paramètres numérotés ($1, $2...) au lieu de "?"
+	numberedParams bool
+	// Clause LIMIT sans limite, requise par SQLite devant OFFSET
+	noLimit string
+	// Requête indiquant si une table existe, avec son nom en paramètre
+	tableExistsQuery string
+	// Requête exécutée au début de chaque migration pour qu'un seul serveur
+	// applique les migrations à la fois, ou "" si la base n'est ouverte que
+	// par un serveur
+	migrationLockQuery string
+	// Requête exécutée avant de vérifier qu'un autre administrateur resterait,
+	// pour que deux transactions ne retirent pas chacune l'un des deux
+	// derniers. SQLite n'en a pas besoin : il refuse d'écrire dans une
+	// transaction dont les lectures ne sont plus à jour.
+	adminLockQuery string
+}
+
+var sqliteDialect = &dialect{
+	name:             "sqlite",
+	migrationsDir:    "migrations/sqlite",
+	timestampType:    "DATETIME",
+	noLimit:          "LIMIT -1",
+	tableExistsQuery: "SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)",
+}
+
+var postgresDialect = &dialect{
+	name:             "postgres",
+	migrationsDir:    "migrations/postgres",
+	timestampType:    "TIMESTAMPTZ",
+	numberedParams:   true,
+	noLimit:          "LIMIT ALL",
+	tableExistsQuery: "SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?)",
+	// Verrou tenu jusqu'à la fin de la transaction ; la clé est arbitraire
+	migrationLockQuery: "SELECT pg_advisory_xact_lock(727301)",
+	adminLockQuery:     "SELECT pg_advisory_xact_lock(727302)",
+}
+
+// rebind remplace les paramètres "?" d'une requête par ceux du dialecte.
+// Les "?" placés entre apostrophes sont conservés.
+func (d *dialect) rebind(query string) string {
+	if !d.numberedParams || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '?' && !quoted:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// sqlDB exécute les requêtes sur la base en les adaptant à son dialecte
+type sqlDB struct {
+	*sql.DB
+	dialect *dialect
+}
+
+func (db *sqlDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.dialect.rebind(query), args...)
+}
+
+func (db *sqlDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.dialect.rebind(query), args...)
+}
+
+func (db *sqlDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.dialect.rebind(query), args...)
+}
+
+func (db *sqlDB) Begin() (*sqlTx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, dialect: db.dialect}, nil
+}
+
+// sqlTx est l'équivalent de sqlDB pour une transaction
+type sqlTx struct {
+	*sql.Tx
+	dialect *dialect
+}
+
+func (tx *sqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.rebind(query), args...)
+}
+
+func (tx *sqlTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.rebind(query), args...)
+}
+
+func (tx *sqlTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.rebind(query), args...)
+}
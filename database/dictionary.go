@@ -0,0 +1,232 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWordExists       = errors.New("word already exists")
+	ErrLastPlayableWord = errors.New("cannot remove the last playable word")
+)
+
+type DictionaryWord struct {
+	Word       string    `json:"word"`
+	Definition string    `json:"definition,omitempty"`
+	Flagged    bool      `json:"flagged"`
+	FlagReason string    `json:"flag_reason,omitempty"`
+	AddedBy    *int      `json:"added_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// DictionaryFilter décrit une page du dictionnaire
+type DictionaryFilter struct {
+	Search  string // Début du mot
+	Flagged *bool
+	Limit   int
+	Offset  int
+}
+
+const dictionaryColumns = "word, COALESCE(definition, ''), flagged, COALESCE(flag_reason, ''), added_by, created_at, updated_at"
+
+func scanDictionaryWord(scan func(...interface{}) error) (*DictionaryWord, error) {
+	word := &DictionaryWord{}
+	var addedBy sql.NullInt64
+	err := scan(&word.Word, &word.Definition, &word.Flagged, &word.FlagReason, &addedBy, &word.CreatedAt, &word.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if addedBy.Valid {
+		id := int(addedBy.Int64)
+		word.AddedBy = &id
+	}
+	return word, nil
+}
+
+func (s *SQLStore) CountDictionaryWords() (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM dictionary_words").Scan(&count)
+	return count, err
+}
+
+// DictionaryVersion renvoie un numéro qui change à chaque modification du
+// dictionnaire, y compris par un autre serveur partageant la base
+func (s *SQLStore) DictionaryVersion() (int64, error) {
+	var version int64
+	err := s.db.QueryRow("SELECT version FROM dictionary_version").Scan(&version)
+	return version, err
+}
+
+// ListDictionaryWords renvoie une page du dictionnaire par ordre alphabétique,
+// ainsi que le nombre total de résultats. Une limite nulle renvoie tout.
+func (s *SQLStore) ListDictionaryWords(filter DictionaryFilter) ([]DictionaryWord, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Search != "" {
+		conditions = append(conditions, `word LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(filter.Search)+"%")
+	}
+	if filter.Flagged != nil {
+		conditions = append(conditions, "flagged = ?")
+		args = append(args, *filter.Flagged)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM dictionary_words"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := s.db.dialect.noLimit
+	if filter.Limit > 0 {
+		limit = "LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	rows, err := s.db.Query("SELECT "+dictionaryColumns+" FROM dictionary_words"+where+" ORDER BY word "+limit+" OFFSET ?",
+		append(args, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	words := []DictionaryWord{}
+	for rows.Next() {
+		word, err := scanDictionaryWord(rows.Scan)
+		if err != nil {
+			return nil, 0, err
+		}
+		words = append(words, *word)
+	}
+	return words, total, rows.Err()
+}
+
+// GetDictionaryWord renvoie un mot du dictionnaire, ou nil s'il n'y est pas
+func (s *SQLStore) GetDictionaryWord(word string) (*DictionaryWord, error) {
+	row := s.db.QueryRow("SELECT "+dictionaryColumns+" FROM dictionary_words WHERE word = ?", word)
+	entry, err := scanDictionaryWord(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return entry, err
+}
+
+// AddDictionaryWord ajoute un mot déjà normalisé. Renvoie ErrWordExists s'il
+// est déjà présent.
+func (s *SQLStore) AddDictionaryWord(word, definition string, addedBy *int) error {
+	now := time.Now()
+	result, err := s.db.Exec("INSERT INTO dictionary_words (word, definition, added_by, created_at, updated_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		word, nullString(definition), addedBy, now, now)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrWordExists
+	}
+	return nil
+}
+
+// ImportDictionaryWords ajoute des mots déjà normalisés en une transaction.
+// La définition d'un mot déjà présent est remplacée si une nouvelle est
+// fournie. Renvoie le nombre de mots ajoutés et mis à jour.
+func (s *SQLStore) ImportDictionaryWords(words []DictionaryWord, addedBy *int) (int, int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	added, updated := 0, 0
+	for _, word := range words {
+		result, err := tx.Exec("INSERT INTO dictionary_words (word, definition, added_by, created_at, updated_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+			word.Word, nullString(word.Definition), addedBy, now, now)
+		if err != nil {
+			return 0, 0, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added++
+			continue
+		}
+		if word.Definition == "" {
+			continue
+		}
+		result, err = tx.Exec("UPDATE dictionary_words SET definition = ?, updated_at = ? WHERE word = ? AND COALESCE(definition, '') != ?",
+			word.Definition, now, word.Word, word.Definition)
+		if err != nil {
+			return 0, 0, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			updated++
+		}
+	}
+	return added, updated, tx.Commit()
+}
+
+// RemoveDictionaryWord retire un mot. Renvoie sql.ErrNoRows s'il n'existe
+// pas et ErrLastPlayableWord s'il est le dernier mot jouable.
+func (s *SQLStore) RemoveDictionaryWord(word string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkOtherPlayableWords(tx, word); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM dictionary_words WHERE word = ?", word)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// SetWordFlag signale un mot avec un motif, ou retire le signalement.
+// Renvoie sql.ErrNoRows si le mot n'existe pas et ErrLastPlayableWord s'il
+// est le dernier mot jouable.
+func (s *SQLStore) SetWordFlag(word string, flagged bool, reason string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if flagged {
+		if err := checkOtherPlayableWords(tx, word); err != nil {
+			return err
+		}
+	} else {
+		reason = ""
+	}
+	result, err := tx.Exec("UPDATE dictionary_words SET flagged = ?, flag_reason = ?, updated_at = ? WHERE word = ?",
+		flagged, nullString(reason), time.Now(), word)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// checkOtherPlayableWords vérifie qu'il resterait un mot jouable sans celui-ci
+func checkOtherPlayableWords(tx *sqlTx, word string) error {
+	var others int
+	err := tx.QueryRow("SELECT COUNT(*) FROM dictionary_words WHERE flagged = FALSE AND word != ?", word).Scan(&others)
+	if err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastPlayableWord
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+type EmailToken struct {
+	ID        string
+	UserID    int
+	Purpose   string
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// CreateEmailToken enregistre un token et invalide les précédents tokens
+// inutilisés du même usage pour cet utilisateur
+func (s *SQLStore) CreateEmailToken(t *EmailToken) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE email_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL",
+		t.CreatedAt, t.UserID, t.Purpose)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO email_tokens (id, user_id, purpose, email, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		t.ID, t.UserID, t.Purpose, t.Email, t.CreatedAt, t.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConsumeEmailToken marque un token comme utilisé et le renvoie. Renvoie nil
+// si le token n'existe pas, a déjà servi ou a expiré.
+func (s *SQLStore) ConsumeEmailToken(id, purpose string) (*EmailToken, error) {
+	now := time.Now()
+	result, err := s.db.Exec("UPDATE email_tokens SET used_at = ? WHERE id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+		now, id, purpose, now)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+
+	t := &EmailToken{UsedAt: &now}
+	err = s.db.QueryRow("SELECT id, user_id, purpose, email, created_at, expires_at FROM email_tokens WHERE id = ?", id).
+		Scan(&t.ID, &t.UserID, &t.Purpose, &t.Email, &t.CreatedAt, &t.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
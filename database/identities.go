@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GetUserByIdentity renvoie l'utilisateur rattaché à une identité externe,
+// ou nil si elle n'est rattachée à aucun compte
+func (s *SQLStore) GetUserByIdentity(provider, subject string) (*User, error) {
+	var userID int
+	err := s.db.QueryRow("SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?", provider, subject).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec("UPDATE user_identities SET last_login_at = ? WHERE provider = ? AND subject = ?", time.Now(), provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetUserByID(userID)
+}
+
+// LinkIdentity rattache une identité externe à un compte existant
+func (s *SQLStore) LinkIdentity(userID int, provider, subject, email string) error {
+	now := time.Now()
+	_, err := s.db.Exec("INSERT INTO user_identities (user_id, provider, subject, email, created_at, last_login_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, provider, subject, nullString(email), now, now)
+	return err
+}
+
+// CreateExternalUser crée un compte pour une identité externe. Le mot de
+// passe est un hash inutilisable : l'utilisateur peut en définir un avec
+// "mot de passe oublié". Renvoie ErrUsernameTaken ou ErrEmailTaken en cas de
+// conflit.
+func (s *SQLStore) CreateExternalUser(username, email string, emailVerified bool, hashedPassword []byte, provider, subject string) (*User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRow("SELECT id FROM users WHERE username = ?", username).Scan(&existing)
+	if err == nil {
+		return nil, ErrUsernameTaken
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+	err = tx.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&existing)
+	if err == nil {
+		return nil, ErrEmailTaken
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var userID int
+	err = tx.QueryRow("INSERT INTO users (username, email, password, email_verified) VALUES (?, ?, ?, ?) RETURNING id",
+		username, email, hashedPassword, emailVerified).Scan(&userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec("INSERT INTO user_identities (user_id, provider, subject, email, created_at, last_login_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, provider, subject, nullString(email), now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetUserByID(userID)
+}
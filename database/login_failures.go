@@ -0,0 +1,62 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LoginFailures compte les échecs de connexion récents d'un compte ou d'une adresse
+type LoginFailures struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// SaveLoginFailures enregistre l'état des échecs d'une clé
+func (s *SQLStore) SaveLoginFailures(f *LoginFailures) error {
+	var lockedUntil sql.NullTime
+	if !f.LockedUntil.IsZero() {
+		lockedUntil = sql.NullTime{Time: f.LockedUntil, Valid: true}
+	}
+	_, err := s.db.Exec(`INSERT INTO login_failures (key, failures, last_failure, locked_until) VALUES (?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET failures = excluded.failures, last_failure = excluded.last_failure, locked_until = excluded.locked_until`,
+		f.Key, f.Failures, f.LastFailure, lockedUntil)
+	return err
+}
+
+// DeleteLoginFailures efface les échecs d'une clé, après une connexion réussie
+func (s *SQLStore) DeleteLoginFailures(key string) error {
+	_, err := s.db.Exec("DELETE FROM login_failures WHERE key = ?", key)
+	return err
+}
+
+// GetLoginFailures renvoie les échecs survenus depuis since ou dont le
+// verrouillage court encore, et supprime les autres
+func (s *SQLStore) GetLoginFailures(since time.Time) ([]LoginFailures, error) {
+	now := time.Now()
+	_, err := s.db.Exec("DELETE FROM login_failures WHERE last_failure < ? AND (locked_until IS NULL OR locked_until < ?)", since, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT key, failures, last_failure, locked_until FROM login_failures")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []LoginFailures
+	for rows.Next() {
+		var f LoginFailures
+		var lockedUntil sql.NullTime
+		if err := rows.Scan(&f.Key, &f.Failures, &f.LastFailure, &lockedUntil); err != nil {
+			return nil, err
+		}
+		if lockedUntil.Valid {
+			f.LockedUntil = lockedUntil.Time
+		}
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type Match struct {
+	ID               string        `json:"id"`
+	Word             string        `json:"word"`
+	OpponentProgress bool          `json:"opponent_progress"`
+	Private          bool          `json:"private"`
+	WinnerID         string        `json:"winner_id,omitempty"`
+	StartedAt        time.Time     `json:"started_at"`
+	EndedAt          time.Time     `json:"ended_at"`
+	Players          []MatchPlayer `json:"players"`
+	Guesses          []MatchGuess  `json:"guesses,omitempty"`
+	Chat             []MatchChat   `json:"-"` // Consulté uniquement par les modérateurs via GetMatchChat
+}
+
+type MatchPlayer struct {
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"` // Vide pour un invité
+}
+
+type MatchGuess struct {
+	PlayerID  string    `json:"player_id"`
+	Guess     string    `json:"guess"`
+	Result    []string  `json:"result"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MatchChat struct {
+	PlayerID  string    `json:"player_id"`
+	Username  string    `json:"username,omitempty"`
+	Message   string    `json:"message"`
+	Original  string    `json:"original,omitempty"` // Renseigné si le message a été filtré
+	Emote     string    `json:"emote,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveMatch enregistre une partie terminée avec ses joueurs et ses tentatives
+func (s *SQLStore) SaveMatch(match *Match) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO matches (id, word, opponent_progress, private, winner_id, started_at, ended_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		match.ID, match.Word, match.OpponentProgress, match.Private, nullString(match.WinnerID), match.StartedAt, match.EndedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, player := range match.Players {
+		_, err = tx.Exec("INSERT INTO match_players (match_id, player_id, username) VALUES (?, ?, ?)",
+			match.ID, player.ID, nullString(player.Username))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, guess := range match.Guesses {
+		result, err := json.Marshal(guess.Result)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO match_guesses (match_id, player_id, guess, result, created_at) VALUES (?, ?, ?, ?, ?)",
+			match.ID, guess.PlayerID, guess.Guess, string(result), guess.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, chat := range match.Chat {
+		_, err = tx.Exec("INSERT INTO match_chat (match_id, player_id, message, original, emote, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			match.ID, chat.PlayerID, chat.Message, nullString(chat.Original), nullString(chat.Emote), chat.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetMatch renvoie une partie avec sa chronologie complète, ou nil si elle n'existe pas
+func (s *SQLStore) GetMatch(id string) (*Match, error) {
+	match := &Match{}
+	var winnerID sql.NullString
+	err := s.db.QueryRow("SELECT id, word, opponent_progress, private, winner_id, started_at, ended_at FROM matches WHERE id = ?", id).
+		Scan(&match.ID, &match.Word, &match.OpponentProgress, &match.Private, &winnerID, &match.StartedAt, &match.EndedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	match.WinnerID = winnerID.String
+
+	match.Players, err = s.getMatchPlayers(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT player_id, guess, result, created_at FROM match_guesses WHERE match_id = ? ORDER BY created_at, id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var guess MatchGuess
+		var result string
+		if err := rows.Scan(&guess.PlayerID, &guess.Guess, &result, &guess.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(result), &guess.Result); err != nil {
+			return nil, err
+		}
+		match.Guesses = append(match.Guesses, guess)
+	}
+	return match, rows.Err()
+}
+
+// GetUserMatches renvoie une page de l'historique d'un joueur, de la partie la
+// plus récente à la plus ancienne, sans les tentatives, ainsi que le nombre
+// total de parties. Les parties privées ne sont incluses que si includePrivate est vrai.
+func (s *SQLStore) GetUserMatches(username string, includePrivate bool, limit, offset int) ([]Match, int, error) {
+	filter := "FROM matches WHERE id IN (SELECT match_id FROM match_players WHERE username = ?)"
+	if !includePrivate {
+		filter += " AND private = FALSE"
+	}
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) "+filter, username).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query("SELECT id, word, opponent_progress, private, winner_id, started_at, ended_at "+
+		filter+" ORDER BY started_at DESC LIMIT ? OFFSET ?", username, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	matches := []Match{}
+	for rows.Next() {
+		var match Match
+		var winnerID sql.NullString
+		err := rows.Scan(&match.ID, &match.Word, &match.OpponentProgress, &match.Private, &winnerID, &match.StartedAt, &match.EndedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		match.WinnerID = winnerID.String
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	for i := range matches {
+		matches[i].Players, err = s.getMatchPlayers(matches[i].ID)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return matches, total, nil
+}
+
+// GetMatchChat renvoie le chat d'une partie, messages d'origine compris
+func (s *SQLStore) GetMatchChat(matchID string) ([]MatchChat, error) {
+	rows, err := s.db.Query(`SELECT c.player_id, p.username, c.message, c.original, c.emote, c.created_at
+		FROM match_chat c LEFT JOIN match_players p ON p.match_id = c.match_id AND p.player_id = c.player_id
+		WHERE c.match_id = ? ORDER BY c.created_at, c.id`, matchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chat := []MatchChat{}
+	for rows.Next() {
+		var entry MatchChat
+		var username, original, emote sql.NullString
+		if err := rows.Scan(&entry.PlayerID, &username, &entry.Message, &original, &emote, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Username = username.String
+		entry.Original = original.String
+		entry.Emote = emote.String
+		chat = append(chat, entry)
+	}
+	return chat, rows.Err()
+}
+
+func (s *SQLStore) getMatchPlayers(matchID string) ([]MatchPlayer, error) {
+	rows, err := s.db.Query("SELECT player_id, username FROM match_players WHERE match_id = ?", matchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	players := []MatchPlayer{}
+	for rows.Next() {
+		var player MatchPlayer
+		var username sql.NullString
+		if err := rows.Scan(&player.ID, &username); err != nil {
+			return nil, err
+		}
+		player.Username = username.String
+		players = append(players, player)
+	}
+	return players, rows.Err()
+}
+
+// nullString convertit une chaîne vide en NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,1328 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore est une implémentation de Store qui garde les données en
+// mémoire, pour tester les handlers sans base SQLite. Elle reproduit le
+// comportement de SQLStore, erreurs comprises ; les données sont perdues à
+// la fermeture.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	users         map[int]*User
+	userRoles     map[int][]string
+	roles         []Role
+	identities    []*memoryIdentity
+	suspensions   []*Suspension
+	sessions      map[string]*Session
+	mfa           map[int]*UserMFA
+	recoveryCodes map[int][]*memoryRecoveryCode
+	emailTokens   map[string]*EmailToken
+	loginFailures map[string]LoginFailures
+	matches       map[string]*Match
+	dictionary    map[string]*DictionaryWord
+	wordReports   []*memoryWordReport
+	audit         []AuditEntry
+
+	lastUserID       int
+	lastSuspensionID int
+	lastReportID     int64
+	lastAuditID      int64
+
+	// Incrémentée à chaque modification du dictionnaire
+	dictionaryVersion int64
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+type memoryIdentity struct {
+	userID      int
+	provider    string
+	subject     string
+	email       string
+	lastLoginAt time.Time
+}
+
+type memoryRecoveryCode struct {
+	hash []byte
+	used bool
+}
+
+type memoryWordReport struct {
+	WordReport
+	status string
+}
+
+// NewMemoryStore crée un stockage vide, avec les rôles créés par la
+// migration initiale
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:     make(map[int]*User),
+		userRoles: make(map[int][]string),
+		roles: []Role{
+			{ID: 1, Name: AdminRole, Description: "Accès complet à l'administration", Permissions: []string{
+				PermAuditRead, PermChatModerate, PermDictionaryWrite, PermMatchesReadPrivate,
+				PermRolesManage, PermUsersManage, PermUsersRead,
+			}},
+			{ID: 2, Name: "moderator", Description: "Consultation des utilisateurs et modération du chat", Permissions: []string{
+				PermChatModerate, PermMatchesReadPrivate, PermUsersRead,
+			}},
+			{ID: 3, Name: "dictionary_editor", Description: "Gestion du dictionnaire", Permissions: []string{
+				PermDictionaryWrite,
+			}},
+		},
+		sessions:      make(map[string]*Session),
+		mfa:           make(map[int]*UserMFA),
+		recoveryCodes: make(map[int][]*memoryRecoveryCode),
+		emailTokens:   make(map[string]*EmailToken),
+		loginFailures: make(map[string]LoginFailures),
+		matches:       make(map[string]*Match),
+		dictionary:    make(map[string]*DictionaryWord),
+	}
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// pageBounds renvoie les bornes d'une page de n éléments, comme LIMIT et
+// OFFSET : une limite négative renvoie tout à partir de offset
+func pageBounds(n, limit, offset int) (int, int) {
+	if offset > n {
+		offset = n
+	}
+	end := n
+	if limit >= 0 && offset+limit < n {
+		end = offset + limit
+	}
+	return offset, end
+}
+
+// Utilisateurs
+
+// copyUser renvoie une copie de l'utilisateur, comme lue depuis la base
+func copyUser(user *User) *User {
+	if user == nil {
+		return nil
+	}
+	copied := *user
+	return &copied
+}
+
+func (m *MemoryStore) userByUsername(username string) *User {
+	for _, user := range m.users {
+		if user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) userByEmail(email string) *User {
+	for _, user := range m.users {
+		if user.Email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetUserByUsername(username string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return copyUser(m.userByUsername(username)), nil
+}
+
+func (m *MemoryStore) GetUserByID(id int) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return copyUser(m.users[id]), nil
+}
+
+func (m *MemoryStore) GetUserByEmail(email string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return copyUser(m.userByEmail(email)), nil
+}
+
+// insertUser ajoute un utilisateur, en refusant un pseudo ou un email déjà
+// utilisé comme le font les contraintes UNIQUE de la table users
+func (m *MemoryStore) insertUser(username, email string, hashedPassword []byte) (*User, error) {
+	if m.userByUsername(username) != nil {
+		return nil, ErrUsernameTaken
+	}
+	if m.userByEmail(email) != nil {
+		return nil, ErrEmailTaken
+	}
+	m.lastUserID++
+	user := &User{
+		ID:        m.lastUserID,
+		Username:  username,
+		Email:     email,
+		Password:  hashedPassword,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *MemoryStore) CreateUser(username, email, password string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.insertUser(username, email, []byte(password))
+	return err
+}
+
+func (m *MemoryStore) UpdatePassword(id int, hashedPassword []byte, mustChange bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.users[id]; user != nil {
+		user.Password = hashedPassword
+		user.MustChangePassword = mustChange
+	}
+	return nil
+}
+
+func (m *MemoryStore) SetEmailVerified(id int, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.users[id]; user != nil && user.Email == email {
+		user.EmailVerified = true
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateUsername(id int, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.users[id]
+	if user == nil {
+		return sql.ErrNoRows
+	}
+	if existing := m.userByUsername(username); existing != nil && existing.ID != id {
+		return ErrUsernameTaken
+	}
+
+	for _, match := range m.matches {
+		for i := range match.Players {
+			if match.Players[i].Username == user.Username {
+				match.Players[i].Username = username
+			}
+		}
+	}
+	user.Username = username
+	return nil
+}
+
+func (m *MemoryStore) UpdateEmail(id int, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing := m.userByEmail(email); existing != nil && existing.ID != id {
+		return ErrEmailTaken
+	}
+	if user := m.users[id]; user != nil {
+		user.Email = email
+		user.EmailVerified = false
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateAvatar(id int, avatar string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if user := m.users[id]; user != nil {
+		user.Avatar = avatar
+	}
+	return nil
+}
+
+func (m *MemoryStore) CountAdmins() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	admins := 0
+	for _, user := range m.users {
+		if user.IsAdmin {
+			admins++
+		}
+	}
+	return admins, nil
+}
+
+func (m *MemoryStore) DeleteUser(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.users[id]
+	if user == nil {
+		return sql.ErrNoRows
+	}
+	if user.IsAdmin {
+		return fmt.Errorf("cannot delete admin user")
+	}
+
+	for sessionID, s := range m.sessions {
+		if s.UserID == id {
+			delete(m.sessions, sessionID)
+		}
+	}
+	for _, entry := range m.dictionary {
+		if entry.AddedBy != nil && *entry.AddedBy == id {
+			entry.AddedBy = nil
+			m.dictionaryVersion++
+		}
+	}
+	for tokenID, t := range m.emailTokens {
+		if t.UserID == id {
+			delete(m.emailTokens, tokenID)
+		}
+	}
+	identities := m.identities[:0]
+	for _, identity := range m.identities {
+		if identity.userID != id {
+			identities = append(identities, identity)
+		}
+	}
+	m.identities = identities
+	delete(m.userRoles, id)
+	suspensions := m.suspensions[:0]
+	for _, suspension := range m.suspensions {
+		if suspension.CreatedBy != nil && *suspension.CreatedBy == id {
+			suspension.CreatedBy = nil
+		}
+		if suspension.UserID != id {
+			suspensions = append(suspensions, suspension)
+		}
+	}
+	m.suspensions = suspensions
+	reports := m.wordReports[:0]
+	for _, report := range m.wordReports {
+		if report.ReporterID != id {
+			reports = append(reports, report)
+		}
+	}
+	m.wordReports = reports
+	delete(m.mfa, id)
+	delete(m.recoveryCodes, id)
+	delete(m.users, id)
+	return nil
+}
+
+// Administration des comptes
+
+func (m *MemoryStore) ListUsers(filter UserFilter) ([]AdminUser, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	search := strings.ToLower(strings.TrimSpace(filter.Search))
+
+	var matched []*User
+	for _, user := range m.users {
+		deleted := user.DeletedAt != nil
+		switch filter.Status {
+		case "active":
+			if deleted || m.activeSuspension(user.ID, now) != nil {
+				continue
+			}
+		case "suspended":
+			if deleted || m.activeSuspension(user.ID, now) == nil {
+				continue
+			}
+		case "deleted":
+			if !deleted {
+				continue
+			}
+		case "admins":
+			if deleted || !user.IsAdmin {
+				continue
+			}
+		case "all":
+		default:
+			if deleted {
+				continue
+			}
+		}
+		if search != "" && !strings.Contains(strings.ToLower(user.Username), search) &&
+			!strings.Contains(strings.ToLower(user.Email), search) {
+			continue
+		}
+		matched = append(matched, user)
+	}
+
+	// Même ordre que SQLStore : la colonne demandée, puis l'identifiant
+	sortKey := func(user *User) string {
+		switch filter.Sort {
+		case "username":
+			return strings.ToLower(user.Username)
+		case "email":
+			return strings.ToLower(user.Email)
+		case "created_at":
+			return user.CreatedAt
+		}
+		return ""
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if ka, kb := sortKey(a), sortKey(b); ka != kb {
+			return (ka < kb) != filter.Desc
+		}
+		if _, ok := userSortColumns[filter.Sort]; !ok || filter.Sort == "id" {
+			return (a.ID < b.ID) != filter.Desc
+		}
+		return a.ID < b.ID
+	})
+
+	start, end := pageBounds(len(matched), filter.Limit, filter.Offset)
+	users := []AdminUser{}
+	for _, user := range matched[start:end] {
+		users = append(users, m.adminUser(user, now))
+	}
+	return users, len(matched), nil
+}
+
+func (m *MemoryStore) adminUser(user *User, now time.Time) AdminUser {
+	adminUser := AdminUser{User: *user}
+	adminUser.Roles = m.rolesOf(user.ID)
+	if suspension := m.activeSuspension(user.ID, now); suspension != nil {
+		copied := *suspension
+		adminUser.Suspension = &copied
+	}
+	return adminUser
+}
+
+func (m *MemoryStore) GetAdminUser(id int) (*AdminUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.users[id]
+	if user == nil {
+		return nil, nil
+	}
+	adminUser := m.adminUser(user, time.Now())
+	return &adminUser, nil
+}
+
+func (m *MemoryStore) GetUserStats(user *User) (*UserStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := &UserStats{}
+	for _, match := range m.matches {
+		for _, player := range match.Players {
+			if player.Username != user.Username {
+				continue
+			}
+			stats.MatchesPlayed++
+			if match.WinnerID == player.ID {
+				stats.MatchesWon++
+			}
+			for _, guess := range match.Guesses {
+				if guess.PlayerID == player.ID {
+					stats.Guesses++
+				}
+			}
+			for _, chat := range match.Chat {
+				if chat.PlayerID == player.ID {
+					stats.ChatMessages++
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, session := range m.sessions {
+		if session.UserID != user.ID {
+			continue
+		}
+		if session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			stats.ActiveSessions++
+		}
+		if stats.LastLoginAt == nil || session.CreatedAt.After(*stats.LastLoginAt) {
+			createdAt := session.CreatedAt
+			stats.LastLoginAt = &createdAt
+		}
+	}
+	return stats, nil
+}
+
+func (m *MemoryStore) SoftDeleteUser(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkNotLastAdmin(userID); err != nil {
+		return err
+	}
+	user := m.users[userID]
+	if user.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	user.DeletedAt = &now
+	m.revokeSessions(func(s *Session) bool { return s.UserID == userID })
+	return nil
+}
+
+func (m *MemoryStore) RestoreUser(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.users[userID]
+	if user == nil || user.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+	user.DeletedAt = nil
+	return nil
+}
+
+// checkNotLastAdmin suit les règles de la fonction du même nom de SQLStore
+func (m *MemoryStore) checkNotLastAdmin(userID int) error {
+	user := m.users[userID]
+	if user == nil {
+		return sql.ErrNoRows
+	}
+	if !user.IsAdmin {
+		return nil
+	}
+	now := time.Now()
+	for _, other := range m.users {
+		if other.ID != userID && other.IsAdmin && other.DeletedAt == nil && m.activeSuspension(other.ID, now) == nil {
+			return nil
+		}
+	}
+	return ErrLastAdmin
+}
+
+// Identités externes
+
+func (m *MemoryStore) GetUserByIdentity(provider, subject string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, identity := range m.identities {
+		if identity.provider == provider && identity.subject == subject {
+			identity.lastLoginAt = time.Now()
+			return copyUser(m.users[identity.userID]), nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) linkIdentity(userID int, provider, subject, email string) error {
+	for _, identity := range m.identities {
+		if identity.provider == provider && identity.subject == subject {
+			return fmt.Errorf("identity %s/%s already linked", provider, subject)
+		}
+	}
+	m.identities = append(m.identities, &memoryIdentity{
+		userID:      userID,
+		provider:    provider,
+		subject:     subject,
+		email:       email,
+		lastLoginAt: time.Now(),
+	})
+	return nil
+}
+
+func (m *MemoryStore) LinkIdentity(userID int, provider, subject, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.linkIdentity(userID, provider, subject, email)
+}
+
+func (m *MemoryStore) CreateExternalUser(username, email string, emailVerified bool, hashedPassword []byte, provider, subject string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, err := m.insertUser(username, email, hashedPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.linkIdentity(user.ID, provider, subject, email); err != nil {
+		delete(m.users, user.ID)
+		return nil, err
+	}
+	user.EmailVerified = emailVerified
+	return copyUser(user), nil
+}
+
+// Suspensions
+
+// activeSuspension renvoie la suspension en cours d'un utilisateur, un
+// bannissement définitif en priorité, sinon celle qui finit le plus tard
+func (m *MemoryStore) activeSuspension(userID int, now time.Time) *Suspension {
+	var active *Suspension
+	for _, suspension := range m.suspensions {
+		if suspension.UserID != userID || suspension.LiftedAt != nil ||
+			suspension.ExpiresAt != nil && !suspension.ExpiresAt.After(now) {
+			continue
+		}
+		if active == nil || active.ExpiresAt != nil &&
+			(suspension.ExpiresAt == nil || suspension.ExpiresAt.After(*active.ExpiresAt)) {
+			active = suspension
+		}
+	}
+	return active
+}
+
+func (m *MemoryStore) GetActiveSuspension(userID int) (*Suspension, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	suspension := m.activeSuspension(userID, time.Now())
+	if suspension == nil {
+		return nil, nil
+	}
+	copied := *suspension
+	return &copied, nil
+}
+
+// liftSuspensions lève les suspensions en cours d'un utilisateur et renvoie
+// leur nombre
+func (m *MemoryStore) liftSuspensions(userID int, now time.Time) int {
+	lifted := 0
+	for suspension := m.activeSuspension(userID, now); suspension != nil; suspension = m.activeSuspension(userID, now) {
+		liftedAt := now
+		suspension.LiftedAt = &liftedAt
+		lifted++
+	}
+	return lifted
+}
+
+func (m *MemoryStore) SuspendUser(userID int, reason string, expiresAt *time.Time, createdBy int) (*Suspension, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkNotLastAdmin(userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	m.liftSuspensions(userID, now)
+	m.lastSuspensionID++
+	suspension := &Suspension{
+		ID:        m.lastSuspensionID,
+		UserID:    userID,
+		Reason:    reason,
+		CreatedBy: &createdBy,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	m.suspensions = append(m.suspensions, suspension)
+	m.revokeSessions(func(s *Session) bool { return s.UserID == userID })
+
+	copied := *suspension
+	return &copied, nil
+}
+
+func (m *MemoryStore) LiftSuspension(userID, liftedBy int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.liftSuspensions(userID, time.Now()) == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Rôles
+
+func (m *MemoryStore) rolesOf(userID int) []string {
+	roles := append([]string{}, m.userRoles[userID]...)
+	sort.Strings(roles)
+	return roles
+}
+
+func (m *MemoryStore) GetRoles() ([]Role, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	roles := make([]Role, len(m.roles))
+	for i, role := range m.roles {
+		roles[i] = role
+		roles[i].Permissions = append([]string{}, role.Permissions...)
+	}
+	return roles, nil
+}
+
+func (m *MemoryStore) GetUserRoles(userID int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rolesOf(userID), nil
+}
+
+func (m *MemoryStore) GetUserPermissions(userID int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]bool)
+	permissions := []string{}
+	for _, name := range m.userRoles[userID] {
+		for _, role := range m.roles {
+			if role.Name != name {
+				continue
+			}
+			for _, permission := range role.Permissions {
+				if !seen[permission] {
+					seen[permission] = true
+					permissions = append(permissions, permission)
+				}
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func (m *MemoryStore) SetUserRoles(userID int, roles []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := []string{}
+	isAdmin := false
+	for _, name := range roles {
+		known := false
+		for _, role := range m.roles {
+			known = known || role.Name == name
+		}
+		if !known {
+			return ErrUnknownRole
+		}
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+		if name == AdminRole {
+			isAdmin = true
+		}
+	}
+
+	if !isAdmin {
+		if err := m.checkNotLastAdmin(userID); err != nil {
+			return err
+		}
+	}
+	user := m.users[userID]
+	if user == nil {
+		return sql.ErrNoRows
+	}
+	user.IsAdmin = isAdmin
+	m.userRoles[userID] = names
+	return nil
+}
+
+// Sessions
+
+// revokeSessions révoque les sessions actives sélectionnées
+func (m *MemoryStore) revokeSessions(selected func(*Session) bool) {
+	now := time.Now()
+	for _, session := range m.sessions {
+		if session.RevokedAt == nil && selected(session) {
+			revokedAt := now
+			session.RevokedAt = &revokedAt
+		}
+	}
+}
+
+func (m *MemoryStore) CreateSession(session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.sessions[session.ID]; exists {
+		return fmt.Errorf("session %s already exists", session.ID)
+	}
+	copied := *session
+	m.sessions[session.ID] = &copied
+	return nil
+}
+
+func (m *MemoryStore) GetSession(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session := m.sessions[id]
+	if session == nil {
+		return nil, nil
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (m *MemoryStore) RotateSession(id string, oldHash, newHash []byte, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session := m.sessions[id]
+	if session == nil || session.RevokedAt != nil || !bytes.Equal(session.RefreshTokenHash, oldHash) {
+		return sql.ErrNoRows
+	}
+	session.RefreshTokenHash = newHash
+	session.LastUsedAt = time.Now()
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
+func (m *MemoryStore) RevokeSession(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokeSessions(func(s *Session) bool { return s.ID == id })
+	return nil
+}
+
+func (m *MemoryStore) RevokeUserSessions(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokeSessions(func(s *Session) bool { return s.UserID == userID })
+	return nil
+}
+
+func (m *MemoryStore) RevokeOtherSessions(userID int, keepID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokeSessions(func(s *Session) bool { return s.UserID == userID && s.ID != keepID })
+	return nil
+}
+
+func (m *MemoryStore) GetUserSessions(userID int) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	sessions := []Session{}
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			copied := *session
+			copied.RefreshTokenHash = nil
+			sessions = append(sessions, copied)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// Double authentification
+
+func (m *MemoryStore) GetUserMFA(userID int) (*UserMFA, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mfa := m.mfa[userID]
+	if mfa == nil {
+		return nil, nil
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (m *MemoryStore) SetPendingMFA(userID int, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mfa[userID] = &UserMFA{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (m *MemoryStore) replaceRecoveryCodes(userID int, recoveryHashes [][]byte) {
+	codes := make([]*memoryRecoveryCode, len(recoveryHashes))
+	for i, hash := range recoveryHashes {
+		codes[i] = &memoryRecoveryCode{hash: hash}
+	}
+	m.recoveryCodes[userID] = codes
+}
+
+func (m *MemoryStore) EnableMFA(userID int, step int64, recoveryHashes [][]byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mfa := m.mfa[userID]; mfa != nil {
+		now := time.Now()
+		mfa.Enabled = true
+		mfa.LastStep = step
+		mfa.EnabledAt = &now
+	}
+	m.replaceRecoveryCodes(userID, recoveryHashes)
+	return nil
+}
+
+func (m *MemoryStore) ReplaceRecoveryCodes(userID int, recoveryHashes [][]byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replaceRecoveryCodes(userID, recoveryHashes)
+	return nil
+}
+
+func (m *MemoryStore) UseMFAStep(userID int, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mfa := m.mfa[userID]
+	if mfa == nil || mfa.LastStep >= step {
+		return false, nil
+	}
+	mfa.LastStep = step
+	return true, nil
+}
+
+func (m *MemoryStore) UseRecoveryCode(userID int, hash []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, code := range m.recoveryCodes[userID] {
+		if !code.used && bytes.Equal(code.hash, hash) {
+			code.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) CountRecoveryCodes(userID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, code := range m.recoveryCodes[userID] {
+		if !code.used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) DisableMFA(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.mfa, userID)
+	delete(m.recoveryCodes, userID)
+	return nil
+}
+
+// Tokens envoyés par email
+
+func (m *MemoryStore) CreateEmailToken(t *EmailToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.emailTokens[t.ID]; exists {
+		return fmt.Errorf("email token %s already exists", t.ID)
+	}
+	for _, previous := range m.emailTokens {
+		if previous.UserID == t.UserID && previous.Purpose == t.Purpose && previous.UsedAt == nil {
+			usedAt := t.CreatedAt
+			previous.UsedAt = &usedAt
+		}
+	}
+	copied := *t
+	copied.UsedAt = nil
+	m.emailTokens[t.ID] = &copied
+	return nil
+}
+
+func (m *MemoryStore) ConsumeEmailToken(id, purpose string) (*EmailToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	t := m.emailTokens[id]
+	if t == nil || t.Purpose != purpose || t.UsedAt != nil || !t.ExpiresAt.After(now) {
+		return nil, nil
+	}
+	t.UsedAt = &now
+	copied := *t
+	return &copied, nil
+}
+
+// Échecs de connexion
+
+func (m *MemoryStore) SaveLoginFailures(f *LoginFailures) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loginFailures[f.Key] = *f
+	return nil
+}
+
+func (m *MemoryStore) DeleteLoginFailures(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.loginFailures, key)
+	return nil
+}
+
+func (m *MemoryStore) GetLoginFailures(since time.Time) ([]LoginFailures, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var list []LoginFailures
+	for key, f := range m.loginFailures {
+		if f.LastFailure.Before(since) && (f.LockedUntil.IsZero() || f.LockedUntil.Before(now)) {
+			delete(m.loginFailures, key)
+			continue
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
+
+// Parties
+
+func (m *MemoryStore) SaveMatch(match *Match) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.matches[match.ID]; exists {
+		return fmt.Errorf("match %s already exists", match.ID)
+	}
+	copied := *match
+	copied.Players = append([]MatchPlayer{}, match.Players...)
+	copied.Guesses = append([]MatchGuess(nil), match.Guesses...)
+	copied.Chat = append([]MatchChat(nil), match.Chat...)
+	sort.SliceStable(copied.Guesses, func(i, j int) bool {
+		return copied.Guesses[i].CreatedAt.Before(copied.Guesses[j].CreatedAt)
+	})
+	sort.SliceStable(copied.Chat, func(i, j int) bool {
+		return copied.Chat[i].CreatedAt.Before(copied.Chat[j].CreatedAt)
+	})
+	m.matches[match.ID] = &copied
+	return nil
+}
+
+func (m *MemoryStore) GetMatch(id string) (*Match, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	match := m.matches[id]
+	if match == nil {
+		return nil, nil
+	}
+	copied := *match
+	copied.Players = append([]MatchPlayer{}, match.Players...)
+	copied.Guesses = append([]MatchGuess(nil), match.Guesses...)
+	copied.Chat = nil
+	return &copied, nil
+}
+
+func (m *MemoryStore) GetUserMatches(username string, includePrivate bool, limit, offset int) ([]Match, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matched []*Match
+	for _, match := range m.matches {
+		if match.Private && !includePrivate {
+			continue
+		}
+		for _, player := range match.Players {
+			if player.Username == username {
+				matched = append(matched, match)
+				break
+			}
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].StartedAt.After(matched[j].StartedAt)
+	})
+
+	start, end := pageBounds(len(matched), limit, offset)
+	matches := []Match{}
+	for _, match := range matched[start:end] {
+		copied := *match
+		copied.Players = append([]MatchPlayer{}, match.Players...)
+		copied.Guesses = nil
+		copied.Chat = nil
+		matches = append(matches, copied)
+	}
+	return matches, len(matched), nil
+}
+
+func (m *MemoryStore) GetMatchChat(matchID string) ([]MatchChat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	chat := []MatchChat{}
+	match := m.matches[matchID]
+	if match == nil {
+		return chat, nil
+	}
+	for _, entry := range match.Chat {
+		entry.Username = ""
+		for _, player := range match.Players {
+			if player.ID == entry.PlayerID {
+				entry.Username = player.Username
+			}
+		}
+		chat = append(chat, entry)
+	}
+	return chat, nil
+}
+
+// Dictionnaire
+
+func (m *MemoryStore) CountDictionaryWords() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.dictionary), nil
+}
+
+func (m *MemoryStore) DictionaryVersion() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dictionaryVersion, nil
+}
+
+func (m *MemoryStore) ListDictionaryWords(filter DictionaryFilter) ([]DictionaryWord, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	search := strings.ToUpper(filter.Search)
+	var matched []DictionaryWord
+	for _, word := range m.dictionary {
+		if !strings.HasPrefix(strings.ToUpper(word.Word), search) {
+			continue
+		}
+		if filter.Flagged != nil && word.Flagged != *filter.Flagged {
+			continue
+		}
+		matched = append(matched, *word)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Word < matched[j].Word
+	})
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	start, end := pageBounds(len(matched), limit, filter.Offset)
+	return append([]DictionaryWord{}, matched[start:end]...), len(matched), nil
+}
+
+func (m *MemoryStore) GetDictionaryWord(word string) (*DictionaryWord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.dictionary[word]
+	if entry == nil {
+		return nil, nil
+	}
+	copied := *entry
+	return &copied, nil
+}
+
+func (m *MemoryStore) addDictionaryWord(word, definition string, addedBy *int, now time.Time) bool {
+	if _, exists := m.dictionary[word]; exists {
+		return false
+	}
+	entry := &DictionaryWord{Word: word, Definition: definition, CreatedAt: now, UpdatedAt: now}
+	if addedBy != nil {
+		id := *addedBy
+		entry.AddedBy = &id
+	}
+	m.dictionary[word] = entry
+	m.dictionaryVersion++
+	return true
+}
+
+func (m *MemoryStore) AddDictionaryWord(word, definition string, addedBy *int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.addDictionaryWord(word, definition, addedBy, time.Now()) {
+		return ErrWordExists
+	}
+	return nil
+}
+
+func (m *MemoryStore) ImportDictionaryWords(words []DictionaryWord, addedBy *int) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	added, updated := 0, 0
+	for _, word := range words {
+		if m.addDictionaryWord(word.Word, word.Definition, addedBy, now) {
+			added++
+			continue
+		}
+		if entry := m.dictionary[word.Word]; word.Definition != "" && entry.Definition != word.Definition {
+			entry.Definition = word.Definition
+			entry.UpdatedAt = now
+			m.dictionaryVersion++
+			updated++
+		}
+	}
+	return added, updated, nil
+}
+
+// checkOtherPlayableWords vérifie qu'il resterait un mot jouable sans celui-ci
+func (m *MemoryStore) checkOtherPlayableWords(word string) error {
+	for _, entry := range m.dictionary {
+		if !entry.Flagged && entry.Word != word {
+			return nil
+		}
+	}
+	return ErrLastPlayableWord
+}
+
+func (m *MemoryStore) RemoveDictionaryWord(word string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkOtherPlayableWords(word); err != nil {
+		return err
+	}
+	if _, exists := m.dictionary[word]; !exists {
+		return sql.ErrNoRows
+	}
+	delete(m.dictionary, word)
+	m.dictionaryVersion++
+	return nil
+}
+
+func (m *MemoryStore) SetWordFlag(word string, flagged bool, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if flagged {
+		if err := m.checkOtherPlayableWords(word); err != nil {
+			return err
+		}
+	} else {
+		reason = ""
+	}
+	entry := m.dictionary[word]
+	if entry == nil {
+		return sql.ErrNoRows
+	}
+	entry.Flagged = flagged
+	entry.FlagReason = reason
+	entry.UpdatedAt = time.Now()
+	m.dictionaryVersion++
+	return nil
+}
+
+// Signalements de mots
+
+func (m *MemoryStore) AddWordReport(report *WordReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.wordReports {
+		if existing.status == ReportPending && existing.Word == report.Word &&
+			existing.Kind == report.Kind && existing.ReporterID == report.ReporterID {
+			return ErrAlreadyReported
+		}
+	}
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+	m.lastReportID++
+	report.ID = m.lastReportID
+	m.wordReports = append(m.wordReports, &memoryWordReport{WordReport: *report, status: ReportPending})
+	return nil
+}
+
+func (m *MemoryStore) CountPendingReportsByUser(userID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, report := range m.wordReports {
+		if report.ReporterID == userID && report.status == ReportPending {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) ListWordReportGroups(kind string, limit, offset int) ([]WordReportGroup, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	type groupKey struct{ word, kind string }
+	groups := make(map[groupKey]*WordReportGroup)
+	lastID := make(map[groupKey]int64)
+	var keys []groupKey
+
+	// Les signalements sont rangés par identifiant croissant : on les
+	// parcourt à rebours pour garder les plus récents
+	for i := len(m.wordReports) - 1; i >= 0; i-- {
+		report := m.wordReports[i]
+		if report.status != ReportPending || kind != "" && report.Kind != kind {
+			continue
+		}
+		key := groupKey{report.Word, report.Kind}
+		group := groups[key]
+		if group == nil {
+			_, inDictionary := m.dictionary[report.Word]
+			group = &WordReportGroup{Word: report.Word, Kind: report.Kind, InDictionary: inDictionary, Latest: []WordReport{}}
+			groups[key] = group
+			lastID[key] = report.ID
+			keys = append(keys, key)
+		}
+		group.Reports++
+		reporter := m.users[report.ReporterID]
+		if reporter != nil && len(group.Latest) < latestReportsPerGroup {
+			latest := report.WordReport
+			latest.Reporter = reporter.Username
+			group.Latest = append(group.Latest, latest)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := groups[keys[i]], groups[keys[j]]
+		if a.Reports != b.Reports {
+			return a.Reports > b.Reports
+		}
+		return lastID[keys[i]] > lastID[keys[j]]
+	})
+
+	start, end := pageBounds(len(keys), limit, offset)
+	page := []WordReportGroup{}
+	for _, key := range keys[start:end] {
+		page = append(page, *groups[key])
+	}
+	return page, len(keys), nil
+}
+
+func (m *MemoryStore) HasPendingWordReports(word, kind string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, report := range m.wordReports {
+		if report.status == ReportPending && report.Word == word && report.Kind == kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryStore) ResolveWordReports(word, kind, status string, resolvedBy int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, report := range m.wordReports {
+		if report.status == ReportPending && report.Word == word && report.Kind == kind {
+			report.status = status
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return count, nil
+}
+
+// Journal d'audit
+
+func (m *MemoryStore) AddAuditEntry(entry *AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	m.lastAuditID++
+	entry.ID = m.lastAuditID
+	m.audit = append(m.audit, *entry)
+	return nil
+}
+
+func (m *MemoryStore) ListAuditEntries(filter AuditFilter) ([]AuditEntry, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matched []AuditEntry
+	for _, entry := range m.audit {
+		if filter.Action != "" {
+			if prefix := strings.TrimSuffix(filter.Action, "*"); prefix != filter.Action {
+				if !strings.HasPrefix(entry.Action, prefix) {
+					continue
+				}
+			} else if entry.Action != filter.Action {
+				continue
+			}
+		}
+		if filter.Actor != "" && entry.Actor != filter.Actor ||
+			filter.Target != "" && entry.Target != filter.Target ||
+			filter.IP != "" && entry.IP != filter.IP ||
+			filter.Since != nil && entry.CreatedAt.Before(*filter.Since) ||
+			filter.Until != nil && !entry.CreatedAt.Before(*filter.Until) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].ID > matched[j].ID
+	})
+
+	start, end := pageBounds(len(matched), filter.Limit, filter.Offset)
+	return append([]AuditEntry{}, matched[start:end]...), len(matched), nil
+}
@@ -0,0 +1,12 @@
+package database_test
+
+import (
+	"testing"
+
+	"motzarella/database"
+	"motzarella/database/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, database.NewMemoryStore())
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+type UserMFA struct {
+	UserID    int
+	Secret    string
+	Enabled   bool
+	LastStep  int64
+	CreatedAt time.Time
+	EnabledAt *time.Time
+}
+
+// GetUserMFA renvoie la configuration de double authentification d'un
+// utilisateur, ou nil s'il n'en a pas
+func (s *SQLStore) GetUserMFA(userID int) (*UserMFA, error) {
+	mfa := &UserMFA{}
+	var enabledAt sql.NullTime
+	err := s.db.QueryRow("SELECT user_id, secret, enabled, last_step, created_at, enabled_at FROM user_mfa WHERE user_id = ?", userID).
+		Scan(&mfa.UserID, &mfa.Secret, &mfa.Enabled, &mfa.LastStep, &mfa.CreatedAt, &enabledAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt.Valid {
+		mfa.EnabledAt = &enabledAt.Time
+	}
+	return mfa, nil
+}
+
+// SetPendingMFA enregistre un nouveau secret, inactif jusqu'à EnableMFA
+func (s *SQLStore) SetPendingMFA(userID int, secret string) error {
+	_, err := s.db.Exec(`INSERT INTO user_mfa (user_id, secret, enabled, last_step, created_at) VALUES (?, ?, FALSE, 0, ?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, enabled = FALSE, last_step = 0, created_at = excluded.created_at, enabled_at = NULL`,
+		userID, secret, time.Now())
+	return err
+}
+
+// EnableMFA active la double authentification et remplace les codes de secours
+func (s *SQLStore) EnableMFA(userID int, step int64, recoveryHashes [][]byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE user_mfa SET enabled = TRUE, last_step = ?, enabled_at = ? WHERE user_id = ?", step, time.Now(), userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes remplace les codes de secours d'un utilisateur
+func (s *SQLStore) ReplaceRecoveryCodes(userID int, recoveryHashes [][]byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sqlTx, userID int, recoveryHashes [][]byte) error {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, hash := range recoveryHashes {
+		if _, err := tx.Exec("INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseMFAStep enregistre la dernière période TOTP utilisée. Renvoie false si
+// une période égale ou postérieure a déjà servi (code rejoué).
+func (s *SQLStore) UseMFAStep(userID int, step int64) (bool, error) {
+	result, err := s.db.Exec("UPDATE user_mfa SET last_step = ? WHERE user_id = ? AND last_step < ?", step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// UseRecoveryCode consomme un code de secours. Renvoie false s'il n'existe
+// pas ou a déjà été utilisé.
+func (s *SQLStore) UseRecoveryCode(userID int, hash []byte) (bool, error) {
+	result, err := s.db.Exec("UPDATE mfa_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now(), userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CountRecoveryCodes renvoie le nombre de codes de secours encore utilisables
+func (s *SQLStore) CountRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+// DisableMFA supprime la double authentification et les codes de secours
+func (s *SQLStore) DisableMFA(userID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteMFA(tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteMFA supprime la double authentification et les codes de secours
+// d'un utilisateur dans une transaction en cours
+func deleteMFA(tx *sqlTx, userID int) error {
+	if _, err := tx.Exec("DELETE FROM user_mfa WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = ?", userID)
+	return err
+}
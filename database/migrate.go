@@ -0,0 +1,300 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scripts de migration, embarqués dans l'exécutable, dans un dossier par
+// base. Chaque migration NNNN_nom comporte un script NNNN_nom.up.sql et un
+// script NNNN_nom.down.sql.
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState indique si une migration est appliquée, et depuis quand
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// loadMigrations lit les migrations embarquées d'un dossier, par version croissante
+func loadMigrations(migrationsDir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		file := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(file, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: suffixe .up.sql ou .down.sql attendu", file)
+		}
+
+		base := strings.TrimSuffix(file, "."+direction+".sql")
+		number, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: nom de la forme NNNN_nom attendu", file)
+		}
+
+		script, err := migrationFiles.ReadFile(path.Join(migrationsDir, file))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration %d: noms différents (%s, %s)", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: scripts up et down requis", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// ensureMigrationsTable crée la table des migrations appliquées. Une base
+// créée avant les migrations (par l'ancien init.sql) est d'abord mise à
+// niveau pour que le schéma initial puisse y être appliqué.
+func (s *SQLStore) ensureMigrationsTable() error {
+	exists, err := s.tableExists("schema_migrations")
+	if err != nil || exists {
+		return err
+	}
+
+	// Les bases PostgreSQL ont toujours été créées par les migrations
+	if s.db.dialect == sqliteDialect {
+		if err := s.upgradeLegacySchema(); err != nil {
+			return err
+		}
+	}
+	// IF NOT EXISTS : un autre serveur peut la créer au même moment
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at ` + s.db.dialect.timestampType + ` NOT NULL
+	)`)
+	return err
+}
+
+// tableExists indique si une table existe dans la base
+func (s *SQLStore) tableExists(name string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(s.db.dialect.tableExistsQuery, name).Scan(&exists)
+	return exists, err
+}
+
+// upgradeLegacySchema ajoute à une base créée par l'ancien init.sql les
+// colonnes qu'ajoutait ensureColumn, que le schéma initial ne peut pas
+// ajouter à une table existante
+func (s *SQLStore) upgradeLegacySchema() error {
+	legacy, err := s.tableExists("users")
+	if err != nil || !legacy {
+		return err
+	}
+
+	log.Println("Base créée avant les migrations : mise à niveau du schéma")
+	columns := []struct{ name, definition string }{
+		{"must_change_password", "BOOLEAN DEFAULT 0"},
+		{"email_verified", "BOOLEAN DEFAULT 0"},
+		{"avatar", "TEXT"},
+		{"deleted_at", "DATETIME"},
+	}
+	for _, column := range columns {
+		if err := s.ensureColumn("users", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliedMigrations renvoie la date d'application de chaque version appliquée
+func (s *SQLStore) appliedMigrations() (map[int]time.Time, error) {
+	rows, err := s.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatus renvoie toutes les migrations connues avec leur état, sans
+// modifier la base
+func (s *SQLStore) MigrationStatus() ([]MigrationState, error) {
+	migrations, err := loadMigrations(s.db.dialect.migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked, err := s.tableExists("schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time)
+	if tracked {
+		applied, err = s.appliedMigrations()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, migration := range migrations {
+		states[i].Migration = migration
+		if appliedAt, ok := applied[migration.Version]; ok {
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+	return states, nil
+}
+
+// Migrate applique les migrations en attente, chacune dans sa propre
+// transaction, et renvoie celles qui ont été appliquées
+func (s *SQLStore) Migrate() ([]Migration, error) {
+	if err := s.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	states, err := s.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, state := range states {
+		if state.AppliedAt != nil {
+			continue
+		}
+		applied, err := s.runMigration(state.Migration, false, func(tx *sqlTx) error {
+			_, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				state.Version, state.Name, time.Now())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", state.Version, state.Name, err)
+		}
+		if applied {
+			done = append(done, state.Migration)
+		}
+	}
+	return done, nil
+}
+
+// Rollback annule les steps dernières migrations appliquées, de la plus
+// récente à la plus ancienne, et renvoie celles qui ont été annulées
+func (s *SQLStore) Rollback(steps int) ([]Migration, error) {
+	if err := s.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	states, err := s.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(states) - 1; i >= 0 && len(done) < steps; i-- {
+		state := states[i]
+		if state.AppliedAt == nil {
+			continue
+		}
+		rolledBack, err := s.runMigration(state.Migration, true, func(tx *sqlTx) error {
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", state.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", state.Version, state.Name, err)
+		}
+		if rolledBack {
+			done = append(done, state.Migration)
+		}
+	}
+	return done, nil
+}
+
+// runMigration exécute le script up (ou down) d'une migration et met à jour
+// schema_migrations dans une même transaction : en cas d'erreur, la base
+// reste dans son état précédent. Sous PostgreSQL, plusieurs serveurs peuvent
+// démarrer en même temps : une fois le verrou obtenu, la migration n'est
+// exécutée que si elle est toujours en attente (ou appliquée pour down).
+// Renvoie false si un autre serveur s'en est chargé.
+func (s *SQLStore) runMigration(migration Migration, down bool, record func(tx *sqlTx) error) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if lock := s.db.dialect.migrationLockQuery; lock != "" {
+		if _, err := tx.Exec(lock); err != nil {
+			return false, err
+		}
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)", migration.Version).Scan(&exists)
+		if err != nil {
+			return false, err
+		}
+		if exists != down {
+			return false, nil
+		}
+	}
+
+	script := migration.Up
+	if down {
+		script = migration.Down
+	}
+	// Le script est exécuté tel quel, sans adaptation des paramètres
+	if _, err := tx.Tx.Exec(script); err != nil {
+		return false, err
+	}
+	if err := record(tx); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,257 @@
+-- Schéma initial pour PostgreSQL, équivalent à migrations/sqlite/0001_initial.
+-- Les colonnes triées utilisent la collation "C" pour être ordonnées octet par
+-- octet, comme sous SQLite, quelle que soit la locale du serveur.
+
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    username TEXT COLLATE "C" UNIQUE NOT NULL,
+    email TEXT COLLATE "C" UNIQUE NOT NULL,
+    password BYTEA NOT NULL,
+    is_admin BOOLEAN DEFAULT FALSE,
+    must_change_password BOOLEAN DEFAULT FALSE,
+    email_verified BOOLEAN DEFAULT FALSE,
+    avatar TEXT, -- Nom du fichier dans data/avatars
+    created_at TIMESTAMPTZ DEFAULT date_trunc('second', CURRENT_TIMESTAMP), -- À la seconde, comme sous SQLite
+    deleted_at TIMESTAMPTZ -- Suppression par un administrateur
+);
+
+-- Le compte admin initial est créé par InitDB (voir bootstrapAdmin)
+
+-- Historique des parties multijoueur, enregistré à la fin de chaque partie
+CREATE TABLE matches (
+    id TEXT PRIMARY KEY,
+    word TEXT NOT NULL,
+    opponent_progress BOOLEAN DEFAULT TRUE,
+    private BOOLEAN DEFAULT FALSE,
+    winner_id TEXT,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE match_players (
+    match_id TEXT NOT NULL REFERENCES matches(id),
+    player_id TEXT NOT NULL,
+    username TEXT, -- NULL pour un invité
+    PRIMARY KEY (match_id, player_id)
+);
+
+CREATE INDEX idx_match_players_username ON match_players(username);
+
+CREATE TABLE match_guesses (
+    id BIGSERIAL PRIMARY KEY,
+    match_id TEXT NOT NULL REFERENCES matches(id),
+    player_id TEXT NOT NULL,
+    guess TEXT NOT NULL,
+    result TEXT NOT NULL, -- Résultat de checkGuess encodé en JSON
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_match_guesses_match ON match_guesses(match_id);
+
+-- Messages du chat des parties, conservés pour la modération
+CREATE TABLE match_chat (
+    id BIGSERIAL PRIMARY KEY,
+    match_id TEXT NOT NULL REFERENCES matches(id),
+    player_id TEXT NOT NULL,
+    message TEXT NOT NULL, -- Message tel que diffusé, après filtrage
+    original TEXT, -- Message d'origine s'il a été filtré
+    emote TEXT,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_match_chat_match ON match_chat(match_id);
+
+-- Sessions de connexion : chaque session porte un refresh token (stocké haché)
+-- renouvelé à chaque utilisation. Une session révoquée invalide ses tokens d'accès.
+CREATE TABLE sessions (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    refresh_token_hash BYTEA NOT NULL,
+    user_agent TEXT,
+    ip TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id);
+
+-- Échecs de connexion et verrouillages temporaires, par compte ("user:<nom>")
+-- ou par adresse ("ip:<adresse>"), conservés si LOGIN_LIMIT_PERSIST est activé
+CREATE TABLE login_failures (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+
+-- Journal d'audit des actions d'administration et des événements de sécurité.
+-- Les entrées ne sont jamais modifiées ni supprimées.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    actor_id INTEGER, -- NULL pour les actions du système
+    actor TEXT,
+    target TEXT,
+    ip TEXT,
+    details TEXT, -- JSON
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_audit_log_created ON audit_log(created_at);
+CREATE INDEX idx_audit_log_action ON audit_log(action);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor);
+
+-- Le journal est en ajout seul : toute modification ou suppression échoue
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+-- Tokens à usage unique envoyés par email (vérification d'adresse,
+-- réinitialisation du mot de passe). Le token signé ne contient que l'id.
+CREATE TABLE email_tokens (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    purpose TEXT NOT NULL,
+    email TEXT NOT NULL, -- Adresse à laquelle le token a été envoyé
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_email_tokens_user ON email_tokens(user_id, purpose);
+
+-- Double authentification (TOTP). Le secret est enregistré dès l'inscription
+-- mais n'est actif qu'après la vérification d'un premier code.
+CREATE TABLE user_mfa (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id),
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    last_step BIGINT NOT NULL DEFAULT 0, -- Dernière période utilisée, contre le rejeu
+    created_at TIMESTAMPTZ NOT NULL,
+    enabled_at TIMESTAMPTZ
+);
+
+-- Codes de secours de la double authentification, stockés hachés
+CREATE TABLE mfa_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    code_hash BYTEA NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id);
+
+-- Identités externes (OpenID Connect) rattachées aux comptes
+CREATE TABLE user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL, -- Claim "sub" du fournisseur
+    email TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_login_at TIMESTAMPTZ,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
+
+-- Rôles et permissions. Le rôle admin porte toutes les permissions ; la
+-- colonne users.is_admin est conservée et tenue à jour d'après ce rôle.
+CREATE TABLE roles (
+    id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    description TEXT
+);
+
+CREATE TABLE role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id),
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE user_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    role_id INTEGER NOT NULL REFERENCES roles(id),
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Accès complet à l''administration'),
+    ('moderator', 'Consultation des utilisateurs et modération du chat'),
+    ('dictionary_editor', 'Gestion du dictionnaire');
+
+INSERT INTO role_permissions (role_id, permission)
+    SELECT id, permission FROM roles, (VALUES
+        ('users:read'),
+        ('users:manage'),
+        ('roles:manage'),
+        ('matches:read_private'),
+        ('chat:moderate'),
+        ('dictionary:write'),
+        ('audit:read')
+    ) AS permissions(permission) WHERE name = 'admin';
+
+INSERT INTO role_permissions (role_id, permission)
+    SELECT id, permission FROM roles, (VALUES
+        ('users:read'),
+        ('matches:read_private'),
+        ('chat:moderate')
+    ) AS permissions(permission) WHERE name = 'moderator';
+
+INSERT INTO role_permissions (role_id, permission)
+    SELECT id, 'dictionary:write' FROM roles WHERE name = 'dictionary_editor';
+
+-- Suspensions de comptes prononcées par les administrateurs. Une suspension
+-- sans date de fin est un bannissement définitif ; lifted_at est renseigné
+-- quand elle est levée avant son terme.
+CREATE TABLE user_suspensions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    reason TEXT NOT NULL,
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ,
+    lifted_at TIMESTAMPTZ,
+    lifted_by INTEGER REFERENCES users(id)
+);
+
+CREATE INDEX idx_user_suspensions_user ON user_suspensions(user_id);
+
+-- Dictionnaire du jeu, modifiable par les administrateurs. Un mot signalé
+-- reste accepté comme proposition mais n'est plus tiré comme mot mystère.
+CREATE TABLE dictionary_words (
+    word TEXT COLLATE "C" PRIMARY KEY, -- Majuscules sans accent
+    definition TEXT,
+    flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    flag_reason TEXT,
+    added_by INTEGER REFERENCES users(id), -- NULL pour la liste initiale
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Signalements de mots par les joueurs, traités dans la file de modération
+CREATE TABLE word_reports (
+    id BIGSERIAL PRIMARY KEY,
+    word TEXT NOT NULL, -- Majuscules sans accent
+    kind TEXT NOT NULL, -- 'missing' (mot à ajouter) ou 'remove' (mot à retirer)
+    comment TEXT,
+    reporter_id INTEGER NOT NULL REFERENCES users(id),
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending', 'approved' ou 'rejected'
+    created_at TIMESTAMPTZ NOT NULL,
+    resolved_by INTEGER REFERENCES users(id),
+    resolved_at TIMESTAMPTZ
+);
+
+-- Un joueur ne signale qu'une fois le même mot tant que le signalement est en attente
+CREATE UNIQUE INDEX idx_word_reports_pending ON word_reports(word, kind, reporter_id) WHERE status = 'pending';
+CREATE INDEX idx_word_reports_status ON word_reports(status, word, kind);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS dictionary_words_changed ON dictionary_words;
+DROP FUNCTION IF EXISTS dictionary_words_changed;
+DROP TABLE IF EXISTS dictionary_version;
@@ -0,0 +1,19 @@
+-- Version du dictionnaire, incrémentée à chaque modification de
+-- dictionary_words : les serveurs qui partagent la base la surveillent pour
+-- recharger leurs mots.
+CREATE TABLE dictionary_version (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version BIGINT NOT NULL
+);
+
+INSERT INTO dictionary_version (id, version) VALUES (1, 0);
+
+CREATE FUNCTION dictionary_words_changed() RETURNS trigger AS $$
+BEGIN
+    UPDATE dictionary_version SET version = version + 1;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER dictionary_words_changed AFTER INSERT OR UPDATE OR DELETE ON dictionary_words
+    FOR EACH STATEMENT EXECUTE FUNCTION dictionary_words_changed();
@@ -0,0 +1,19 @@
+-- Supprime tout le schéma, données comprises
+DROP TABLE IF EXISTS word_reports;
+DROP TABLE IF EXISTS dictionary_words;
+DROP TABLE IF EXISTS user_suspensions;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
+DROP TABLE IF EXISTS email_tokens;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS login_failures;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS match_chat;
+DROP TABLE IF EXISTS match_guesses;
+DROP TABLE IF EXISTS match_players;
+DROP TABLE IF EXISTS matches;
+DROP TABLE IF EXISTS users;
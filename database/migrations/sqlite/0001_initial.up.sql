@@ -0,0 +1,258 @@
+-- Schéma initial, identique à l'ancien database/init.sql. Les IF NOT EXISTS
+-- permettent de l'appliquer sur une base créée avant les migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT UNIQUE NOT NULL,
+    email TEXT UNIQUE NOT NULL,
+    password BLOB NOT NULL,
+    is_admin BOOLEAN DEFAULT 0,
+    must_change_password BOOLEAN DEFAULT 0,
+    email_verified BOOLEAN DEFAULT 0,
+    avatar TEXT, -- Nom du fichier dans data/avatars
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    deleted_at DATETIME -- Suppression par un administrateur
+);
+
+-- Le compte admin initial est créé par InitDB (voir bootstrapAdmin)
+
+-- Historique des parties multijoueur, enregistré à la fin de chaque partie
+CREATE TABLE IF NOT EXISTS matches (
+    id TEXT PRIMARY KEY,
+    word TEXT NOT NULL,
+    opponent_progress BOOLEAN DEFAULT 1,
+    private BOOLEAN DEFAULT 0,
+    winner_id TEXT,
+    started_at DATETIME NOT NULL,
+    ended_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS match_players (
+    match_id TEXT NOT NULL REFERENCES matches(id),
+    player_id TEXT NOT NULL,
+    username TEXT, -- NULL pour un invité
+    PRIMARY KEY (match_id, player_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_match_players_username ON match_players(username);
+
+CREATE TABLE IF NOT EXISTS match_guesses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    match_id TEXT NOT NULL REFERENCES matches(id),
+    player_id TEXT NOT NULL,
+    guess TEXT NOT NULL,
+    result TEXT NOT NULL, -- Résultat de checkGuess encodé en JSON
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_match_guesses_match ON match_guesses(match_id);
+
+-- Messages du chat des parties, conservés pour la modération
+CREATE TABLE IF NOT EXISTS match_chat (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    match_id TEXT NOT NULL REFERENCES matches(id),
+    player_id TEXT NOT NULL,
+    message TEXT NOT NULL, -- Message tel que diffusé, après filtrage
+    original TEXT, -- Message d'origine s'il a été filtré
+    emote TEXT,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_match_chat_match ON match_chat(match_id);
+
+-- Sessions de connexion : chaque session porte un refresh token (stocké haché)
+-- renouvelé à chaque utilisation. Une session révoquée invalide ses tokens d'accès.
+CREATE TABLE IF NOT EXISTS sessions (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    refresh_token_hash BLOB NOT NULL,
+    user_agent TEXT,
+    ip TEXT,
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+
+-- Échecs de connexion et verrouillages temporaires, par compte ("user:<nom>")
+-- ou par adresse ("ip:<adresse>"), conservés si LOGIN_LIMIT_PERSIST est activé
+CREATE TABLE IF NOT EXISTS login_failures (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure DATETIME NOT NULL,
+    locked_until DATETIME
+);
+
+-- Journal d'audit des actions d'administration et des événements de sécurité.
+-- Les entrées ne sont jamais modifiées ni supprimées.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action TEXT NOT NULL,
+    actor_id INTEGER, -- NULL pour les actions du système
+    actor TEXT,
+    target TEXT,
+    ip TEXT,
+    details TEXT, -- JSON
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor);
+
+-- Le journal est en ajout seul : toute modification ou suppression échoue
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+-- Tokens à usage unique envoyés par email (vérification d'adresse,
+-- réinitialisation du mot de passe). Le token signé ne contient que l'id.
+CREATE TABLE IF NOT EXISTS email_tokens (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    purpose TEXT NOT NULL,
+    email TEXT NOT NULL, -- Adresse à laquelle le token a été envoyé
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_tokens_user ON email_tokens(user_id, purpose);
+
+-- Double authentification (TOTP). Le secret est enregistré dès l'inscription
+-- mais n'est actif qu'après la vérification d'un premier code.
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id),
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    last_step INTEGER NOT NULL DEFAULT 0, -- Dernière période utilisée, contre le rejeu
+    created_at DATETIME NOT NULL,
+    enabled_at DATETIME
+);
+
+-- Codes de secours de la double authentification, stockés hachés
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    code_hash BLOB NOT NULL,
+    used_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id);
+
+-- Identités externes (OpenID Connect) rattachées aux comptes
+CREATE TABLE IF NOT EXISTS user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL, -- Claim "sub" du fournisseur
+    email TEXT,
+    created_at DATETIME NOT NULL,
+    last_login_at DATETIME,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+
+-- Rôles et permissions. Le rôle admin porte toutes les permissions ; la
+-- colonne users.is_admin est conservée et tenue à jour d'après ce rôle.
+CREATE TABLE IF NOT EXISTS roles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id),
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    role_id INTEGER NOT NULL REFERENCES roles(id),
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT OR IGNORE INTO roles (name, description) VALUES
+    ('admin', 'Accès complet à l''administration'),
+    ('moderator', 'Consultation des utilisateurs et modération du chat'),
+    ('dictionary_editor', 'Gestion du dictionnaire');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission)
+    SELECT id, permission FROM roles, (
+        SELECT 'users:read' AS permission UNION ALL
+        SELECT 'users:manage' UNION ALL
+        SELECT 'roles:manage' UNION ALL
+        SELECT 'matches:read_private' UNION ALL
+        SELECT 'chat:moderate' UNION ALL
+        SELECT 'dictionary:write' UNION ALL
+        SELECT 'audit:read'
+    ) WHERE name = 'admin';
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission)
+    SELECT id, permission FROM roles, (
+        SELECT 'users:read' AS permission UNION ALL
+        SELECT 'matches:read_private' UNION ALL
+        SELECT 'chat:moderate'
+    ) WHERE name = 'moderator';
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission)
+    SELECT id, 'dictionary:write' FROM roles WHERE name = 'dictionary_editor';
+
+-- Les administrateurs créés avant l'introduction des rôles
+INSERT OR IGNORE INTO user_roles (user_id, role_id)
+    SELECT users.id, roles.id FROM users, roles WHERE users.is_admin = 1 AND roles.name = 'admin';
+
+-- Suspensions de comptes prononcées par les administrateurs. Une suspension
+-- sans date de fin est un bannissement définitif ; lifted_at est renseigné
+-- quand elle est levée avant son terme.
+CREATE TABLE IF NOT EXISTS user_suspensions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    reason TEXT NOT NULL,
+    created_by INTEGER REFERENCES users(id),
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME,
+    lifted_at DATETIME,
+    lifted_by INTEGER REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_suspensions_user ON user_suspensions(user_id);
+
+-- Dictionnaire du jeu, modifiable par les administrateurs. Un mot signalé
+-- reste accepté comme proposition mais n'est plus tiré comme mot mystère.
+CREATE TABLE IF NOT EXISTS dictionary_words (
+    word TEXT PRIMARY KEY, -- Majuscules sans accent
+    definition TEXT,
+    flagged BOOLEAN NOT NULL DEFAULT 0,
+    flag_reason TEXT,
+    added_by INTEGER REFERENCES users(id), -- NULL pour la liste initiale
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+-- Signalements de mots par les joueurs, traités dans la file de modération
+CREATE TABLE IF NOT EXISTS word_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    word TEXT NOT NULL, -- Majuscules sans accent
+    kind TEXT NOT NULL, -- 'missing' (mot à ajouter) ou 'remove' (mot à retirer)
+    comment TEXT,
+    reporter_id INTEGER NOT NULL REFERENCES users(id),
+    status TEXT NOT NULL DEFAULT 'pending', -- 'pending', 'approved' ou 'rejected'
+    created_at DATETIME NOT NULL,
+    resolved_by INTEGER REFERENCES users(id),
+    resolved_at DATETIME
+);
+
+-- Un joueur ne signale qu'une fois le même mot tant que le signalement est en attente
+CREATE UNIQUE INDEX IF NOT EXISTS idx_word_reports_pending ON word_reports(word, kind, reporter_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_word_reports_status ON word_reports(status, word, kind);
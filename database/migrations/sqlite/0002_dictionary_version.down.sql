@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS dictionary_words_delete;
+DROP TRIGGER IF EXISTS dictionary_words_update;
+DROP TRIGGER IF EXISTS dictionary_words_insert;
+DROP TABLE IF EXISTS dictionary_version;
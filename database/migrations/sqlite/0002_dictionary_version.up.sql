@@ -0,0 +1,24 @@
+-- Version du dictionnaire, incrémentée à chaque modification de
+-- dictionary_words : les serveurs qui partagent la base la surveillent pour
+-- recharger leurs mots.
+CREATE TABLE dictionary_version (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version INTEGER NOT NULL
+);
+
+INSERT INTO dictionary_version (id, version) VALUES (1, 0);
+
+CREATE TRIGGER dictionary_words_insert AFTER INSERT ON dictionary_words
+BEGIN
+    UPDATE dictionary_version SET version = version + 1;
+END;
+
+CREATE TRIGGER dictionary_words_update AFTER UPDATE ON dictionary_words
+BEGIN
+    UPDATE dictionary_version SET version = version + 1;
+END;
+
+CREATE TRIGGER dictionary_words_delete AFTER DELETE ON dictionary_words
+BEGIN
+    UPDATE dictionary_version SET version = version + 1;
+END;
@@ -0,0 +1,26 @@
+package database_test
+
+import (
+	"os"
+	"testing"
+
+	"motzarella/database"
+	"motzarella/database/storetest"
+)
+
+// TestPostgresStore s'exécute sur la base PostgreSQL désignée par
+// TEST_DATABASE_URL, qui doit être vide :
+//
+//	TEST_DATABASE_URL=postgres://localhost/motzarella_test?sslmode=disable go test ./database/
+func TestPostgresStore(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL non défini")
+	}
+	store, err := database.OpenPostgres(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	storetest.RunSQL(t, store)
+}
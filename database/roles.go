@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Permissions vérifiées par les routes d'administration
+const (
+	PermUsersRead          = "users:read"
+	PermUsersManage        = "users:manage"
+	PermRolesManage        = "roles:manage"
+	PermMatchesReadPrivate = "matches:read_private"
+	PermChatModerate       = "chat:moderate"
+	PermDictionaryWrite    = "dictionary:write"
+	PermAuditRead          = "audit:read"
+)
+
+// Rôle portant toutes les permissions, synchronisé avec users.is_admin
+const AdminRole = "admin"
+
+var (
+	ErrUnknownRole = errors.New("unknown role")
+	ErrLastAdmin   = errors.New("cannot remove the last admin")
+)
+
+type Role struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Can indique si l'utilisateur dispose d'une permission. Les permissions
+// sont chargées à l'authentification de la requête.
+func (u *User) Can(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRoles renvoie les rôles existants avec leurs permissions
+func (s *SQLStore) GetRoles() ([]Role, error) {
+	rows, err := s.db.Query(`SELECT roles.id, roles.name, COALESCE(roles.description, ''), COALESCE(role_permissions.permission, '')
+		FROM roles LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+		ORDER BY roles.id, role_permissions.permission`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		var permission string
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &permission); err != nil {
+			return nil, err
+		}
+		if len(roles) == 0 || roles[len(roles)-1].ID != role.ID {
+			role.Permissions = []string{}
+			roles = append(roles, role)
+		}
+		if permission != "" {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission)
+		}
+	}
+	return roles, rows.Err()
+}
+
+// GetUserRoles renvoie les noms des rôles d'un utilisateur
+func (s *SQLStore) GetUserRoles(userID int) ([]string, error) {
+	return s.queryStrings(`SELECT roles.name FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+		WHERE user_roles.user_id = ? ORDER BY roles.name`, userID)
+}
+
+// GetUserPermissions renvoie l'ensemble des permissions accordées par les
+// rôles d'un utilisateur
+func (s *SQLStore) GetUserPermissions(userID int) ([]string, error) {
+	return s.queryStrings(`SELECT DISTINCT role_permissions.permission FROM user_roles
+		JOIN role_permissions ON role_permissions.role_id = user_roles.role_id
+		WHERE user_roles.user_id = ? ORDER BY role_permissions.permission`, userID)
+}
+
+// SetUserRoles remplace les rôles d'un utilisateur et met à jour is_admin.
+// Retirer le rôle admin au dernier administrateur actif est refusé.
+func (s *SQLStore) SetUserRoles(userID int, roles []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	roleIDs := make([]int, 0, len(roles))
+	isAdmin := false
+	for _, name := range roles {
+		var id int
+		err := tx.QueryRow("SELECT id FROM roles WHERE name = ?", name).Scan(&id)
+		if err == sql.ErrNoRows {
+			return ErrUnknownRole
+		}
+		if err != nil {
+			return err
+		}
+		roleIDs = append(roleIDs, id)
+		if name == AdminRole {
+			isAdmin = true
+		}
+	}
+
+	if !isAdmin {
+		if err := checkNotLastAdmin(tx, userID); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec("UPDATE users SET is_admin = ? WHERE id = ?", isAdmin, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, id := range roleIDs {
+		if _, err := tx.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?) ON CONFLICT DO NOTHING", userID, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
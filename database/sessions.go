@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Session struct {
+	ID               string     `json:"id"`
+	UserID           int        `json:"-"`
+	RefreshTokenHash []byte     `json:"-"`
+	UserAgent        string     `json:"user_agent"`
+	IP               string     `json:"ip"`
+	CreatedAt        time.Time  `json:"created_at"`
+	LastUsedAt       time.Time  `json:"last_used_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active indique si la session peut encore être utilisée
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+func (s *SQLStore) CreateSession(session *Session) error {
+	_, err := s.db.Exec("INSERT INTO sessions (id, user_id, refresh_token_hash, user_agent, ip, created_at, last_used_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		session.ID, session.UserID, session.RefreshTokenHash, session.UserAgent, session.IP, session.CreatedAt, session.LastUsedAt, session.ExpiresAt)
+	return err
+}
+
+// GetSession renvoie une session, ou nil si elle n'existe pas
+func (s *SQLStore) GetSession(id string) (*Session, error) {
+	session := &Session{}
+	var userAgent, ip sql.NullString
+	var revokedAt sql.NullTime
+	err := s.db.QueryRow("SELECT id, user_id, refresh_token_hash, user_agent, ip, created_at, last_used_at, expires_at, revoked_at FROM sessions WHERE id = ?", id).
+		Scan(&session.ID, &session.UserID, &session.RefreshTokenHash, &userAgent, &ip, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.UserAgent = userAgent.String
+	session.IP = ip.String
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, nil
+}
+
+// RotateSession remplace le refresh token d'une session encore active, si
+// son refresh token est toujours oldHash. Renvoie sql.ErrNoRows sinon : la
+// session a été révoquée, ou renouvelée entre-temps avec le même token.
+func (s *SQLStore) RotateSession(id string, oldHash, newHash []byte, expiresAt time.Time) error {
+	result, err := s.db.Exec("UPDATE sessions SET refresh_token_hash = ?, last_used_at = ?, expires_at = ? WHERE id = ? AND refresh_token_hash = ? AND revoked_at IS NULL",
+		newHash, time.Now(), expiresAt, id, oldHash)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *SQLStore) RevokeSession(id string) error {
+	_, err := s.db.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id)
+	return err
+}
+
+// RevokeUserSessions révoque toutes les sessions d'un utilisateur
+func (s *SQLStore) RevokeUserSessions(userID int) error {
+	_, err := s.db.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now(), userID)
+	return err
+}
+
+// RevokeOtherSessions révoque toutes les sessions d'un utilisateur sauf une
+func (s *SQLStore) RevokeOtherSessions(userID int, keepID string) error {
+	_, err := s.db.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id != ? AND revoked_at IS NULL", time.Now(), userID, keepID)
+	return err
+}
+
+// GetUserSessions renvoie les sessions actives d'un utilisateur, la plus récente d'abord
+func (s *SQLStore) GetUserSessions(userID int) ([]Session, error) {
+	rows, err := s.db.Query("SELECT id, user_agent, ip, created_at, last_used_at, expires_at FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_used_at DESC",
+		userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session := Session{UserID: userID}
+		var userAgent, ip sql.NullString
+		if err := rows.Scan(&session.ID, &userAgent, &ip, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		session.UserAgent = userAgent.String
+		session.IP = ip.String
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
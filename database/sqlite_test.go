@@ -0,0 +1,73 @@
+package database_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"motzarella/database"
+	"motzarella/database/storetest"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	store, err := database.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	storetest.RunSQL(t, store)
+}
+
+// Une suppression qui échoue en cours de route ne retire rien du compte
+func TestSQLiteDeleteUserIsAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := database.OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.CreateUser("nina", "nina@example.com", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	nina, _ := store.GetUserByUsername("nina")
+	now := time.Now()
+	session := &database.Session{ID: "session-nina", UserID: nina.ID, RefreshTokenHash: []byte("hash"),
+		CreatedAt: now, LastUsedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := store.CreateSession(session); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetPendingMFA(nina.ID, "SECRET"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.EnableMFA(nina.ID, 1, [][]byte{[]byte("code")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// La dernière étape, la suppression de l'utilisateur, échoue
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.Exec("CREATE TRIGGER refuse_user_delete BEFORE DELETE ON users BEGIN SELECT RAISE(ABORT, 'suppression refusée'); END"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.DeleteUser(nina.ID); err == nil {
+		t.Fatal("DeleteUser: erreur attendue")
+	}
+	if sessions, _ := store.GetUserSessions(nina.ID); len(sessions) != 1 {
+		t.Errorf("sessions après un échec: %d, 1 attendue", len(sessions))
+	}
+	if mfa, _ := store.GetUserMFA(nina.ID); mfa == nil || !mfa.Enabled {
+		t.Errorf("double authentification après un échec: %+v", mfa)
+	}
+	if count, _ := store.CountRecoveryCodes(nina.ID); count != 1 {
+		t.Errorf("codes de secours après un échec: %d, 1 attendu", count)
+	}
+}
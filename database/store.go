@@ -0,0 +1,137 @@
+package database
+
+import "time"
+
+// UserStore gère les comptes utilisateurs. Les fonctions GetUserBy*
+// renvoient nil si l'utilisateur n'existe pas.
+type UserStore interface {
+	GetUserByUsername(username string) (*User, error)
+	GetUserByID(id int) (*User, error)
+	GetUserByEmail(email string) (*User, error)
+	CreateUser(username, email, password string) error
+	UpdatePassword(id int, hashedPassword []byte, mustChange bool) error
+	SetEmailVerified(id int, email string) error
+	UpdateUsername(id int, username string) error
+	UpdateEmail(id int, email string) error
+	UpdateAvatar(id int, avatar string) error
+	CountAdmins() (int, error)
+	DeleteUser(id int) error
+
+	// Administration des comptes
+	ListUsers(filter UserFilter) ([]AdminUser, int, error)
+	GetAdminUser(id int) (*AdminUser, error)
+	GetUserStats(user *User) (*UserStats, error)
+	SoftDeleteUser(userID int) error
+	RestoreUser(userID int) error
+}
+
+// IdentityStore rattache des identités OpenID Connect aux comptes
+type IdentityStore interface {
+	GetUserByIdentity(provider, subject string) (*User, error)
+	LinkIdentity(userID int, provider, subject, email string) error
+	CreateExternalUser(username, email string, emailVerified bool, hashedPassword []byte, provider, subject string) (*User, error)
+}
+
+// SuspensionStore gère les suspensions de comptes
+type SuspensionStore interface {
+	GetActiveSuspension(userID int) (*Suspension, error)
+	SuspendUser(userID int, reason string, expiresAt *time.Time, createdBy int) (*Suspension, error)
+	LiftSuspension(userID, liftedBy int) error
+}
+
+// RoleStore gère les rôles des utilisateurs et leurs permissions
+type RoleStore interface {
+	GetRoles() ([]Role, error)
+	GetUserRoles(userID int) ([]string, error)
+	GetUserPermissions(userID int) ([]string, error)
+	SetUserRoles(userID int, roles []string) error
+}
+
+// SessionStore gère les sessions ouvertes à la connexion
+type SessionStore interface {
+	CreateSession(session *Session) error
+	GetSession(id string) (*Session, error)
+	RotateSession(id string, oldHash, newHash []byte, expiresAt time.Time) error
+	RevokeSession(id string) error
+	RevokeUserSessions(userID int) error
+	RevokeOtherSessions(userID int, keepID string) error
+	GetUserSessions(userID int) ([]Session, error)
+}
+
+// MFAStore gère la double authentification et les codes de secours
+type MFAStore interface {
+	GetUserMFA(userID int) (*UserMFA, error)
+	SetPendingMFA(userID int, secret string) error
+	EnableMFA(userID int, step int64, recoveryHashes [][]byte) error
+	ReplaceRecoveryCodes(userID int, recoveryHashes [][]byte) error
+	UseMFAStep(userID int, step int64) (bool, error)
+	UseRecoveryCode(userID int, hash []byte) (bool, error)
+	CountRecoveryCodes(userID int) (int, error)
+	DisableMFA(userID int) error
+}
+
+// EmailTokenStore gère les tokens à usage unique envoyés par email
+type EmailTokenStore interface {
+	CreateEmailToken(t *EmailToken) error
+	ConsumeEmailToken(id, purpose string) (*EmailToken, error)
+}
+
+// LoginFailureStore conserve les échecs de connexion entre deux redémarrages
+type LoginFailureStore interface {
+	SaveLoginFailures(f *LoginFailures) error
+	DeleteLoginFailures(key string) error
+	GetLoginFailures(since time.Time) ([]LoginFailures, error)
+}
+
+// MatchStore conserve l'historique des parties terminées
+type MatchStore interface {
+	SaveMatch(match *Match) error
+	GetMatch(id string) (*Match, error)
+	GetUserMatches(username string, includePrivate bool, limit, offset int) ([]Match, int, error)
+	GetMatchChat(matchID string) ([]MatchChat, error)
+}
+
+// DictionaryStore gère les mots du dictionnaire
+type DictionaryStore interface {
+	CountDictionaryWords() (int, error)
+	DictionaryVersion() (int64, error)
+	ListDictionaryWords(filter DictionaryFilter) ([]DictionaryWord, int, error)
+	GetDictionaryWord(word string) (*DictionaryWord, error)
+	AddDictionaryWord(word, definition string, addedBy *int) error
+	ImportDictionaryWords(words []DictionaryWord, addedBy *int) (int, int, error)
+	RemoveDictionaryWord(word string) error
+	SetWordFlag(word string, flagged bool, reason string) error
+}
+
+// WordReportStore gère les signalements de mots par les joueurs
+type WordReportStore interface {
+	AddWordReport(report *WordReport) error
+	CountPendingReportsByUser(userID int) (int, error)
+	ListWordReportGroups(kind string, limit, offset int) ([]WordReportGroup, int, error)
+	HasPendingWordReports(word, kind string) (bool, error)
+	ResolveWordReports(word, kind, status string, resolvedBy int) (int, error)
+}
+
+// AuditStore conserve le journal d'audit
+type AuditStore interface {
+	AddAuditEntry(entry *AuditEntry) error
+	ListAuditEntries(filter AuditFilter) ([]AuditEntry, int, error)
+}
+
+// Store regroupe l'ensemble des données du serveur. SQLStore l'implémente
+// sur SQLite ou PostgreSQL et MemoryStore en mémoire, pour les tests.
+type Store interface {
+	UserStore
+	IdentityStore
+	SuspensionStore
+	RoleStore
+	SessionStore
+	MFAStore
+	EmailTokenStore
+	LoginFailureStore
+	MatchStore
+	DictionaryStore
+	WordReportStore
+	AuditStore
+	Close() error
+}
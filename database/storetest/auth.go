@@ -0,0 +1,235 @@
+package storetest
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+	"time"
+
+	"motzarella/database"
+)
+
+func checkSessions(t *testing.T, store database.Store) {
+	user := newUser(t, store, "frank")
+	created := now().Add(-time.Hour)
+	session := &database.Session{
+		ID:               "frank-1",
+		UserID:           user.ID,
+		RefreshTokenHash: []byte{0, 1, 2, 255},
+		UserAgent:        "Firefox",
+		IP:               "192.0.2.1",
+		CreatedAt:        created,
+		LastUsedAt:       created,
+		ExpiresAt:        now().Add(time.Hour),
+	}
+	must(t, store.CreateSession(session), "CreateSession")
+
+	got, err := store.GetSession("frank-1")
+	must(t, err, "GetSession")
+	if got == nil || got.UserID != user.ID || !bytes.Equal(got.RefreshTokenHash, session.RefreshTokenHash) ||
+		got.UserAgent != "Firefox" || got.IP != "192.0.2.1" || got.RevokedAt != nil {
+		t.Fatalf("GetSession: %+v", got)
+	}
+	if !sameTime(got.CreatedAt, session.CreatedAt) || !sameTime(got.ExpiresAt, session.ExpiresAt) {
+		t.Errorf("GetSession: dates %v et %v, %v et %v attendues", got.CreatedAt, got.ExpiresAt, session.CreatedAt, session.ExpiresAt)
+	}
+	if got, err := store.GetSession("unknown"); err != nil || got != nil {
+		t.Errorf("GetSession inconnue: %+v, %v, nil attendu", got, err)
+	}
+
+	expiresAt := now().Add(2 * time.Hour)
+	must(t, store.RotateSession("frank-1", session.RefreshTokenHash, []byte{9}, expiresAt), "RotateSession")
+	got, _ = store.GetSession("frank-1")
+	if !bytes.Equal(got.RefreshTokenHash, []byte{9}) || !sameTime(got.ExpiresAt, expiresAt) || !got.LastUsedAt.After(created) {
+		t.Errorf("RotateSession: %+v", got)
+	}
+	// Un token déjà remplacé ne peut pas renouveler la session une seconde fois
+	expectErr(t, store.RotateSession("frank-1", session.RefreshTokenHash, []byte{8}, expiresAt), sql.ErrNoRows, "RotateSession avec l'ancien token")
+	if got, _ := store.GetSession("frank-1"); !bytes.Equal(got.RefreshTokenHash, []byte{9}) {
+		t.Errorf("RotateSession avec l'ancien token: session renouvelée")
+	}
+
+	for _, id := range []string{"frank-2", "frank-3"} {
+		must(t, store.CreateSession(&database.Session{ID: id, UserID: user.ID, RefreshTokenHash: []byte(id),
+			CreatedAt: created, LastUsedAt: created.Add(time.Minute), ExpiresAt: now().Add(time.Hour)}), "CreateSession")
+	}
+	must(t, store.CreateSession(&database.Session{ID: "frank-expired", UserID: user.ID, RefreshTokenHash: []byte{1},
+		CreatedAt: created, LastUsedAt: created, ExpiresAt: now().Add(-time.Minute)}), "CreateSession expirée")
+
+	sessionIDs := func() []string {
+		sessions, err := store.GetUserSessions(user.ID)
+		must(t, err, "GetUserSessions")
+		ids := []string{}
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	// La plus récemment utilisée d'abord, sans les sessions expirées
+	if ids := sessionIDs(); len(ids) != 3 || ids[0] != "frank-1" {
+		t.Errorf("GetUserSessions: %v", ids)
+	}
+
+	must(t, store.RevokeSession("frank-2"), "RevokeSession")
+	if got, _ := store.GetSession("frank-2"); got == nil || got.RevokedAt == nil || got.Active() {
+		t.Errorf("RevokeSession: %+v", got)
+	}
+	// Une session révoquée ne peut plus être renouvelée
+	expectErr(t, store.RotateSession("frank-2", []byte("frank-2"), []byte{7}, expiresAt), sql.ErrNoRows, "RotateSession révoquée")
+	if got, _ := store.GetSession("frank-2"); bytes.Equal(got.RefreshTokenHash, []byte{7}) {
+		t.Errorf("RotateSession: session révoquée renouvelée")
+	}
+
+	must(t, store.RevokeOtherSessions(user.ID, "frank-3"), "RevokeOtherSessions")
+	if ids := sessionIDs(); !equalStrings(ids, []string{"frank-3"}) {
+		t.Errorf("RevokeOtherSessions: %v", ids)
+	}
+	must(t, store.RevokeUserSessions(user.ID), "RevokeUserSessions")
+	if ids := sessionIDs(); len(ids) != 0 {
+		t.Errorf("RevokeUserSessions: %v", ids)
+	}
+}
+
+func checkMFA(t *testing.T, store database.Store) {
+	user := newUser(t, store, "grace")
+	if mfa, err := store.GetUserMFA(user.ID); err != nil || mfa != nil {
+		t.Errorf("GetUserMFA sans secret: %+v, %v, nil attendu", mfa, err)
+	}
+
+	must(t, store.SetPendingMFA(user.ID, "SECRET1"), "SetPendingMFA")
+	mfa, err := store.GetUserMFA(user.ID)
+	must(t, err, "GetUserMFA")
+	if mfa == nil || mfa.Secret != "SECRET1" || mfa.Enabled || mfa.EnabledAt != nil {
+		t.Fatalf("SetPendingMFA: %+v", mfa)
+	}
+
+	must(t, store.EnableMFA(user.ID, 100, [][]byte{[]byte("code-a"), []byte("code-b")}), "EnableMFA")
+	mfa, _ = store.GetUserMFA(user.ID)
+	if !mfa.Enabled || mfa.LastStep != 100 || mfa.EnabledAt == nil {
+		t.Errorf("EnableMFA: %+v", mfa)
+	}
+
+	// Une période déjà utilisée ne peut pas resservir
+	for _, step := range []struct {
+		step int64
+		ok   bool
+	}{{100, false}, {99, false}, {101, true}, {101, false}} {
+		if ok, err := store.UseMFAStep(user.ID, step.step); err != nil || ok != step.ok {
+			t.Errorf("UseMFAStep(%d): %v, %v, %v attendu", step.step, ok, err, step.ok)
+		}
+	}
+
+	if ok, err := store.UseRecoveryCode(user.ID, []byte("code-a")); err != nil || !ok {
+		t.Errorf("UseRecoveryCode: %v, %v", ok, err)
+	}
+	if ok, _ := store.UseRecoveryCode(user.ID, []byte("code-a")); ok {
+		t.Errorf("UseRecoveryCode: code utilisé deux fois")
+	}
+	if ok, _ := store.UseRecoveryCode(user.ID, []byte("unknown")); ok {
+		t.Errorf("UseRecoveryCode: code inconnu accepté")
+	}
+	if count, err := store.CountRecoveryCodes(user.ID); err != nil || count != 1 {
+		t.Errorf("CountRecoveryCodes: %d, %v, 1 attendu", count, err)
+	}
+
+	must(t, store.ReplaceRecoveryCodes(user.ID, [][]byte{[]byte("c1"), []byte("c2"), []byte("c3")}), "ReplaceRecoveryCodes")
+	if count, _ := store.CountRecoveryCodes(user.ID); count != 3 {
+		t.Errorf("ReplaceRecoveryCodes: %d codes, 3 attendus", count)
+	}
+	if ok, _ := store.UseRecoveryCode(user.ID, []byte("code-b")); ok {
+		t.Errorf("ReplaceRecoveryCodes: ancien code accepté")
+	}
+
+	// Un nouveau secret désactive la double authentification jusqu'à sa vérification
+	must(t, store.SetPendingMFA(user.ID, "SECRET2"), "SetPendingMFA")
+	mfa, _ = store.GetUserMFA(user.ID)
+	if mfa.Secret != "SECRET2" || mfa.Enabled || mfa.LastStep != 0 || mfa.EnabledAt != nil {
+		t.Errorf("SetPendingMFA sur un secret actif: %+v", mfa)
+	}
+
+	must(t, store.DisableMFA(user.ID), "DisableMFA")
+	if mfa, _ := store.GetUserMFA(user.ID); mfa != nil {
+		t.Errorf("DisableMFA: %+v", mfa)
+	}
+	if count, _ := store.CountRecoveryCodes(user.ID); count != 0 {
+		t.Errorf("DisableMFA: %d codes de secours restants", count)
+	}
+}
+
+func checkEmailTokens(t *testing.T, store database.Store) {
+	user := newUser(t, store, "heidi")
+	token := func(id, purpose string, ttl time.Duration) *database.EmailToken {
+		created := now()
+		emailToken := &database.EmailToken{ID: id, UserID: user.ID, Purpose: purpose, Email: user.Email,
+			CreatedAt: created, ExpiresAt: created.Add(ttl)}
+		must(t, store.CreateEmailToken(emailToken), "CreateEmailToken "+id)
+		return emailToken
+	}
+
+	token("heidi-1", "verify_email", time.Hour)
+	second := token("heidi-2", "verify_email", time.Hour)
+	token("heidi-reset", "password_reset", time.Hour)
+	token("heidi-expired", "change_email", -time.Minute)
+
+	// Un nouveau token invalide les précédents de même usage
+	if got, err := store.ConsumeEmailToken("heidi-1", "verify_email"); err != nil || got != nil {
+		t.Errorf("ConsumeEmailToken remplacé: %+v, %v, nil attendu", got, err)
+	}
+	if got, _ := store.ConsumeEmailToken("heidi-2", "password_reset"); got != nil {
+		t.Errorf("ConsumeEmailToken d'un autre usage: %+v, nil attendu", got)
+	}
+	got, err := store.ConsumeEmailToken("heidi-2", "verify_email")
+	must(t, err, "ConsumeEmailToken")
+	if got == nil || got.UserID != user.ID || got.Email != user.Email || got.UsedAt == nil || !sameTime(got.ExpiresAt, second.ExpiresAt) {
+		t.Errorf("ConsumeEmailToken: %+v", got)
+	}
+	if got, _ := store.ConsumeEmailToken("heidi-2", "verify_email"); got != nil {
+		t.Errorf("ConsumeEmailToken: token utilisé deux fois")
+	}
+	if got, _ := store.ConsumeEmailToken("heidi-expired", "change_email"); got != nil {
+		t.Errorf("ConsumeEmailToken: token expiré accepté")
+	}
+	if got, _ := store.ConsumeEmailToken("unknown", "verify_email"); got != nil {
+		t.Errorf("ConsumeEmailToken: token inconnu accepté")
+	}
+	if got, _ := store.ConsumeEmailToken("heidi-reset", "password_reset"); got == nil {
+		t.Errorf("ConsumeEmailToken: token d'un autre usage invalidé")
+	}
+}
+
+func checkLoginFailures(t *testing.T, store database.Store) {
+	current := now()
+	since := current.Add(-time.Hour)
+	must(t, store.SaveLoginFailures(&database.LoginFailures{Key: "user:recent", Failures: 1, LastFailure: current}), "SaveLoginFailures")
+	must(t, store.SaveLoginFailures(&database.LoginFailures{Key: "user:recent", Failures: 2, LastFailure: current}), "SaveLoginFailures existant")
+	must(t, store.SaveLoginFailures(&database.LoginFailures{Key: "user:old", Failures: 3, LastFailure: since.Add(-time.Minute)}), "SaveLoginFailures")
+	must(t, store.SaveLoginFailures(&database.LoginFailures{Key: "ip:locked", Failures: 5, LastFailure: since.Add(-time.Minute),
+		LockedUntil: current.Add(time.Minute)}), "SaveLoginFailures verrouillé")
+
+	byKey := func() map[string]database.LoginFailures {
+		list, err := store.GetLoginFailures(since)
+		must(t, err, "GetLoginFailures")
+		m := make(map[string]database.LoginFailures)
+		for _, f := range list {
+			m[f.Key] = f
+		}
+		return m
+	}
+
+	// Les échecs anciens sont oubliés, sauf si le verrouillage court encore
+	failures := byKey()
+	if f, ok := failures["user:recent"]; !ok || f.Failures != 2 || !sameTime(f.LastFailure, current) || !f.LockedUntil.IsZero() {
+		t.Errorf("GetLoginFailures: user:recent %+v", f)
+	}
+	if f, ok := failures["ip:locked"]; !ok || !sameTime(f.LockedUntil, current.Add(time.Minute)) {
+		t.Errorf("GetLoginFailures: ip:locked %+v", f)
+	}
+	if _, ok := failures["user:old"]; ok || len(failures) != 2 {
+		t.Errorf("GetLoginFailures: %v, échecs anciens conservés", failures)
+	}
+
+	must(t, store.DeleteLoginFailures("user:recent"), "DeleteLoginFailures")
+	if _, ok := byKey()["user:recent"]; ok {
+		t.Errorf("DeleteLoginFailures: échecs conservés")
+	}
+}
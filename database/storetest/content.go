@@ -0,0 +1,336 @@
+package storetest
+
+import (
+	"database/sql"
+	"sort"
+	"testing"
+	"time"
+
+	"motzarella/database"
+)
+
+func checkMatches(t *testing.T, store database.Store) {
+	user := newUser(t, store, "ivan")
+	start := now().Add(-time.Hour)
+
+	match := &database.Match{
+		ID:               "ivan-match-1",
+		Word:             "POMME",
+		OpponentProgress: true,
+		WinnerID:         "p1",
+		StartedAt:        start,
+		EndedAt:          start.Add(5 * time.Minute),
+		Players:          []database.MatchPlayer{{ID: "p1", Username: "ivan"}, {ID: "p2"}},
+		Guesses: []database.MatchGuess{
+			{PlayerID: "p2", Guess: "PORTE", Result: []string{"correct", "correct", "absent", "absent", "absent"}, CreatedAt: start.Add(time.Minute)},
+			{PlayerID: "p1", Guess: "POMME", Result: []string{"correct", "correct", "correct", "correct", "correct"}, CreatedAt: start.Add(2 * time.Minute)},
+		},
+		Chat: []database.MatchChat{
+			{PlayerID: "p1", Message: "bonjour", CreatedAt: start.Add(30 * time.Second)},
+			{PlayerID: "p2", Message: "***", Original: "zut", Emote: "angry", CreatedAt: start.Add(90 * time.Second)},
+		},
+	}
+	must(t, store.SaveMatch(match), "SaveMatch")
+	must(t, store.SaveMatch(&database.Match{ID: "ivan-match-2", Word: "POIRE", Private: true, StartedAt: start.Add(10 * time.Minute),
+		EndedAt: start.Add(15 * time.Minute), Players: []database.MatchPlayer{{ID: "p1", Username: "ivan"}}}), "SaveMatch privée")
+	must(t, store.SaveMatch(&database.Match{ID: "ivan-match-3", Word: "PECHE", StartedAt: start.Add(20 * time.Minute),
+		EndedAt: start.Add(25 * time.Minute), Players: []database.MatchPlayer{{ID: "p1", Username: "ivan"}}}), "SaveMatch")
+
+	got, err := store.GetMatch("ivan-match-1")
+	must(t, err, "GetMatch")
+	if got == nil || got.Word != "POMME" || !got.OpponentProgress || got.Private || got.WinnerID != "p1" ||
+		!sameTime(got.StartedAt, match.StartedAt) || !sameTime(got.EndedAt, match.EndedAt) {
+		t.Fatalf("GetMatch: %+v", got)
+	}
+	sort.Slice(got.Players, func(i, j int) bool { return got.Players[i].ID < got.Players[j].ID })
+	if len(got.Players) != 2 || got.Players[0] != match.Players[0] || got.Players[1] != match.Players[1] {
+		t.Errorf("GetMatch: joueurs %+v", got.Players)
+	}
+	if len(got.Guesses) != 2 || got.Guesses[0].Guess != "PORTE" || got.Guesses[1].PlayerID != "p1" ||
+		!equalStrings(got.Guesses[0].Result, match.Guesses[0].Result) || !sameTime(got.Guesses[1].CreatedAt, match.Guesses[1].CreatedAt) {
+		t.Errorf("GetMatch: tentatives %+v", got.Guesses)
+	}
+	if got, err := store.GetMatch("unknown"); err != nil || got != nil {
+		t.Errorf("GetMatch inconnue: %+v, %v, nil attendu", got, err)
+	}
+
+	chat, err := store.GetMatchChat("ivan-match-1")
+	must(t, err, "GetMatchChat")
+	if len(chat) != 2 || chat[0].Username != "ivan" || chat[0].Message != "bonjour" ||
+		chat[1].Username != "" || chat[1].Original != "zut" || chat[1].Emote != "angry" {
+		t.Errorf("GetMatchChat: %+v", chat)
+	}
+	if chat, err := store.GetMatchChat("ivan-match-3"); err != nil || len(chat) != 0 {
+		t.Errorf("GetMatchChat sans message: %+v, %v", chat, err)
+	}
+
+	matchIDs := func(includePrivate bool, limit, offset int) ([]string, int) {
+		matches, total, err := store.GetUserMatches("ivan", includePrivate, limit, offset)
+		must(t, err, "GetUserMatches")
+		ids := []string{}
+		for _, m := range matches {
+			ids = append(ids, m.ID)
+			if len(m.Guesses) != 0 {
+				t.Errorf("GetUserMatches: tentatives de %s chargées", m.ID)
+			}
+		}
+		return ids, total
+	}
+	if ids, total := matchIDs(false, 10, 0); !equalStrings(ids, []string{"ivan-match-3", "ivan-match-1"}) || total != 2 {
+		t.Errorf("GetUserMatches publiques: %v (%d)", ids, total)
+	}
+	if ids, total := matchIDs(true, 1, 1); !equalStrings(ids, []string{"ivan-match-2"}) || total != 3 {
+		t.Errorf("GetUserMatches page: %v (%d)", ids, total)
+	}
+
+	stats, err := store.GetUserStats(user)
+	must(t, err, "GetUserStats")
+	if stats.MatchesPlayed != 3 || stats.MatchesWon != 1 || stats.Guesses != 1 || stats.ChatMessages != 1 {
+		t.Errorf("GetUserStats: %+v", stats)
+	}
+
+	// Renommer un joueur renomme son historique
+	must(t, store.UpdateUsername(user.ID, "ivan-renamed"), "UpdateUsername")
+	if matches, total, err := store.GetUserMatches("ivan-renamed", true, 10, 0); err != nil || total != 3 || len(matches) != 3 {
+		t.Errorf("GetUserMatches après renommage: %d parties, %v", total, err)
+	}
+}
+
+func checkDictionary(t *testing.T, store database.Store) {
+	editor := newUser(t, store, "judy")
+	editorID := editor.ID
+
+	// La version change à chaque modification, pour que les autres serveurs
+	// rechargent leurs mots
+	version, err := store.DictionaryVersion()
+	must(t, err, "DictionaryVersion")
+	expectNewVersion := func(operation string) {
+		current, err := store.DictionaryVersion()
+		must(t, err, "DictionaryVersion")
+		if current == version {
+			t.Errorf("DictionaryVersion inchangée après %s", operation)
+		}
+		version = current
+	}
+
+	must(t, store.AddDictionaryWord("ABRI", "Lieu où l'on s'abrite", &editorID), "AddDictionaryWord")
+	expectNewVersion("AddDictionaryWord")
+	expectErr(t, store.AddDictionaryWord("ABRI", "", nil), database.ErrWordExists, "AddDictionaryWord existant")
+	word, err := store.GetDictionaryWord("ABRI")
+	must(t, err, "GetDictionaryWord")
+	if word == nil || word.Definition != "Lieu où l'on s'abrite" || word.Flagged || word.AddedBy == nil || *word.AddedBy != editorID {
+		t.Fatalf("GetDictionaryWord: %+v", word)
+	}
+	if word, err := store.GetDictionaryWord("NOPE"); err != nil || word != nil {
+		t.Errorf("GetDictionaryWord inconnu: %+v, %v, nil attendu", word, err)
+	}
+
+	added, updated, err := store.ImportDictionaryWords([]database.DictionaryWord{
+		{Word: "ABRI", Definition: "Nouvelle définition"},
+		{Word: "ACIER"},
+		{Word: "AB_C"},
+		{Word: "BALLE", Definition: "Objet rond"},
+	}, nil)
+	must(t, err, "ImportDictionaryWords")
+	if added != 3 || updated != 1 {
+		t.Errorf("ImportDictionaryWords: %d ajoutés, %d mis à jour, 3 et 1 attendus", added, updated)
+	}
+	expectNewVersion("ImportDictionaryWords")
+	// Sans définition, un mot existant est laissé tel quel
+	if _, updated, _ := store.ImportDictionaryWords([]database.DictionaryWord{{Word: "ABRI"}, {Word: "BALLE", Definition: "Objet rond"}}, nil); updated != 0 {
+		t.Errorf("ImportDictionaryWords sans changement: %d mis à jour", updated)
+	}
+	if count, err := store.CountDictionaryWords(); err != nil || count != 4 {
+		t.Errorf("CountDictionaryWords: %d, %v, 4 attendu", count, err)
+	}
+
+	list := func(filter database.DictionaryFilter) ([]string, int) {
+		words, total, err := store.ListDictionaryWords(filter)
+		must(t, err, "ListDictionaryWords")
+		names := []string{}
+		for _, w := range words {
+			names = append(names, w.Word)
+		}
+		return names, total
+	}
+	if words, total := list(database.DictionaryFilter{}); !equalStrings(words, []string{"ABRI", "AB_C", "ACIER", "BALLE"}) || total != 4 {
+		t.Errorf("ListDictionaryWords sans limite: %v (%d)", words, total)
+	}
+	if words, total := list(database.DictionaryFilter{Search: "AB_", Limit: 10}); !equalStrings(words, []string{"AB_C"}) || total != 1 {
+		t.Errorf("ListDictionaryWords recherche: %v (%d)", words, total)
+	}
+	if words, total := list(database.DictionaryFilter{Limit: 2, Offset: 1}); !equalStrings(words, []string{"AB_C", "ACIER"}) || total != 4 {
+		t.Errorf("ListDictionaryWords page: %v (%d)", words, total)
+	}
+	if words, _ := list(database.DictionaryFilter{Offset: 3}); !equalStrings(words, []string{"BALLE"}) {
+		t.Errorf("ListDictionaryWords sans limite à partir de 3: %v", words)
+	}
+
+	must(t, store.SetWordFlag("ACIER", true, "Trop rare"), "SetWordFlag")
+	expectNewVersion("SetWordFlag")
+	flagged := true
+	if words, total := list(database.DictionaryFilter{Flagged: &flagged}); !equalStrings(words, []string{"ACIER"}) || total != 1 {
+		t.Errorf("ListDictionaryWords signalés: %v (%d)", words, total)
+	}
+	if word, _ := store.GetDictionaryWord("ACIER"); word == nil || !word.Flagged || word.FlagReason != "Trop rare" {
+		t.Errorf("SetWordFlag: %+v", word)
+	}
+	must(t, store.SetWordFlag("ACIER", false, "ignoré"), "SetWordFlag retrait")
+	if word, _ := store.GetDictionaryWord("ACIER"); word == nil || word.Flagged || word.FlagReason != "" {
+		t.Errorf("SetWordFlag retrait: %+v", word)
+	}
+	expectErr(t, store.SetWordFlag("NOPE", true, ""), sql.ErrNoRows, "SetWordFlag inconnu")
+	expectErr(t, store.RemoveDictionaryWord("NOPE"), sql.ErrNoRows, "RemoveDictionaryWord inconnu")
+
+	// Il reste toujours au moins un mot jouable
+	must(t, store.RemoveDictionaryWord("AB_C"), "RemoveDictionaryWord")
+	expectNewVersion("RemoveDictionaryWord")
+	must(t, store.RemoveDictionaryWord("ACIER"), "RemoveDictionaryWord")
+	must(t, store.SetWordFlag("ABRI", true, "test"), "SetWordFlag")
+	expectErr(t, store.SetWordFlag("BALLE", true, "test"), database.ErrLastPlayableWord, "SetWordFlag du dernier mot jouable")
+	expectErr(t, store.RemoveDictionaryWord("BALLE"), database.ErrLastPlayableWord, "RemoveDictionaryWord du dernier mot jouable")
+	must(t, store.RemoveDictionaryWord("ABRI"), "RemoveDictionaryWord d'un mot signalé")
+	if count, _ := store.CountDictionaryWords(); count != 1 {
+		t.Errorf("CountDictionaryWords: %d, 1 attendu", count)
+	}
+}
+
+func checkWordReports(t *testing.T, store database.Store) {
+	kim := newUser(t, store, "kim")
+	leo := newUser(t, store, "leo")
+	must(t, store.AddDictionaryWord("ZEBRE", "", nil), "AddDictionaryWord")
+
+	report := func(user *database.User, word, kind string) (*database.WordReport, error) {
+		r := &database.WordReport{Word: word, Kind: kind, Comment: "par " + user.Username, ReporterID: user.ID}
+		return r, store.AddWordReport(r)
+	}
+	first, err := report(kim, "ZEBRE", database.ReportRemove)
+	must(t, err, "AddWordReport")
+	if first.ID <= 0 || first.CreatedAt.IsZero() {
+		t.Errorf("AddWordReport: %+v", first)
+	}
+	_, err = report(kim, "ZEBRE", database.ReportRemove)
+	expectErr(t, err, database.ErrAlreadyReported, "AddWordReport en double")
+	_, err = report(kim, "ZOZO", database.ReportMissing)
+	must(t, err, "AddWordReport")
+	last, err := report(leo, "ZEBRE", database.ReportRemove)
+	must(t, err, "AddWordReport")
+	if last.ID <= first.ID {
+		t.Errorf("AddWordReport: identifiant %d après %d", last.ID, first.ID)
+	}
+
+	if count, err := store.CountPendingReportsByUser(kim.ID); err != nil || count != 2 {
+		t.Errorf("CountPendingReportsByUser: %d, %v, 2 attendu", count, err)
+	}
+
+	groups, total, err := store.ListWordReportGroups("", 10, 0)
+	must(t, err, "ListWordReportGroups")
+	if total != 2 || len(groups) != 2 {
+		t.Fatalf("ListWordReportGroups: %+v (%d)", groups, total)
+	}
+	// Le mot le plus signalé d'abord, avec ses signalements les plus récents
+	zebre := groups[0]
+	if zebre.Word != "ZEBRE" || zebre.Kind != database.ReportRemove || zebre.Reports != 2 || !zebre.InDictionary ||
+		len(zebre.Latest) != 2 || zebre.Latest[0].Reporter != "leo" || zebre.Latest[1].Comment != "par kim" {
+		t.Errorf("ListWordReportGroups: %+v", zebre)
+	}
+	if groups[1].Word != "ZOZO" || groups[1].InDictionary {
+		t.Errorf("ListWordReportGroups: %+v", groups[1])
+	}
+	if groups, total, _ := store.ListWordReportGroups(database.ReportMissing, 10, 0); total != 1 || len(groups) != 1 || groups[0].Word != "ZOZO" {
+		t.Errorf("ListWordReportGroups par type: %+v (%d)", groups, total)
+	}
+	if groups, total, _ := store.ListWordReportGroups("", 1, 1); total != 2 || len(groups) != 1 || groups[0].Word != "ZOZO" {
+		t.Errorf("ListWordReportGroups page: %+v (%d)", groups, total)
+	}
+
+	if pending, err := store.HasPendingWordReports("ZEBRE", database.ReportRemove); err != nil || !pending {
+		t.Errorf("HasPendingWordReports: %v, %v", pending, err)
+	}
+	if pending, _ := store.HasPendingWordReports("ZEBRE", database.ReportMissing); pending {
+		t.Errorf("HasPendingWordReports d'un autre type: vrai")
+	}
+
+	resolved, err := store.ResolveWordReports("ZEBRE", database.ReportRemove, database.ReportRejected, leo.ID)
+	must(t, err, "ResolveWordReports")
+	if resolved != 2 {
+		t.Errorf("ResolveWordReports: %d, 2 attendus", resolved)
+	}
+	_, err = store.ResolveWordReports("ZEBRE", database.ReportRemove, database.ReportRejected, leo.ID)
+	expectErr(t, err, sql.ErrNoRows, "ResolveWordReports sans signalement")
+	if pending, _ := store.HasPendingWordReports("ZEBRE", database.ReportRemove); pending {
+		t.Errorf("HasPendingWordReports après résolution: vrai")
+	}
+
+	// Une fois traité, le mot peut être signalé à nouveau
+	_, err = report(kim, "ZEBRE", database.ReportRemove)
+	must(t, err, "AddWordReport après résolution")
+}
+
+func checkAudit(t *testing.T, store database.Store) {
+	actorID := 7
+	base := now().Add(-time.Hour)
+	entries := []*database.AuditEntry{
+		{Action: "user.suspend", ActorID: &actorID, Actor: "mallory", Target: "user:a", IP: "192.0.2.7",
+			Details: map[string]interface{}{"reason": "spam"}, CreatedAt: base},
+		{Action: "user.restore", Actor: "mallory", Target: "user:a", CreatedAt: base.Add(time.Minute)},
+		{Action: "dictionary.add", Actor: "niaj", Target: "word:ABRI", CreatedAt: base.Add(2 * time.Minute)},
+		{Action: "login.failure", Target: "user:b", CreatedAt: base.Add(3 * time.Minute)},
+	}
+	for _, entry := range entries {
+		must(t, store.AddAuditEntry(entry), "AddAuditEntry")
+	}
+	if entries[0].ID <= 0 || entries[3].ID <= entries[0].ID {
+		t.Errorf("AddAuditEntry: identifiants %d et %d", entries[0].ID, entries[3].ID)
+	}
+
+	list := func(filter database.AuditFilter) ([]database.AuditEntry, int) {
+		if filter.Limit == 0 {
+			filter.Limit = 10
+		}
+		if filter.Since == nil {
+			filter.Since = &base
+		}
+		list, total, err := store.ListAuditEntries(filter)
+		must(t, err, "ListAuditEntries")
+		return list, total
+	}
+	actions := func(list []database.AuditEntry) []string {
+		names := []string{}
+		for _, entry := range list {
+			names = append(names, entry.Action)
+		}
+		return names
+	}
+
+	// Du plus récent au plus ancien
+	all, total := list(database.AuditFilter{})
+	if expected := []string{"login.failure", "dictionary.add", "user.restore", "user.suspend"}; !equalStrings(actions(all), expected) || total != 4 {
+		t.Fatalf("ListAuditEntries: %v (%d)", actions(all), total)
+	}
+	first := all[3]
+	if first.ActorID == nil || *first.ActorID != actorID || first.IP != "192.0.2.7" || first.Details["reason"] != "spam" ||
+		!sameTime(first.CreatedAt, base) {
+		t.Errorf("ListAuditEntries: %+v", first)
+	}
+	if all[0].ActorID != nil || all[0].Actor != "" || all[0].Details != nil {
+		t.Errorf("ListAuditEntries sans auteur: %+v", all[0])
+	}
+
+	if got, total := list(database.AuditFilter{Action: "user.*"}); !equalStrings(actions(got), []string{"user.restore", "user.suspend"}) || total != 2 {
+		t.Errorf("ListAuditEntries par préfixe: %v (%d)", actions(got), total)
+	}
+	if got, _ := list(database.AuditFilter{Action: "user"}); len(got) != 0 {
+		t.Errorf("ListAuditEntries par action exacte: %v", actions(got))
+	}
+	if got, _ := list(database.AuditFilter{Actor: "mallory", Target: "user:a", IP: "192.0.2.7"}); !equalStrings(actions(got), []string{"user.suspend"}) {
+		t.Errorf("ListAuditEntries par auteur, cible et adresse: %v", actions(got))
+	}
+	since, until := base.Add(time.Minute), base.Add(3*time.Minute)
+	if got, _ := list(database.AuditFilter{Since: &since, Until: &until}); !equalStrings(actions(got), []string{"dictionary.add", "user.restore"}) {
+		t.Errorf("ListAuditEntries par période: %v", actions(got))
+	}
+	if got, total := list(database.AuditFilter{Limit: 2, Offset: 1}); !equalStrings(actions(got), []string{"dictionary.add", "user.restore"}) || total != 4 {
+		t.Errorf("ListAuditEntries page: %v (%d)", actions(got), total)
+	}
+}
@@ -0,0 +1,122 @@
+// Package storetest vérifie qu'une implémentation de database.Store se
+// comporte comme le reste du serveur l'attend : mêmes résultats, mêmes
+// erreurs et même ordre, quelle que soit la base. Les mêmes vérifications
+// s'exécutent sur MemoryStore, SQLite et PostgreSQL (voir les tests du
+// paquet database).
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"motzarella/database"
+)
+
+// checks regroupe toutes les vérifications, dans leur ordre d'exécution
+var checks = []struct {
+	name string
+	run  func(t *testing.T, store database.Store)
+}{
+	{"users", checkUsers},
+	{"admins", checkAdmins},
+	{"identities", checkIdentities},
+	{"suspensions", checkSuspensions},
+	{"user-list", checkUserList},
+	{"sessions", checkSessions},
+	{"mfa", checkMFA},
+	{"email-tokens", checkEmailTokens},
+	{"login-failures", checkLoginFailures},
+	{"matches", checkMatches},
+	{"dictionary", checkDictionary},
+	{"word-reports", checkWordReports},
+	{"audit", checkAudit},
+	{"delete-user", checkDeleteUser},
+}
+
+// Run exécute toutes les vérifications sur store, chacune dans un sous-test.
+// store doit être vide : schéma à jour, rôles initiaux, aucun utilisateur ni
+// mot du dictionnaire. Les vérifications partagent ses données et y laissent
+// les leurs.
+func Run(t *testing.T, store database.Store) {
+	for _, check := range checks {
+		check := check
+		t.Run(check.name, func(t *testing.T) {
+			check.run(t, store)
+		})
+	}
+}
+
+// RunSQL applique les migrations à une base vide, tout juste ouverte, y
+// exécute les vérifications puis annule les migrations pour la laisser vide.
+// Une base dont le schéma existe déjà est refusée : ses données seraient
+// perdues.
+func RunSQL(t *testing.T, store *database.SQLStore) {
+	states, err := store.MigrationStatus()
+	must(t, err, "MigrationStatus")
+	for _, state := range states {
+		if state.AppliedAt != nil {
+			t.Fatalf("la base doit être vide : migration %04d_%s déjà appliquée", state.Version, state.Name)
+		}
+	}
+
+	applied, err := store.Migrate()
+	must(t, err, "Migrate")
+	defer func() {
+		if _, err := store.Rollback(len(applied)); err != nil {
+			t.Errorf("Rollback: %v", err)
+		}
+	}()
+	Run(t, store)
+}
+
+// must interrompt la vérification si une opération a échoué
+func must(t *testing.T, err error, operation string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", operation, err)
+	}
+}
+
+// expectErr vérifie qu'une opération renvoie l'erreur attendue
+func expectErr(t *testing.T, err, expected error, operation string) {
+	t.Helper()
+	if err != expected {
+		t.Errorf("%s: erreur %v, %v attendue", operation, err, expected)
+	}
+}
+
+// newUser crée un utilisateur et le relit
+func newUser(t *testing.T, store database.Store, username string) *database.User {
+	t.Helper()
+	must(t, store.CreateUser(username, username+"@example.com", "hash-"+username), "CreateUser "+username)
+	user, err := store.GetUserByUsername(username)
+	must(t, err, "GetUserByUsername "+username)
+	if user == nil {
+		t.Fatalf("GetUserByUsername %s: utilisateur introuvable après CreateUser", username)
+	}
+	return user
+}
+
+// sameTime compare deux dates à la milliseconde près : les bases ne
+// conservent pas toutes les nanosecondes
+func sameTime(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -time.Millisecond && d < time.Millisecond
+}
+
+// now renvoie l'heure courante à la microseconde, précision de PostgreSQL
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,331 @@
+package storetest
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"motzarella/database"
+)
+
+func checkUsers(t *testing.T, store database.Store) {
+	user := newUser(t, store, "alice")
+	if user.ID <= 0 || user.Email != "alice@example.com" || string(user.Password) != "hash-alice" {
+		t.Errorf("GetUserByUsername: %+v", user)
+	}
+	if user.IsAdmin || user.MustChangePassword || user.EmailVerified || user.DeletedAt != nil {
+		t.Errorf("nouvel utilisateur: drapeaux inattendus %+v", user)
+	}
+	if user.CreatedAt == "" {
+		t.Errorf("nouvel utilisateur: created_at vide")
+	}
+
+	if got, err := store.GetUserByID(user.ID); err != nil || got == nil || got.Username != "alice" {
+		t.Errorf("GetUserByID: %+v, %v", got, err)
+	}
+	if got, err := store.GetUserByEmail("alice@example.com"); err != nil || got == nil || got.ID != user.ID {
+		t.Errorf("GetUserByEmail: %+v, %v", got, err)
+	}
+	if got, err := store.GetUserByUsername("nobody"); err != nil || got != nil {
+		t.Errorf("GetUserByUsername inconnu: %+v, %v, nil attendu", got, err)
+	}
+	if got, err := store.GetUserByID(-1); err != nil || got != nil {
+		t.Errorf("GetUserByID inconnu: %+v, %v, nil attendu", got, err)
+	}
+	if err := store.CreateUser("alice", "other@example.com", "hash"); err == nil {
+		t.Errorf("CreateUser avec un pseudo pris: erreur attendue")
+	}
+	if err := store.CreateUser("alice2", "alice@example.com", "hash"); err == nil {
+		t.Errorf("CreateUser avec un email pris: erreur attendue")
+	}
+
+	must(t, store.UpdatePassword(user.ID, []byte("new-hash"), true), "UpdatePassword")
+	user, _ = store.GetUserByID(user.ID)
+	if string(user.Password) != "new-hash" || !user.MustChangePassword {
+		t.Errorf("UpdatePassword: %q, must_change_password %v", user.Password, user.MustChangePassword)
+	}
+
+	// La vérification ne vaut que pour l'adresse à laquelle le lien a été envoyé
+	must(t, store.SetEmailVerified(user.ID, "old@example.com"), "SetEmailVerified")
+	if user, _ = store.GetUserByID(user.ID); user.EmailVerified {
+		t.Errorf("SetEmailVerified avec une autre adresse: adresse vérifiée")
+	}
+	must(t, store.SetEmailVerified(user.ID, "alice@example.com"), "SetEmailVerified")
+	if user, _ = store.GetUserByID(user.ID); !user.EmailVerified {
+		t.Errorf("SetEmailVerified: adresse non vérifiée")
+	}
+
+	newUser(t, store, "bob")
+	expectErr(t, store.UpdateUsername(user.ID, "bob"), database.ErrUsernameTaken, "UpdateUsername vers un pseudo pris")
+	expectErr(t, store.UpdateUsername(-1, "ghost"), sql.ErrNoRows, "UpdateUsername d'un inconnu")
+	must(t, store.UpdateUsername(user.ID, "alicia"), "UpdateUsername")
+	if got, _ := store.GetUserByUsername("alicia"); got == nil || got.ID != user.ID {
+		t.Errorf("UpdateUsername: utilisateur introuvable sous son nouveau pseudo")
+	}
+
+	expectErr(t, store.UpdateEmail(user.ID, "bob@example.com"), database.ErrEmailTaken, "UpdateEmail vers une adresse prise")
+	must(t, store.UpdateEmail(user.ID, "alicia@example.com"), "UpdateEmail")
+	if user, _ = store.GetUserByID(user.ID); user.Email != "alicia@example.com" || user.EmailVerified {
+		t.Errorf("UpdateEmail: %s, vérifiée %v", user.Email, user.EmailVerified)
+	}
+
+	must(t, store.UpdateAvatar(user.ID, "avatar.png"), "UpdateAvatar")
+	if user, _ = store.GetUserByID(user.ID); user.Avatar != "avatar.png" {
+		t.Errorf("UpdateAvatar: %q", user.Avatar)
+	}
+	must(t, store.UpdateAvatar(user.ID, ""), "UpdateAvatar vide")
+	if user, _ = store.GetUserByID(user.ID); user.Avatar != "" {
+		t.Errorf("UpdateAvatar vide: %q", user.Avatar)
+	}
+}
+
+// checkAdmins s'exécute sur une base sans administrateur
+func checkAdmins(t *testing.T, store database.Store) {
+	roles, err := store.GetRoles()
+	must(t, err, "GetRoles")
+	var names []string
+	for _, role := range roles {
+		names = append(names, role.Name)
+		if role.Name == database.AdminRole && len(role.Permissions) != 7 {
+			t.Errorf("GetRoles: permissions du rôle admin %v", role.Permissions)
+		}
+	}
+	if !equalStrings(names, []string{database.AdminRole, "moderator", "dictionary_editor"}) {
+		t.Errorf("GetRoles: %v", names)
+	}
+
+	first := newUser(t, store, "admin-one")
+	expectErr(t, store.SetUserRoles(first.ID, []string{"overlord"}), database.ErrUnknownRole, "SetUserRoles inconnu")
+	expectErr(t, store.SetUserRoles(-1, nil), sql.ErrNoRows, "SetUserRoles d'un inconnu")
+	must(t, store.SetUserRoles(first.ID, []string{database.AdminRole}), "SetUserRoles admin")
+	if first, _ = store.GetUserByID(first.ID); !first.IsAdmin {
+		t.Errorf("SetUserRoles admin: is_admin faux")
+	}
+	if admins, err := store.CountAdmins(); err != nil || admins != 1 {
+		t.Errorf("CountAdmins: %d, %v, 1 attendu", admins, err)
+	}
+
+	// Le dernier administrateur actif ne peut pas être retiré
+	expectErr(t, store.SetUserRoles(first.ID, nil), database.ErrLastAdmin, "SetUserRoles sur le dernier admin")
+	expectErr(t, store.SoftDeleteUser(first.ID), database.ErrLastAdmin, "SoftDeleteUser du dernier admin")
+	_, err = store.SuspendUser(first.ID, "test", nil, first.ID)
+	expectErr(t, err, database.ErrLastAdmin, "SuspendUser du dernier admin")
+	if err := store.DeleteUser(first.ID); err == nil {
+		t.Errorf("DeleteUser d'un admin: erreur attendue")
+	}
+
+	second := newUser(t, store, "admin-two")
+	must(t, store.SetUserRoles(second.ID, []string{"moderator", database.AdminRole}), "SetUserRoles")
+	must(t, store.SetUserRoles(first.ID, []string{"dictionary_editor", "moderator"}), "SetUserRoles sans admin")
+	if first, _ = store.GetUserByID(first.ID); first.IsAdmin {
+		t.Errorf("SetUserRoles sans admin: is_admin vrai")
+	}
+
+	userRoles, err := store.GetUserRoles(first.ID)
+	must(t, err, "GetUserRoles")
+	if !equalStrings(userRoles, []string{"dictionary_editor", "moderator"}) {
+		t.Errorf("GetUserRoles: %v", userRoles)
+	}
+	permissions, err := store.GetUserPermissions(first.ID)
+	must(t, err, "GetUserPermissions")
+	expected := []string{database.PermChatModerate, database.PermDictionaryWrite, database.PermMatchesReadPrivate, database.PermUsersRead}
+	if !equalStrings(permissions, expected) {
+		t.Errorf("GetUserPermissions: %v, %v attendu", permissions, expected)
+	}
+	if userRoles, err := store.GetUserRoles(newUser(t, store, "no-roles").ID); err != nil || len(userRoles) != 0 {
+		t.Errorf("GetUserRoles sans rôle: %v, %v", userRoles, err)
+	}
+}
+
+func checkIdentities(t *testing.T, store database.Store) {
+	user, err := store.CreateExternalUser("carol", "carol@example.com", true, []byte("unusable"), "google", "sub-carol")
+	must(t, err, "CreateExternalUser")
+	if user == nil || user.ID <= 0 || user.Username != "carol" || !user.EmailVerified {
+		t.Fatalf("CreateExternalUser: %+v", user)
+	}
+
+	if got, err := store.GetUserByIdentity("google", "sub-carol"); err != nil || got == nil || got.ID != user.ID {
+		t.Errorf("GetUserByIdentity: %+v, %v", got, err)
+	}
+	if got, err := store.GetUserByIdentity("google", "unknown"); err != nil || got != nil {
+		t.Errorf("GetUserByIdentity inconnue: %+v, %v, nil attendu", got, err)
+	}
+
+	_, err = store.CreateExternalUser("carol", "carol2@example.com", true, []byte("unusable"), "google", "sub-other")
+	expectErr(t, err, database.ErrUsernameTaken, "CreateExternalUser avec un pseudo pris")
+	_, err = store.CreateExternalUser("carol2", "carol@example.com", true, []byte("unusable"), "google", "sub-other")
+	expectErr(t, err, database.ErrEmailTaken, "CreateExternalUser avec un email pris")
+	if got, _ := store.GetUserByUsername("carol2"); got != nil {
+		t.Errorf("CreateExternalUser en échec: compte créé quand même")
+	}
+
+	must(t, store.LinkIdentity(user.ID, "github", "42", ""), "LinkIdentity")
+	if got, err := store.GetUserByIdentity("github", "42"); err != nil || got == nil || got.ID != user.ID {
+		t.Errorf("GetUserByIdentity après LinkIdentity: %+v, %v", got, err)
+	}
+}
+
+func checkSuspensions(t *testing.T, store database.Store) {
+	user := newUser(t, store, "dave")
+	moderator := newUser(t, store, "dave-moderator")
+
+	if suspension, err := store.GetActiveSuspension(user.ID); err != nil || suspension != nil {
+		t.Errorf("GetActiveSuspension sans suspension: %+v, %v, nil attendu", suspension, err)
+	}
+
+	must(t, store.CreateSession(&database.Session{ID: "dave-session", UserID: user.ID, RefreshTokenHash: []byte{1},
+		CreatedAt: now(), LastUsedAt: now(), ExpiresAt: now().Add(time.Hour)}), "CreateSession")
+
+	expiresAt := now().Add(24 * time.Hour)
+	first, err := store.SuspendUser(user.ID, "spam", &expiresAt, moderator.ID)
+	must(t, err, "SuspendUser")
+	if first.ID <= 0 || first.Reason != "spam" || first.CreatedBy == nil || *first.CreatedBy != moderator.ID {
+		t.Errorf("SuspendUser: %+v", first)
+	}
+	active, err := store.GetActiveSuspension(user.ID)
+	must(t, err, "GetActiveSuspension")
+	if active == nil || active.ID != first.ID || active.ExpiresAt == nil || !sameTime(*active.ExpiresAt, expiresAt) {
+		t.Errorf("GetActiveSuspension: %+v, suspension %d attendue", active, first.ID)
+	}
+	if session, _ := store.GetSession("dave-session"); session == nil || session.RevokedAt == nil {
+		t.Errorf("SuspendUser: session non révoquée")
+	}
+
+	// Une nouvelle suspension remplace la précédente
+	ban, err := store.SuspendUser(user.ID, "récidive", nil, moderator.ID)
+	must(t, err, "SuspendUser définitif")
+	active, err = store.GetActiveSuspension(user.ID)
+	must(t, err, "GetActiveSuspension")
+	if active == nil || active.ID != ban.ID || active.ExpiresAt != nil || active.Reason != "récidive" {
+		t.Errorf("GetActiveSuspension après remplacement: %+v, bannissement %d attendu", active, ban.ID)
+	}
+
+	must(t, store.LiftSuspension(user.ID, moderator.ID), "LiftSuspension")
+	if active, err := store.GetActiveSuspension(user.ID); err != nil || active != nil {
+		t.Errorf("GetActiveSuspension après levée: %+v, %v, nil attendu", active, err)
+	}
+	expectErr(t, store.LiftSuspension(user.ID, moderator.ID), sql.ErrNoRows, "LiftSuspension sans suspension")
+
+	// Une suspension échue n'est plus active
+	past := now().Add(-time.Minute)
+	_, err = store.SuspendUser(user.ID, "échue", &past, moderator.ID)
+	must(t, err, "SuspendUser échue")
+	if active, err := store.GetActiveSuspension(user.ID); err != nil || active != nil {
+		t.Errorf("GetActiveSuspension échue: %+v, %v, nil attendu", active, err)
+	}
+}
+
+func checkUserList(t *testing.T, store database.Store) {
+	for _, name := range []string{"List-Zoe", "list-amy", "LIST-MAX"} {
+		newUser(t, store, name)
+	}
+	suspended := newUser(t, store, "list-sus")
+	deleted := newUser(t, store, "list-gone")
+	_, err := store.SuspendUser(suspended.ID, "test", nil, suspended.ID)
+	must(t, err, "SuspendUser")
+	must(t, store.SoftDeleteUser(deleted.ID), "SoftDeleteUser")
+
+	usernames := func(filter database.UserFilter) ([]string, int) {
+		users, total, err := store.ListUsers(filter)
+		must(t, err, "ListUsers")
+		names := []string{}
+		for _, user := range users {
+			names = append(names, user.Username)
+		}
+		return names, total
+	}
+
+	// La recherche et le tri ignorent la casse
+	names, total := usernames(database.UserFilter{Search: "LIST-", Sort: "username", Limit: 10})
+	if expected := []string{"list-amy", "LIST-MAX", "list-sus", "List-Zoe"}; !equalStrings(names, expected) || total != 4 {
+		t.Errorf("ListUsers recherche: %v (%d), %v attendu", names, total, expected)
+	}
+	names, total = usernames(database.UserFilter{Search: "list-", Sort: "username", Desc: true, Limit: 2, Offset: 1})
+	if expected := []string{"list-sus", "LIST-MAX"}; !equalStrings(names, expected) || total != 4 {
+		t.Errorf("ListUsers page: %v (%d), %v attendu", names, total, expected)
+	}
+	names, _ = usernames(database.UserFilter{Search: "list-", Status: "active", Sort: "username", Limit: 10})
+	if expected := []string{"list-amy", "LIST-MAX", "List-Zoe"}; !equalStrings(names, expected) {
+		t.Errorf("ListUsers actifs: %v, %v attendu", names, expected)
+	}
+	names, _ = usernames(database.UserFilter{Search: "list-", Status: "suspended", Limit: 10})
+	if !equalStrings(names, []string{"list-sus"}) {
+		t.Errorf("ListUsers suspendus: %v", names)
+	}
+	names, _ = usernames(database.UserFilter{Search: "list-", Status: "deleted", Limit: 10})
+	if !equalStrings(names, []string{"list-gone"}) {
+		t.Errorf("ListUsers supprimés: %v", names)
+	}
+	names, _ = usernames(database.UserFilter{Search: "list-", Status: "all", Sort: "id", Limit: 10})
+	if len(names) != 5 || names[0] != "List-Zoe" {
+		t.Errorf("ListUsers tous par id: %v", names)
+	}
+	names, _ = usernames(database.UserFilter{Search: "100%", Status: "all", Limit: 10})
+	if len(names) != 0 {
+		t.Errorf("ListUsers recherche de caractères spéciaux: %v", names)
+	}
+
+	user, err := store.GetAdminUser(suspended.ID)
+	must(t, err, "GetAdminUser")
+	if user == nil || user.Suspension == nil || user.Suspension.Reason != "test" {
+		t.Errorf("GetAdminUser: suspension manquante %+v", user)
+	}
+	if user, err := store.GetAdminUser(-1); err != nil || user != nil {
+		t.Errorf("GetAdminUser inconnu: %+v, %v, nil attendu", user, err)
+	}
+
+	expectErr(t, store.SoftDeleteUser(deleted.ID), sql.ErrNoRows, "SoftDeleteUser d'un utilisateur supprimé")
+	expectErr(t, store.SoftDeleteUser(-1), sql.ErrNoRows, "SoftDeleteUser d'un inconnu")
+	if user, _ := store.GetUserByID(deleted.ID); user == nil || user.DeletedAt == nil {
+		t.Errorf("SoftDeleteUser: deleted_at vide")
+	}
+	must(t, store.RestoreUser(deleted.ID), "RestoreUser")
+	if user, _ := store.GetUserByID(deleted.ID); user == nil || user.DeletedAt != nil {
+		t.Errorf("RestoreUser: deleted_at renseigné")
+	}
+	expectErr(t, store.RestoreUser(deleted.ID), sql.ErrNoRows, "RestoreUser d'un utilisateur non supprimé")
+}
+
+func checkDeleteUser(t *testing.T, store database.Store) {
+	moderator := newUser(t, store, "erin-moderator")
+	user := newUser(t, store, "erin")
+	other := newUser(t, store, "erin-other")
+
+	must(t, store.CreateSession(&database.Session{ID: "erin-session", UserID: user.ID, RefreshTokenHash: []byte{1},
+		CreatedAt: now(), LastUsedAt: now(), ExpiresAt: now().Add(time.Hour)}), "CreateSession")
+	must(t, store.SetPendingMFA(user.ID, "SECRET"), "SetPendingMFA")
+	must(t, store.SetUserRoles(moderator.ID, []string{"moderator"}), "SetUserRoles")
+	must(t, store.LinkIdentity(user.ID, "google", "sub-erin", ""), "LinkIdentity")
+	must(t, store.AddWordReport(&database.WordReport{Word: "ERINA", Kind: "missing", ReporterID: user.ID}), "AddWordReport")
+	moderatorID := moderator.ID
+	must(t, store.AddDictionaryWord("ERINB", "", &moderatorID), "AddDictionaryWord")
+	_, err := store.SuspendUser(other.ID, "test", nil, moderator.ID)
+	must(t, err, "SuspendUser")
+
+	must(t, store.DeleteUser(user.ID), "DeleteUser")
+	if got, _ := store.GetUserByID(user.ID); got != nil {
+		t.Errorf("DeleteUser: utilisateur toujours présent")
+	}
+	if session, _ := store.GetSession("erin-session"); session != nil && session.RevokedAt == nil {
+		t.Errorf("DeleteUser: session toujours active")
+	}
+	if got, _ := store.GetUserByIdentity("google", "sub-erin"); got != nil {
+		t.Errorf("DeleteUser: identité toujours rattachée")
+	}
+	if mfa, _ := store.GetUserMFA(user.ID); mfa != nil {
+		t.Errorf("DeleteUser: double authentification conservée")
+	}
+	if pending, _ := store.HasPendingWordReports("ERINA", "missing"); pending {
+		t.Errorf("DeleteUser: signalement conservé")
+	}
+	expectErr(t, store.DeleteUser(user.ID), sql.ErrNoRows, "DeleteUser d'un inconnu")
+
+	// Supprimer l'auteur d'un mot ou d'une suspension ne les supprime pas
+	must(t, store.DeleteUser(moderator.ID), "DeleteUser de l'auteur d'un mot et d'une suspension")
+	if word, _ := store.GetDictionaryWord("ERINB"); word == nil || word.AddedBy != nil {
+		t.Errorf("DeleteUser de l'auteur: mot %+v", word)
+	}
+	if suspension, _ := store.GetActiveSuspension(other.ID); suspension == nil || suspension.CreatedBy != nil {
+		t.Errorf("DeleteUser de l'auteur: suspension %+v", suspension)
+	}
+}
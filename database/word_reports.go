@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Types de signalement d'un mot
+const (
+	ReportMissing = "missing" // Mot refusé alors qu'il devrait être accepté
+	ReportRemove  = "remove"  // Mot à retirer du dictionnaire
+)
+
+// États d'un signalement
+const (
+	ReportPending  = "pending"
+	ReportApproved = "approved"
+	ReportRejected = "rejected"
+)
+
+var ErrAlreadyReported = errors.New("word already reported")
+
+type WordReport struct {
+	ID         int64     `json:"id"`
+	Word       string    `json:"word"`
+	Kind       string    `json:"kind"`
+	Comment    string    `json:"comment,omitempty"`
+	ReporterID int       `json:"-"`
+	Reporter   string    `json:"reporter"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WordReportGroup regroupe les signalements en attente d'un même mot
+type WordReportGroup struct {
+	Word         string       `json:"word"`
+	Kind         string       `json:"kind"`
+	Reports      int          `json:"reports"`
+	InDictionary bool         `json:"in_dictionary"`
+	Latest       []WordReport `json:"latest"` // Signalements les plus récents
+}
+
+// Nombre de signalements détaillés par mot dans la file de modération
+const latestReportsPerGroup = 5
+
+// AddWordReport enregistre un signalement. Renvoie ErrAlreadyReported si le
+// joueur a déjà un signalement en attente pour ce mot.
+func (s *SQLStore) AddWordReport(report *WordReport) error {
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now()
+	}
+	err := s.db.QueryRow("INSERT INTO word_reports (word, kind, comment, reporter_id, created_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING RETURNING id",
+		report.Word, report.Kind, nullString(report.Comment), report.ReporterID, report.CreatedAt).Scan(&report.ID)
+	if err == sql.ErrNoRows {
+		return ErrAlreadyReported
+	}
+	return err
+}
+
+// CountPendingReportsByUser renvoie le nombre de signalements en attente d'un joueur
+func (s *SQLStore) CountPendingReportsByUser(userID int) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM word_reports WHERE reporter_id = ? AND status = ?", userID, ReportPending).Scan(&count)
+	return count, err
+}
+
+// ListWordReportGroups renvoie une page de la file de modération : les mots
+// signalés, du plus signalé au moins signalé, avec leurs derniers
+// signalements. kind vide renvoie les deux types.
+func (s *SQLStore) ListWordReportGroups(kind string, limit, offset int) ([]WordReportGroup, int, error) {
+	where := " WHERE status = ?"
+	args := []interface{}{ReportPending}
+	if kind != "" {
+		where += " AND kind = ?"
+		args = append(args, kind)
+	}
+
+	var total int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM (SELECT 1 FROM word_reports"+where+" GROUP BY word, kind) AS reported", args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Query(`SELECT word, kind, COUNT(*),
+		EXISTS(SELECT 1 FROM dictionary_words d WHERE d.word = word_reports.word)
+		FROM word_reports`+where+` GROUP BY word, kind
+		ORDER BY COUNT(*) DESC, MAX(id) DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	groups := []WordReportGroup{}
+	for rows.Next() {
+		var group WordReportGroup
+		if err := rows.Scan(&group.Word, &group.Kind, &group.Reports, &group.InDictionary); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		groups = append(groups, group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	for i := range groups {
+		groups[i].Latest, err = s.latestWordReports(groups[i].Word, groups[i].Kind)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return groups, total, nil
+}
+
+func (s *SQLStore) latestWordReports(word, kind string) ([]WordReport, error) {
+	rows, err := s.db.Query(`SELECT r.id, r.word, r.kind, COALESCE(r.comment, ''), r.reporter_id, u.username, r.created_at
+		FROM word_reports r JOIN users u ON u.id = r.reporter_id
+		WHERE r.word = ? AND r.kind = ? AND r.status = ? ORDER BY r.id DESC LIMIT ?`,
+		word, kind, ReportPending, latestReportsPerGroup)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []WordReport{}
+	for rows.Next() {
+		var report WordReport
+		err := rows.Scan(&report.ID, &report.Word, &report.Kind, &report.Comment, &report.ReporterID, &report.Reporter, &report.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// HasPendingWordReports indique si un mot a des signalements en attente
+func (s *SQLStore) HasPendingWordReports(word, kind string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM word_reports WHERE word = ? AND kind = ? AND status = ?)",
+		word, kind, ReportPending).Scan(&exists)
+	return exists, err
+}
+
+// ResolveWordReports clôt les signalements en attente d'un mot avec l'état
+// donné et renvoie leur nombre. Renvoie sql.ErrNoRows s'il n'y en a aucun.
+func (s *SQLStore) ResolveWordReports(word, kind, status string, resolvedBy int) (int, error) {
+	result, err := s.db.Exec("UPDATE word_reports SET status = ?, resolved_by = ?, resolved_at = ? WHERE word = ? AND kind = ? AND status = ?",
+		status, resolvedBy, time.Now(), word, kind, ReportPending)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return int(n), nil
+}
@@ -0,0 +1,108 @@
+// Package dictionary contient la liste des mots jouables, chargée depuis la
+// base et remplacée à chaud lorsqu'un administrateur la modifie.
+package dictionary
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Longueurs acceptées pour un mot du dictionnaire
+const (
+	MinLength = 5
+	MaxLength = 8
+)
+
+var (
+	ErrInvalidCharacters = errors.New("invalid characters")
+	ErrInvalidLength     = fmt.Errorf("length must be between %d and %d", MinLength, MaxLength)
+)
+
+type Word struct {
+	Word    string
+	Flagged bool // Accepté comme proposition, mais jamais tiré comme mot mystère
+}
+
+// Store est la liste des mots en mémoire, sûre pour un accès concurrent
+type Store struct {
+	mu       sync.RWMutex
+	valid    map[string]bool
+	playable []string
+}
+
+func NewStore() *Store {
+	return &Store{valid: make(map[string]bool)}
+}
+
+// Replace remplace tout le contenu de la liste
+func (s *Store) Replace(words []Word) {
+	valid := make(map[string]bool, len(words))
+	playable := make([]string, 0, len(words))
+	for _, w := range words {
+		valid[w.Word] = true
+		if !w.Flagged {
+			playable = append(playable, w.Word)
+		}
+	}
+
+	s.mu.Lock()
+	s.valid = valid
+	s.playable = playable
+	s.mu.Unlock()
+}
+
+// Contains indique si un mot est accepté comme proposition
+func (s *Store) Contains(word string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.valid[word]
+}
+
+// Random tire un mot mystère parmi les mots non signalés. Renvoie false si
+// aucun mot n'est disponible.
+func (s *Store) Random() (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.playable) == 0 {
+		return "", false
+	}
+	return s.playable[rand.Intn(len(s.playable))], true
+}
+
+// Len renvoie le nombre de mots acceptés et le nombre de mots jouables
+func (s *Store) Len() (int, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.valid), len(s.playable)
+}
+
+// Lettres accentuées ramenées à leur forme sans accent
+var accents = strings.NewReplacer(
+	"À", "A", "Â", "A", "Ä", "A",
+	"Ç", "C",
+	"É", "E", "È", "E", "Ê", "E", "Ë", "E",
+	"Î", "I", "Ï", "I",
+	"Ô", "O", "Ö", "O",
+	"Ù", "U", "Û", "U", "Ü", "U",
+	"Ÿ", "Y",
+	"Œ", "OE", "Æ", "AE",
+)
+
+// Normalize met un mot au format du dictionnaire (majuscules sans accent) et
+// vérifie sa longueur et ses caractères
+func Normalize(word string) (string, error) {
+	word = accents.Replace(strings.ToUpper(strings.TrimSpace(word)))
+	for _, r := range word {
+		if r < 'A' || r > 'Z' {
+			return "", ErrInvalidCharacters
+		}
+	}
+	if n := utf8.RuneCountInString(word); n < MinLength || n > MaxLength {
+		return "", ErrInvalidLength
+	}
+	return word, nil
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"motzarella/database"
+
+	"github.com/gorilla/websocket"
+)
+
+// Player enveloppe la connexion d'un joueur ou d'un spectateur.
+// gorilla/websocket n'accepte qu'un seul écrivain à la fois, or plusieurs
+// goroutines envoient des messages aux participants d'une même partie :
+// toutes les écritures passent donc par WriteJSON.
+type Player struct {
+	Conn     *websocket.Conn
+	ID       string
+	Username string // Vide pour un joueur non connecté
+	UserID   int    // 0 pour un joueur non connecté
+	mu       sync.Mutex
+}
+
+func (p *Player) WriteJSON(v interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Conn.WriteJSON(v)
+}
+
+// Disconnect ferme la connexion après avoir envoyé un dernier message au
+// joueur. La goroutine qui lit la connexion se termine alors normalement.
+func (p *Player) Disconnect(message map[string]interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Conn.WriteJSON(message)
+	p.Conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, ""), time.Now().Add(time.Second))
+	p.Conn.Close()
+}
+
+// Name renvoie le nom affiché aux autres participants
+func (p *Player) Name() string {
+	if p.Username == "" {
+		return "Invité"
+	}
+	return p.Username
+}
+
+// GameSettings regroupe les options d'une partie. Seuls les joueurs ayant
+// choisi les mêmes options sont mis en relation par le matchmaking.
+type GameSettings struct {
+	// Diffuser la progression de l'adversaire (nombre d'essais et couleurs,
+	// sans les lettres). Désactivé, on retrouve le mode caché.
+	OpponentProgress bool `json:"opponent_progress"`
+	// Une partie privée n'est pas listée et refuse les spectateurs
+	Private bool `json:"private"`
+}
+
+type Game struct {
+	ID         string
+	Players    map[*Player]bool
+	Spectators map[*Player]bool // Séparés des joueurs : ils ne peuvent jamais proposer de mot
+	Word       string
+	Guesses    map[*Player][]string  // Stocke les tentatives de chaque joueur
+	Timeline   []database.MatchGuess // Toutes les tentatives horodatées, dans l'ordre
+	Chat       []database.MatchChat
+	Settings   GameSettings
+	StartedAt  time.Time
+	Finished   bool
+	EndReason  string // Motif affiché aux joueurs si la partie est arrêtée par un administrateur
+	mu         sync.Mutex
+}
+
+func NewGame(id, word string, settings GameSettings, players ...*Player) *Game {
+	game := &Game{
+		ID:         id,
+		Players:    make(map[*Player]bool),
+		Spectators: make(map[*Player]bool),
+		Word:       word,
+		Guesses:    make(map[*Player][]string),
+		Settings:   settings,
+		StartedAt:  time.Now(),
+	}
+	for _, player := range players {
+		game.Players[player] = true
+		game.Guesses[player] = []string{}
+	}
+	return game
+}
+
+// SubmitGuess traite la proposition d'un joueur et diffuse le résultat
+func (g *Game) SubmitGuess(player *Player, guess string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Finished || !g.Players[player] {
+		return
+	}
+
+	// Vérifier si le joueur a déjà gagné ou perdu
+	if len(g.Guesses[player]) > 0 && (g.Guesses[player][len(g.Guesses[player])-1] == g.Word || len(g.Guesses[player]) >= 6) {
+		return
+	}
+
+	if !isValidWord(guess) {
+		player.WriteJSON(map[string]interface{}{
+			"type":    "error",
+			"message": "Mot non reconnu dans le dictionnaire.",
+			"code":    "unknown_word",
+			"word":    guess,
+		})
+		return
+	}
+
+	// Vérifier si le mot est valide (même longueur que le mot mystère)
+	if len(guess) != len(g.Word) {
+		player.WriteJSON(map[string]interface{}{
+			"type":    "error",
+			"message": fmt.Sprintf("Le mot doit faire exactement %d lettres", len(g.Word)),
+		})
+		return
+	}
+
+	result := checkGuess(guess, g.Word)
+	g.Guesses[player] = append(g.Guesses[player], guess)
+	g.Timeline = append(g.Timeline, database.MatchGuess{
+		PlayerID:  player.ID,
+		Guess:     guess,
+		Result:    result,
+		CreatedAt: time.Now(),
+	})
+	attempts := len(g.Guesses[player])
+
+	// Envoyer le résultat uniquement au joueur qui a fait la tentative
+	player.WriteJSON(map[string]interface{}{
+		"type":     "guess_result",
+		"guess":    guess,
+		"result":   result,
+		"correct":  guess == g.Word,
+		"attempts": attempts,
+	})
+
+	// Informer les adversaires de la progression, sans les lettres
+	if g.Settings.OpponentProgress {
+		for opponent := range g.Players {
+			if opponent == player {
+				continue
+			}
+			opponent.WriteJSON(map[string]interface{}{
+				"type":     "opponent_progress",
+				"result":   result,
+				"attempts": attempts,
+			})
+		}
+	}
+
+	// Les spectateurs voient les couleurs de chaque joueur, jamais les lettres
+	for spectator := range g.Spectators {
+		spectator.WriteJSON(map[string]interface{}{
+			"type":      "player_progress",
+			"player_id": player.ID,
+			"username":  player.Name(),
+			"result":    result,
+			"attempts":  attempts,
+		})
+	}
+
+	if guess == g.Word {
+		// Le joueur a gagné
+		g.finish(player)
+	} else if attempts >= 6 {
+		// Le joueur a perdu, l'autre joueur gagne
+		for p := range g.Players {
+			if p != player {
+				g.finish(p)
+				return
+			}
+		}
+		g.finish(nil)
+	}
+}
+
+// finish envoie game_over à tous les participants, enregistre la partie et la
+// retire du registre. Doit être appelée avec g.mu verrouillé.
+func (g *Game) finish(winner *Player) {
+	g.Finished = true
+
+	// Enregistrer les adversaires avant game_over : un joueur peut demander
+	// sa revanche dès la réception du message
+	recordFinishedGame(g)
+
+	for p := range g.Players {
+		result := "none"
+		if p == winner {
+			result = "you"
+		}
+		gameOver := map[string]interface{}{
+			"type":   "game_over",
+			"winner": result,
+			"word":   g.Word,
+		}
+		if g.EndReason != "" {
+			gameOver["reason"] = g.EndReason
+		}
+		p.WriteJSON(gameOver)
+	}
+
+	// Une fois la partie terminée, les spectateurs reçoivent le mot et
+	// l'intégralité des tentatives
+	if len(g.Spectators) > 0 {
+		winnerID := ""
+		if winner != nil {
+			winnerID = winner.ID
+		}
+		timeline := g.playersSnapshot(true)
+		for spectator := range g.Spectators {
+			spectator.WriteJSON(map[string]interface{}{
+				"type":      "game_over",
+				"winner_id": winnerID,
+				"word":      g.Word,
+				"players":   timeline,
+			})
+		}
+	}
+
+	if err := store.SaveMatch(g.match(winner)); err != nil {
+		log.Printf("Erreur lors de l'enregistrement de la partie %s: %v", g.ID, err)
+	}
+
+	games.Remove(g.ID)
+}
+
+// Abort termine la partie sans vainqueur, avec un motif affiché aux joueurs.
+// Renvoie false si elle était déjà terminée.
+func (g *Game) Abort(reason string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Finished {
+		return false
+	}
+	g.EndReason = reason
+	g.finish(nil)
+	return true
+}
+
+// match convertit la partie pour l'historique. Doit être appelée avec g.mu verrouillé.
+func (g *Game) match(winner *Player) *database.Match {
+	match := &database.Match{
+		ID:               g.ID,
+		Word:             g.Word,
+		OpponentProgress: g.Settings.OpponentProgress,
+		Private:          g.Settings.Private,
+		StartedAt:        g.StartedAt,
+		EndedAt:          time.Now(),
+		Guesses:          g.Timeline,
+		Chat:             g.Chat,
+	}
+	if winner != nil {
+		match.WinnerID = winner.ID
+	}
+	for p := range g.Players {
+		match.Players = append(match.Players, database.MatchPlayer{ID: p.ID, Username: p.Username})
+	}
+	return match
+}
+
+// SendChat relaie un message ou une réaction rapide d'un joueur à tous les
+// joueurs et spectateurs de la partie
+func (g *Game) SendChat(player *Player, message, emote string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Finished || !g.Players[player] {
+		return
+	}
+
+	text, errMsg := validateChat(message, emote)
+	if errMsg == "" && !chatLimits.Allow(player, time.Now()) {
+		errMsg = "Vous envoyez des messages trop rapidement."
+	}
+	if errMsg != "" {
+		player.WriteJSON(map[string]interface{}{
+			"type":    "error",
+			"message": errMsg,
+		})
+		return
+	}
+
+	entry := database.MatchChat{
+		PlayerID:  player.ID,
+		Username:  player.Username,
+		Emote:     emote,
+		CreatedAt: time.Now(),
+	}
+	if emote != "" {
+		entry.Message = chatEmotes[emote]
+	} else if filtered, ok := filterChatMessage(text); ok {
+		entry.Message = filtered
+		entry.Original = text
+	} else {
+		entry.Message = text
+	}
+	g.Chat = append(g.Chat, entry)
+
+	chat := map[string]interface{}{
+		"type":       "chat",
+		"player_id":  player.ID,
+		"username":   player.Name(),
+		"message":    entry.Message,
+		"emote":      entry.Emote,
+		"created_at": entry.CreatedAt,
+	}
+	for p := range g.Players {
+		p.WriteJSON(chat)
+	}
+	for spectator := range g.Spectators {
+		spectator.WriteJSON(chat)
+	}
+}
+
+// AddSpectator inscrit un spectateur et lui envoie l'état actuel de la partie
+func (g *Game) AddSpectator(spectator *Player) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Finished {
+		return fmt.Errorf("game is over")
+	}
+	if g.Settings.Private {
+		return fmt.Errorf("game is private")
+	}
+
+	g.Spectators[spectator] = true
+	spectator.WriteJSON(map[string]interface{}{
+		"type":        "spectate_start",
+		"game_id":     g.ID,
+		"word_length": len(g.Word),
+		"started_at":  g.StartedAt,
+		"players":     g.playersSnapshot(false),
+	})
+	return nil
+}
+
+func (g *Game) RemoveSpectator(spectator *Player) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.Spectators, spectator)
+}
+
+// playersSnapshot décrit la progression de chaque joueur. Les lettres ne
+// sont incluses que si withGuesses est vrai, c'est-à-dire en fin de partie.
+// Doit être appelée avec g.mu verrouillé.
+func (g *Game) playersSnapshot(withGuesses bool) []map[string]interface{} {
+	players := []map[string]interface{}{}
+	for p := range g.Players {
+		results := [][]string{}
+		for _, guess := range g.Guesses[p] {
+			results = append(results, checkGuess(guess, g.Word))
+		}
+		entry := map[string]interface{}{
+			"player_id": p.ID,
+			"username":  p.Name(),
+			"attempts":  len(g.Guesses[p]),
+			"results":   results,
+		}
+		if withGuesses {
+			entry["guesses"] = g.Guesses[p]
+		}
+		players = append(players, entry)
+	}
+	return players
+}
+
+// LiveInfo résume une partie en cours pour /api/matches/live
+func (g *Game) LiveInfo() map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	players := []map[string]interface{}{}
+	for p := range g.Players {
+		players = append(players, map[string]interface{}{
+			"player_id": p.ID,
+			"username":  p.Name(),
+			"attempts":  len(g.Guesses[p]),
+		})
+	}
+	return map[string]interface{}{
+		"game_id":     g.ID,
+		"players":     players,
+		"spectators":  len(g.Spectators),
+		"word_length": len(g.Word),
+		"started_at":  g.StartedAt,
+	}
+}
+
+// AdminInfo décrit une partie en cours pour le tableau de bord
+// d'administration, parties privées comprises
+func (g *Game) AdminInfo() map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	players := []map[string]interface{}{}
+	for p := range g.Players {
+		players = append(players, map[string]interface{}{
+			"player_id": p.ID,
+			"user_id":   p.UserID,
+			"username":  p.Name(),
+			"attempts":  len(g.Guesses[p]),
+		})
+	}
+	return map[string]interface{}{
+		"game_id":         g.ID,
+		"players":         players,
+		"spectators":      len(g.Spectators),
+		"settings":        g.Settings,
+		"word_length":     len(g.Word),
+		"started_at":      g.StartedAt,
+		"elapsed_seconds": int(time.Since(g.StartedAt).Seconds()),
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"motzarella/database"
+	"motzarella/dictionary"
+
+	"github.com/gorilla/websocket"
+)
+
+// testClient est le navigateur d'un joueur de test : il reçoit les messages
+// envoyés au joueur par le serveur
+type testClient struct {
+	messages chan map[string]interface{}
+}
+
+// newTestPlayer crée un joueur relié par WebSocket à un client de test
+func newTestPlayer(t *testing.T, id string) (*Player, *testClient) {
+	t.Helper()
+	serverConns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		serverConns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	clientConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := <-serverConns
+	t.Cleanup(func() {
+		conn.Close()
+		clientConn.Close()
+	})
+
+	client := &testClient{messages: make(chan map[string]interface{}, 32)}
+	go func() {
+		for {
+			var message map[string]interface{}
+			if err := clientConn.ReadJSON(&message); err != nil {
+				return
+			}
+			client.messages <- message
+		}
+	}()
+	return &Player{Conn: conn, ID: id, Username: id}, client
+}
+
+// next renvoie le prochain message reçu, qui doit être du type attendu
+func (c *testClient) next(t *testing.T, messageType string) map[string]interface{} {
+	t.Helper()
+	select {
+	case message := <-c.messages:
+		if message["type"] != messageType {
+			t.Fatalf("message %v, type %s attendu", message, messageType)
+		}
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatalf("aucun message reçu, type %s attendu", messageType)
+		return nil
+	}
+}
+
+// none vérifie qu'aucun message n'a été reçu
+func (c *testClient) none(t *testing.T) {
+	t.Helper()
+	select {
+	case message := <-c.messages:
+		t.Errorf("message inattendu: %v", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// setupGameTest fournit le dictionnaire et la base utilisés par les parties
+func setupGameTest(t *testing.T) {
+	dictionaryWords.Replace([]dictionary.Word{{Word: "RACINE"}, {Word: "CERISE"}, {Word: "NAVIRE"}})
+	store = database.NewMemoryStore()
+	t.Cleanup(func() { store = nil })
+}
+
+func keys(message map[string]interface{}) []string {
+	list := make([]string, 0, len(message))
+	for key := range message {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// expectColorsOnly vérifie qu'un résultat ne contient que des couleurs
+func expectColorsOnly(t *testing.T, result interface{}) {
+	t.Helper()
+	colors, ok := result.([]interface{})
+	if !ok || len(colors) != 6 {
+		t.Fatalf("résultat %v, 6 couleurs attendues", result)
+	}
+	for _, color := range colors {
+		switch color {
+		case "correct", "present", "absent":
+		default:
+			t.Errorf("résultat %v: %v n'est pas une couleur", result, color)
+		}
+	}
+}
+
+func TestSubmitGuessProgressCarriesNoLetters(t *testing.T) {
+	setupGameTest(t)
+	alice, aliceClient := newTestPlayer(t, "alice")
+	bob, bobClient := newTestPlayer(t, "bob")
+	spectator, spectatorClient := newTestPlayer(t, "spectateur")
+
+	game := NewGame("partie-progression", "RACINE", GameSettings{OpponentProgress: true}, alice, bob)
+	if err := game.AddSpectator(spectator); err != nil {
+		t.Fatal(err)
+	}
+	spectatorClient.next(t, "spectate_start")
+
+	game.SubmitGuess(alice, "CERISE")
+
+	result := aliceClient.next(t, "guess_result")
+	if result["guess"] != "CERISE" {
+		t.Errorf("résultat du joueur: %v, proposition attendue", result)
+	}
+
+	progress := bobClient.next(t, "opponent_progress")
+	if got := strings.Join(keys(progress), ","); got != "attempts,result,type" {
+		t.Errorf("progression de l'adversaire: champs %s", got)
+	}
+	expectColorsOnly(t, progress["result"])
+
+	watched := spectatorClient.next(t, "player_progress")
+	if got := strings.Join(keys(watched), ","); got != "attempts,player_id,result,type,username" {
+		t.Errorf("progression pour les spectateurs: champs %s", got)
+	}
+	expectColorsOnly(t, watched["result"])
+
+	// Un spectateur arrivé en cours de partie voit les couleurs, pas les mots
+	late, lateClient := newTestPlayer(t, "retardataire")
+	if err := game.AddSpectator(late); err != nil {
+		t.Fatal(err)
+	}
+	start := lateClient.next(t, "spectate_start")
+	players, _ := start["players"].([]interface{})
+	for _, entry := range players {
+		player, _ := entry.(map[string]interface{})
+		if _, ok := player["guesses"]; ok {
+			t.Errorf("état de la partie pour un spectateur: propositions %v", player["guesses"])
+		}
+	}
+}
+
+func TestSubmitGuessHiddenProgress(t *testing.T) {
+	setupGameTest(t)
+	alice, aliceClient := newTestPlayer(t, "alice")
+	bob, bobClient := newTestPlayer(t, "bob")
+
+	game := NewGame("partie-cachee", "RACINE", GameSettings{}, alice, bob)
+	game.SubmitGuess(alice, "CERISE")
+
+	aliceClient.next(t, "guess_result")
+	bobClient.none(t)
+}
+
+func TestHandlePlayerMessageMalformedGuess(t *testing.T) {
+	setupGameTest(t)
+	alice, aliceClient := newTestPlayer(t, "alice")
+	bob, _ := newTestPlayer(t, "bob")
+	game := NewGame("partie-messages", "RACINE", GameSettings{}, alice, bob)
+	games.Add(game)
+	t.Cleanup(func() { games.Remove(game.ID) })
+
+	malformed := []map[string]interface{}{
+		{"type": "submit_guess", "game_id": game.ID},
+		{"type": "submit_guess", "game_id": game.ID, "guess": 42},
+		{"type": "submit_guess", "game_id": game.ID, "guess": nil},
+	}
+	for _, data := range malformed {
+		handlePlayerMessage(alice, data)
+		aliceClient.next(t, "error")
+	}
+	if len(game.Guesses[alice]) != 0 {
+		t.Errorf("propositions enregistrées: %v", game.Guesses[alice])
+	}
+
+	// Un identifiant de partie mal typé est ignoré, comme une partie inconnue
+	handlePlayerMessage(alice, map[string]interface{}{"type": "submit_guess", "game_id": 7, "guess": "CERISE"})
+	aliceClient.none(t)
+
+	handlePlayerMessage(alice, map[string]interface{}{"type": "submit_guess", "game_id": game.ID, "guess": "CERISE"})
+	aliceClient.next(t, "guess_result")
+}
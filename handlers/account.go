@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"motzarella/database"
+	"motzarella/mailer"
+	"motzarella/validation"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Taille maximale d'un avatar
+	maxAvatarSize = 1 << 20
+	// Dimensions maximales d'un avatar, en pixels
+	maxAvatarDimension = 1024
+)
+
+// Formats d'avatar acceptés, détectés d'après le contenu du fichier
+var avatarTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+// avatarURL renvoie l'adresse de l'avatar d'un utilisateur, ou une chaîne vide
+func avatarURL(user *database.User) string {
+	if user.Avatar == "" {
+		return ""
+	}
+	return "/avatars/" + user.Avatar
+}
+
+// checkCurrentPassword répond 401 si le mot de passe fourni n'est pas celui de l'utilisateur
+func checkCurrentPassword(w http.ResponseWriter, user *database.User, password string) bool {
+	if database.TestPassword(user.Password, password) != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Mot de passe actuel incorrect",
+		})
+		return false
+	}
+	return true
+}
+
+// verifyCurrentPassword vérifie le mot de passe actuel avec la limitation
+// des tentatives de connexion : un token d'accès volé ne permet pas de le
+// deviner. Renvoie false si la réponse d'erreur (429 ou 401) a été écrite.
+func (h *Handler) verifyCurrentPassword(w http.ResponseWriter, r *http.Request, user *database.User, password string) bool {
+	keys := loginLimitKeys(clientIP(r), user.Username)
+	if wait := h.loginLimits.allow(keys, time.Now()); wait > 0 {
+		writeRetryAfter(w, wait)
+		return false
+	}
+
+	if !checkCurrentPassword(w, user, password) {
+		h.auditLockouts(r, h.loginLimits.fail(keys, time.Now()))
+		return false
+	}
+	h.loginLimits.succeed("user:" + user.Username)
+	return true
+}
+
+// ChangeUsernameHandler renomme l'utilisateur connecté (POST {"username"}) et
+// renvoie un token d'accès portant le nouveau nom
+func (h *Handler) ChangeUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := r.Context().Value("user").(*database.User)
+	sessionID := r.Context().Value("session_id").(string)
+
+	var body struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	errs := validation.Errors{}
+	errs.Add("username", validation.Username(body.Username))
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	err := h.store.UpdateUsername(user.ID, body.Username)
+	if err == database.ErrUsernameTaken {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Ce nom d'utilisateur est déjà pris",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.recordAudit(r, user, "username.changed", "user:"+body.Username, map[string]interface{}{
+		"from": user.Username,
+		"to":   body.Username,
+	})
+
+	tokenString, err := h.tokens.Issue(body.Username, user.IsAdmin, sessionID, accessTokenTTL)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"username":   body.Username,
+		"token":      tokenString,
+		"expires_in": int(accessTokenTTL.Seconds()),
+	})
+}
+
+// ChangeEmailHandler change l'adresse de l'utilisateur connecté
+// (POST {"email", "password"}). La nouvelle adresse doit être vérifiée et
+// l'ancienne est prévenue du changement.
+func (h *Handler) ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := r.Context().Value("user").(*database.User)
+
+	var body struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !h.verifyCurrentPassword(w, r, user, body.Password) {
+		return
+	}
+
+	errs := validation.Errors{}
+	errs.Add("email", validation.Email(body.Email))
+	if errs.Empty() && body.Email == user.Email {
+		errs.Add("email", "C'est déjà votre adresse email")
+	}
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	err := h.store.UpdateEmail(user.ID, body.Email)
+	if err == database.ErrEmailTaken {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Cet email est déjà utilisé",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	previous := user.Email
+	h.recordAudit(r, user, "email.changed", "user:"+user.Username, map[string]interface{}{
+		"from": previous,
+		"to":   body.Email,
+	})
+	updated := *user
+	updated.Email = body.Email
+	updated.EmailVerified = false
+	if err := h.sendVerificationEmail(&updated); err != nil {
+		log.Printf("Erreur lors de l'envoi de l'email de vérification à %s: %v", user.Username, err)
+	}
+	err = h.mail.Send(mailer.Message{
+		To:      previous,
+		Subject: "Votre adresse email a été modifiée",
+		Body:    "Bonjour " + user.Username + ",\n\nL'adresse email de votre compte vient d'être remplacée par " + body.Email + ".\nSi vous n'êtes pas à l'origine de ce changement, réinitialisez votre mot de passe.\n",
+	})
+	if err != nil {
+		log.Printf("Erreur lors de l'envoi de l'avis de changement d'email à %s: %v", user.Username, err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"email":          body.Email,
+		"email_verified": false,
+	})
+}
+
+// AvatarUploadHandler remplace (POST, champ multipart "avatar") ou retire
+// (DELETE) l'avatar de l'utilisateur connecté
+func (h *Handler) AvatarUploadHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*database.User)
+
+	switch r.Method {
+	case http.MethodPost:
+		// Marge pour l'enveloppe multipart autour du fichier
+		r.Body = http.MaxBytesReader(w, r.Body, maxAvatarSize+64<<10)
+		file, _, err := r.FormFile("avatar")
+		if err != nil {
+			writeAvatarError(w, "Fichier manquant ou trop volumineux (1 Mo maximum)")
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, maxAvatarSize+1))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if len(data) > maxAvatarSize {
+			writeAvatarError(w, "L'avatar ne doit pas dépasser 1 Mo")
+			return
+		}
+
+		// Le type est déduit du contenu, pas du nom ni de l'en-tête envoyés
+		ext, ok := avatarTypes[http.DetectContentType(data)]
+		if !ok {
+			writeAvatarError(w, "Format non pris en charge (PNG, JPEG ou GIF)")
+			return
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			writeAvatarError(w, "Image illisible")
+			return
+		}
+		if config.Width > maxAvatarDimension || config.Height > maxAvatarDimension {
+			writeAvatarError(w, "L'avatar ne doit pas dépasser 1024×1024 pixels")
+			return
+		}
+
+		if err := os.MkdirAll(h.avatarDir, os.ModePerm); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		name := uuid.New().String() + ext
+		if err := os.WriteFile(filepath.Join(h.avatarDir, name), data, 0644); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := h.store.UpdateAvatar(user.ID, name); err != nil {
+			os.Remove(filepath.Join(h.avatarDir, name))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		h.removeAvatarFile(user.Avatar)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"avatar_url": "/avatars/" + name,
+		})
+
+	case http.MethodDelete:
+		if err := h.store.UpdateAvatar(user.ID, ""); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		h.removeAvatarFile(user.Avatar)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeAvatarError(w http.ResponseWriter, message string) {
+	errs := validation.Errors{}
+	errs.Add("avatar", message)
+	writeValidationErrors(w, errs)
+}
+
+func (h *Handler) removeAvatarFile(name string) {
+	if name == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(h.avatarDir, name)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Erreur lors de la suppression de l'avatar %s: %v", name, err)
+	}
+}
+
+// AvatarHandler sert les avatars (GET /avatars/{fichier})
+func (h *Handler) AvatarHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/avatars/")
+	id := strings.TrimSuffix(name, filepath.Ext(name))
+	if _, err := uuid.Parse(id); err != nil || !validAvatarExt(filepath.Ext(name)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, filepath.Join(h.avatarDir, name))
+}
+
+func validAvatarExt(ext string) bool {
+	for _, allowed := range avatarTypes {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteAccountHandler supprime le compte de l'utilisateur connecté
+// (DELETE {"password", "confirm"}). Par confirmation, "confirm" doit
+// reprendre le nom d'utilisateur.
+func (h *Handler) DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := r.Context().Value("user").(*database.User)
+
+	var body struct {
+		Password string `json:"password"`
+		Confirm  string `json:"confirm"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !h.verifyCurrentPassword(w, r, user, body.Password) {
+		return
+	}
+	if body.Confirm != user.Username {
+		errs := validation.Errors{}
+		errs.Add("confirm", "Saisissez votre nom d'utilisateur pour confirmer la suppression")
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	if user.IsAdmin {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Un administrateur ne peut pas supprimer son propre compte",
+		})
+		return
+	}
+
+	if err := h.store.DeleteUser(user.ID); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.removeAvatarFile(user.Avatar)
+	h.recordAudit(r, user, "account.deleted", "user:"+user.Username, nil)
+	h.disconnectUser(user.ID, accountDeletedMessage)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// unusablePassword renvoie le hash d'un mot de passe aléatoire jamais
+// communiqué : le compte ne peut plus se connecter par mot de passe avant
+// d'en avoir choisi un avec "mot de passe oublié"
+func unusablePassword() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(secret)), bcrypt.DefaultCost)
+}
@@ -1,69 +1,59 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"motzarella/database"
 )
 
-// Middleware pour vérifier si l'utilisateur est admin
-func AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		user := r.Context().Value("user").(*database.User)
-		if !user.IsAdmin {
-			http.Error(w, "Accès non autorisé", http.StatusForbidden)
-			return
+// RequirePermission vérifie que l'utilisateur dispose de la permission
+// demandée, accordée par l'un de ses rôles
+func RequirePermission(permission string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			user := r.Context().Value("user").(*database.User)
+			if !user.Can(permission) {
+				http.Error(w, "Accès non autorisé", http.StatusForbidden)
+				return
+			}
+			next(w, r)
 		}
-		next(w, r)
 	}
 }
 
-// Handler pour lister tous les utilisateurs
-func ListUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := database.GetAllUsers()
-	if err != nil {
-		http.Error(w, "Erreur lors de la récupération des utilisateurs", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
-}
-
-// Handler pour supprimer un utilisateur
-func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
-	// Extraire l'ID de l'utilisateur de l'URL
+// Handler pour consulter le chat d'une partie (/api/admin/matches/{id}/chat)
+func (h *Handler) MatchChatHandler(w http.ResponseWriter, r *http.Request) {
+	// [, api, admin, matches, ID, chat]
 	parts := strings.Split(r.URL.Path, "/")
-
-	// L'ID est maintenant le dernier élément du chemin
-	if len(parts) < 6 { // [, api, admin, users, delete, ID]
-		http.Error(w, "ID utilisateur manquant", http.StatusBadRequest)
+	if len(parts) != 6 || parts[4] == "" || parts[5] != "chat" {
+		http.Error(w, "Chemin invalide", http.StatusNotFound)
 		return
 	}
 
-	// Prendre le dernier élément comme ID
-	userID, err := strconv.Atoi(parts[len(parts)-1])
-	if err != nil || userID <= 0 {
-		http.Error(w, "ID utilisateur invalide", http.StatusBadRequest)
+	chat, err := h.store.GetMatchChat(parts[4])
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération du chat", http.StatusInternalServerError)
 		return
 	}
 
-	err = database.DeleteUser(userID)
+	// La consultation des messages d'origine est tracée
+	actor := r.Context().Value("user").(*database.User)
+	h.recordAudit(r, actor, "match.chat_viewed", "match:"+parts[4], nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chat)
+}
+
+// Handler pour lister les rôles et leurs permissions (/api/admin/roles)
+func (h *Handler) ListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.store.GetRoles()
 	if err != nil {
-		switch {
-		case err == sql.ErrNoRows:
-			http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
-		case strings.Contains(err.Error(), "cannot delete admin user"):
-			http.Error(w, "Impossible de supprimer un administrateur", http.StatusForbidden)
-		default:
-			http.Error(w, "Erreur lors de la suppression de l'utilisateur", http.StatusInternalServerError)
-		}
+		http.Error(w, "Erreur lors de la récupération des rôles", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
 }
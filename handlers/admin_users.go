@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"motzarella/database"
+)
+
+const (
+	defaultUsersPerPage = 50
+	maxUsersPerPage     = 200
+	// Longueur maximale du motif d'une suspension
+	maxSuspensionReasonLength = 500
+)
+
+// adminUserRoute est une action sur un utilisateur, protégée par sa propre permission
+type adminUserRoute struct {
+	permission string
+	handler    func(h *Handler, w http.ResponseWriter, r *http.Request, actor *database.User, target *database.AdminUser)
+}
+
+// Actions de /api/admin/users/{id}[/action], indexées par "MÉTHODE action"
+var adminUserRoutes = map[string]adminUserRoute{
+	"GET ":                {database.PermUsersRead, (*Handler).userDetailHandler},
+	"DELETE ":             {database.PermUsersManage, (*Handler).softDeleteUserHandler},
+	"POST restore":        {database.PermUsersManage, (*Handler).restoreUserHandler},
+	"PUT roles":           {database.PermRolesManage, (*Handler).userRolesHandler},
+	"POST admin":          {database.PermRolesManage, (*Handler).userAdminHandler},
+	"POST suspend":        {database.PermUsersManage, (*Handler).suspendUserHandler},
+	"DELETE suspend":      {database.PermUsersManage, (*Handler).liftSuspensionHandler},
+	"POST password-reset": {database.PermUsersManage, (*Handler).forcePasswordResetHandler},
+}
+
+// Handler pour lister les utilisateurs (/api/admin/users), paginé :
+// ?page=&limit=&q=&status=active|suspended|deleted|admins|all&sort=id|username|email|created_at&order=asc|desc
+func (h *Handler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := parsePositiveInt(query.Get("page"), 1)
+	limit := parsePositiveInt(query.Get("limit"), defaultUsersPerPage)
+	if limit > maxUsersPerPage {
+		limit = maxUsersPerPage
+	}
+
+	users, total, err := h.store.ListUsers(database.UserFilter{
+		Search: query.Get("q"),
+		Status: query.Get("status"),
+		Sort:   query.Get("sort"),
+		Desc:   query.Get("order") == "desc",
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	})
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des utilisateurs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users": users,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
+
+// AdminUserHandler aiguille les actions sur un utilisateur
+// (/api/admin/users/{id} et /api/admin/users/{id}/{action}) après avoir
+// vérifié la permission propre à chacune
+func (h *Handler) AdminUserHandler(w http.ResponseWriter, r *http.Request) {
+	// [, api, admin, users, ID] ou [, api, admin, users, ID, action]
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 || len(parts) > 6 {
+		http.Error(w, "Chemin invalide", http.StatusNotFound)
+		return
+	}
+	userID, err := strconv.Atoi(parts[4])
+	if err != nil || userID <= 0 {
+		http.Error(w, "ID utilisateur invalide", http.StatusBadRequest)
+		return
+	}
+	action := ""
+	if len(parts) == 6 {
+		action = parts[5]
+	}
+
+	route, ok := adminUserRoutes[r.Method+" "+action]
+	if !ok {
+		http.Error(w, "Chemin invalide", http.StatusNotFound)
+		return
+	}
+	actor := r.Context().Value("user").(*database.User)
+	if !actor.Can(route.permission) {
+		http.Error(w, "Accès non autorisé", http.StatusForbidden)
+		return
+	}
+
+	target, err := h.store.GetAdminUser(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de l'utilisateur", http.StatusInternalServerError)
+		return
+	}
+	if target == nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+
+	route.handler(h, w, r, actor, target)
+}
+
+// userDetailHandler renvoie un utilisateur avec ses statistiques
+func (h *Handler) userDetailHandler(w http.ResponseWriter, r *http.Request, actor *database.User, target *database.AdminUser) {
+	stats, err := h.store.GetUserStats(&target.User)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des statistiques", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user":  target,
+		"stats": stats,
+	})
+}
+
+// softDeleteUserHandler supprime un utilisateur en conservant ses données
+func (h *Handler) softDeleteUserHandler(w http.ResponseWriter, r *http.Request, actor *database.User, target *database.AdminUser) {
+	if target.ID == actor.ID {
+		http.Error(w, "Impossible de supprimer votre propre compte ici", http.StatusConflict)
+		return
+	}
+
+	err := h.store.SoftDeleteUser(target.ID)
+	if err != nil {
+		writeUserActionError(w, err, "Utilisateur déjà supprimé")
+		return
+	}
+	h.recordAudit(r, actor, "user.deleted", "user:"+target.Username, nil)
+	h.disconnectUser(target.ID, accountDeletedMessage)
+	h.writeAdminUser(w, target.ID)
+}
+
+// restoreUserHandler annule la suppression d'un utilisateur
+func (h *Handler) restoreUserHandler(w http.ResponseWriter, r *http.Request, actor *database.User, target *database.AdminUser) {
+	err := h.store.RestoreUser(target.ID)
+	if err != nil {
+		writeUserActionError(w, err, "Utilisateur non supprimé")
+		return
+	}
+	h.recordAudit(r, actor, "user.restored", "user:"+target.Username, nil)
+	h.writeAdminUser(w, target.ID)
+}
+
+// userRolesHandler remplace les rôles d'un utilisateur (corps {"roles": ["moderator"]})
+func (h *Handler) userRolesHandler(w http.ResponseWriter, r *http.Request, actor *database.User, target *database.AdminUser) {
+	var body struct {
+		Roles []string `json:"roles"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Roles == nil {
+		http.Error(w, "Liste de rôles attendue", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SetUserRoles(target.ID, body.Roles); err != nil {
+		writeUserActionError(w, err, "Utilisateur non trouvé")
+		return
+	}
+	h.recordAudit(r, actor, "user.roles_changed", "user:"+target.Username, map[string]interface{}{
+		"before": target.Roles,
+		"after":  body.Roles,
+	})
+	h.writeAdminUser(w, target.ID)
+}
+
+// userAdminHandler promeut ou rétrograde un administrateur (corps
+// {"admin": true}) en ajoutant ou retirant le rôle admin, sans toucher aux
+// autres rôles
+func (h *Handler) userAdminHandler(w http.ResponseWriter, r *http.Request, actor *database.User, target *database.AdminUser) {
+	var body struct {
+		Admin *bool `json:"admin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Admin == nil {
+		http.Error(w, "Champ admin attendu", http.StatusBadRequest)
+		return
+	}
+
+	roles := []string{}
+	for _, role := range target.Roles {
+		if role != database.AdminRole {
+			roles = append(roles, role)
+		}
+	}
+	if *body.Admin {
+		roles = append(roles, database.AdminRole)
+	}
+
+	if err := h.store.SetUserRoles(target.ID, roles); err != nil {
+		writeUserActionError(w, err, "Utilisateur non trouvé")
+		return
+	}
+	action := "user.demoted"
+	if *body.Admin {
+		action = "user.promoted"
+	}
+	h.recordAudit(r, actor, action, "user:"+target.Username, nil)
+	h.writeAdminUser(w, target.ID)
+}
+
+// suspendUserHandler suspend un utilisateur (corps {"reason", "until"} avec
+// une date RFC 3339, ou {"reason", "duration"} avec une durée comme "72h").
+// Sans date de fin, la suspension est définitive.
+func (h *Handler) suspendUserHandler(w http.ResponseWriter, r *http.Request, actor *database.User, target *database.AdminUser) {
+	var body struct {
+		Reason   string `json:"reason"`
+		Until    string `json:"until"`
+		Duration string `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Corps de requête invalide", http.StatusBadRequest)
+		return
+	}
+
+	reason := strings.TrimSpace(body.Reason)
+	if reason == "" || utf8.RuneCountInString(reason) > maxSuspensionReasonLength {
+		http.Error(w, "Le motif est obligatoire (500 caractères maximum)", http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt *time.Time
+	switch {
+	case body.Until != "" && body.Duration != "":
+		http.Error(w, "Indiquez until ou duration, pas les deux", http.StatusBadRequest)
+		return
+	case body.Until != "":
+		until, err := time.Parse(time.RFC3339, body.Until)
+		if err != nil || !until.After(time.Now()) {
+			http.Error(w, "Date de fin invalide", http.StatusBadRequest)
+			return
+		}
+		expiresAt = &until
+	case body.Duration != "":
+		duration, err := time.ParseDuration(body.Duration)
+		if err != nil || duration <= 0 {
+			http.Error(w, "Durée invalide", http.StatusBadRequest)
+			return
+		}
+		until := time.Now().Add(duration)
+		expiresAt = &until
+	}
+
+	if target.ID == actor.ID {
+		http.Error(w, "Impossible de suspendre votre propre compte", http.StatusConflict)
+		return
+	}
+
+	suspension, err := h.store.SuspendUser(target.ID, reason, expiresAt, actor.ID)
+	if err != nil {
+		writeUserActionError(w, err, "Utilisateur non trouvé")
+		return
+	}
+	h.recordAudit(r, actor, "user.suspended", "user:"+target.Username, map[string]interface{}{
+		"reason":     reason,
+		"expires_at": suspension.ExpiresAt,
+	})
+	h.disconnectUser(target.ID, SuspensionMessage(suspension))
+	h.writeAdminUser(w, target.ID)
+}
+
+// liftSuspensionHandler lève la suspension en cours d'un utilisateur
+func (h *Handler) liftSuspensionHandler(w http.ResponseWriter, r *http.Request, actor *database.User, target *database.AdminUser) {
+	err := h.store.LiftSuspension(target.ID, actor.ID)
+	if err != nil {
+		writeUserActionError(w, err, "Utilisateur non suspendu")
+		return
+	}
+	h.recordAudit(r, actor, "user.unsuspended", "user:"+target.Username, nil)
+	h.writeAdminUser(w, target.ID)
+}
+
+// forcePasswordResetHandler invalide le mot de passe d'un utilisateur,
+// révoque ses sessions et lui envoie un lien de réinitialisation
+func (h *Handler) forcePasswordResetHandler(w http.ResponseWriter, r *http.Request, actor *database.User, target *database.AdminUser) {
+	if target.DeletedAt != nil {
+		http.Error(w, "Utilisateur supprimé", http.StatusConflict)
+		return
+	}
+
+	// Le lien est envoyé avant de toucher au compte : en cas d'échec,
+	// l'utilisateur garde l'accès à son compte
+	if err := h.sendPasswordResetEmail(&target.User); err != nil {
+		log.Printf("Erreur lors de l'envoi de l'email de réinitialisation à %s: %v", target.Username, err)
+		http.Error(w, "Erreur lors de l'envoi de l'email de réinitialisation", http.StatusBadGateway)
+		return
+	}
+
+	unusable, err := unusablePassword()
+	if err != nil {
+		http.Error(w, "Erreur lors de la réinitialisation du mot de passe", http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.UpdatePassword(target.ID, unusable, false); err != nil {
+		http.Error(w, "Erreur lors de la réinitialisation du mot de passe", http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.RevokeUserSessions(target.ID); err != nil {
+		http.Error(w, "Erreur lors de la révocation des sessions", http.StatusInternalServerError)
+		return
+	}
+
+	h.recordAudit(r, actor, "user.password_reset", "user:"+target.Username, nil)
+	h.writeAdminUser(w, target.ID)
+}
+
+// writeUserActionError traduit les erreurs des actions d'administration.
+// sql.ErrNoRows signifie que l'action ne s'applique pas à l'utilisateur.
+func writeUserActionError(w http.ResponseWriter, err error, notFound string) {
+	switch err {
+	case sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusConflict)
+	case database.ErrUnknownRole:
+		http.Error(w, "Rôle inconnu", http.StatusBadRequest)
+	case database.ErrLastAdmin:
+		http.Error(w, "Impossible de retirer le dernier administrateur", http.StatusConflict)
+	default:
+		log.Printf("Erreur lors de l'action d'administration: %v", err)
+		http.Error(w, "Erreur lors de la mise à jour de l'utilisateur", http.StatusInternalServerError)
+	}
+}
+
+// writeAdminUser renvoie l'état à jour d'un utilisateur après une action
+func (h *Handler) writeAdminUser(w http.ResponseWriter, userID int) {
+	user, err := h.store.GetAdminUser(userID)
+	if err != nil || user == nil {
+		http.Error(w, "Erreur lors de la récupération de l'utilisateur", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
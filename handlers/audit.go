@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"motzarella/database"
+)
+
+// recordAudit inscrit une action au journal d'audit. actor vaut nil pour les
+// actions du système. Une erreur d'écriture est journalisée sans interrompre
+// la requête.
+func (h *Handler) recordAudit(r *http.Request, actor *database.User, action, target string, details map[string]interface{}) {
+	entry := &database.AuditEntry{
+		Action:  action,
+		Target:  target,
+		IP:      clientIP(r),
+		Details: details,
+	}
+	if actor != nil {
+		entry.ActorID = &actor.ID
+		entry.Actor = actor.Username
+	}
+	if err := h.store.AddAuditEntry(entry); err != nil {
+		log.Printf("Erreur lors de l'écriture du journal d'audit: %v", err)
+	}
+}
+
+// RecordAudit inscrit au journal d'audit une action traitée hors de ce paquet,
+// comme les actions du tableau de bord des parties en cours
+func (h *Handler) RecordAudit(r *http.Request, actor *database.User, action, target string, details map[string]interface{}) {
+	h.recordAudit(r, actor, action, target, details)
+}
+
+const (
+	defaultAuditPerPage = 50
+	maxAuditPerPage     = 500
+	// Nombre maximal d'entrées d'un export CSV
+	maxAuditExport = 50000
+)
+
+// AuditLogHandler consulte le journal d'audit (/api/admin/audit), paginé et
+// filtrable : ?action=user.*&actor=&target=&ip=&since=&until=&page=&limit=.
+// Avec ?format=csv, toutes les entrées filtrées sont exportées en CSV.
+func (h *Handler) AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := database.AuditFilter{
+		Action: query.Get("action"),
+		Actor:  query.Get("actor"),
+		Target: query.Get("target"),
+		IP:     query.Get("ip"),
+	}
+	for name, dest := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Date invalide pour "+name+" (format RFC 3339 attendu)", http.StatusBadRequest)
+				return
+			}
+			*dest = &t
+		}
+	}
+
+	if query.Get("format") == "csv" {
+		filter.Limit = maxAuditExport
+		entries, total, err := h.store.ListAuditEntries(filter)
+		if err != nil {
+			http.Error(w, "Erreur lors de la lecture du journal d'audit", http.StatusInternalServerError)
+			return
+		}
+		writeAuditCSV(w, entries, total)
+		return
+	}
+
+	page := parsePositiveInt(query.Get("page"), 1)
+	limit := parsePositiveInt(query.Get("limit"), defaultAuditPerPage)
+	if limit > maxAuditPerPage {
+		limit = maxAuditPerPage
+	}
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
+
+	entries, total, err := h.store.ListAuditEntries(filter)
+	if err != nil {
+		http.Error(w, "Erreur lors de la lecture du journal d'audit", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+// writeAuditCSV exporte des entrées du journal. X-Total-Count indique le
+// nombre d'entrées correspondant au filtre, qui peut dépasser l'export.
+func writeAuditCSV(w http.ResponseWriter, entries []database.AuditEntry, total int) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-`+time.Now().Format("20060102-150405")+`.csv"`)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "action", "actor_id", "actor", "target", "ip", "details"})
+	for _, entry := range entries {
+		actorID := ""
+		if entry.ActorID != nil {
+			actorID = strconv.Itoa(*entry.ActorID)
+		}
+		details := ""
+		if len(entry.Details) > 0 {
+			encoded, _ := json.Marshal(entry.Details)
+			details = string(encoded)
+		}
+		out.Write([]string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			csvCell(entry.Action),
+			actorID,
+			csvCell(entry.Actor),
+			csvCell(entry.Target),
+			csvCell(entry.IP),
+			csvCell(details),
+		})
+	}
+	out.Flush()
+}
+
+// csvCell neutralise les cellules qu'un tableur interpréterait comme une
+// formule (pseudo ou cible commençant par "=", "+", "-" ou "@")
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -3,33 +3,33 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"motzarella/database"
+	"motzarella/token"
+	"motzarella/validation"
 
-	"github.com/dgrijalva/jwt-go"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtKey = []byte("votre_clé_secrète_jwt") // À changer en production
-
 type Credentials struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Email    string `json:"email,omitempty"`
 }
 
-type Claims struct {
-	Username string `json:"username"`
-	IsAdmin  bool   `json:"is_admin"`
-	jwt.StandardClaims
-}
+var (
+	errInvalidToken   = errors.New("invalid token")
+	errSessionRevoked = errors.New("session revoked")
+	errUserNotFound   = errors.New("user not found")
+)
 
 // RegisterHandler gère l'inscription des utilisateurs
-func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	var creds Credentials
 	err := json.NewDecoder(r.Body).Decode(&creds)
 	if err != nil {
@@ -37,8 +37,14 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Validation des champs avant toute requête en base
+	if errs := validation.Registration(creds.Username, creds.Email, creds.Password); !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+
 	// Vérification si l'utilisateur existe déjà
-	existingUser, err := database.GetUserByUsername(creds.Username)
+	existingUser, err := h.store.GetUserByUsername(creds.Username)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -52,7 +58,7 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Vérification si l'email existe déjà
-	existingEmail, err := database.GetUserByEmail(creds.Email)
+	existingEmail, err := h.store.GetUserByEmail(creds.Email)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -73,37 +79,43 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Création de l'utilisateur
-	err = database.CreateUser(creds.Username, creds.Email, string(hashedPassword))
+	err = h.store.CreateUser(creds.Username, creds.Email, string(hashedPassword))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	// Création du token JWT
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		Username: creds.Username,
-		IsAdmin:  false,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+	user, err := h.store.GetUserByUsername(creds.Username)
+	if err != nil || user == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
-	if err != nil {
+	h.recordAudit(r, user, "user.registered", "user:"+user.Username, nil)
+
+	// L'échec de l'envoi n'empêche pas l'inscription : le lien peut être renvoyé
+	if err := h.sendVerificationEmail(user); err != nil {
+		log.Printf("Erreur lors de l'envoi de l'email de vérification à %s: %v", user.Username, err)
+	}
+
+	// Ouverture d'une session et création des tokens
+	if err := h.issueSession(w, r, user); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": tokenString,
+// writeValidationErrors renvoie les erreurs de validation, champ par champ
+func writeValidationErrors(w http.ResponseWriter, errs validation.Errors) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Certains champs sont invalides",
+		"fields": errs,
 	})
 }
 
 // LoginHandler gère la connexion des utilisateurs
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var creds Credentials
 	err := json.NewDecoder(r.Body).Decode(&creds)
 	if err != nil {
@@ -112,15 +124,26 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Limitation des tentatives par adresse et par compte
+	keys := loginLimitKeys(clientIP(r), creds.Username)
+	if wait := h.loginLimits.allow(keys, time.Now()); wait > 0 {
+		writeRetryAfter(w, wait)
+		return
+	}
+
 	// Récupération de l'utilisateur
-	user, err := database.GetUserByUsername(creds.Username)
+	user, err := h.store.GetUserByUsername(creds.Username)
 	if err != nil {
 		log.Printf("Erreur lors de la récupération de l'utilisateur: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	if user == nil {
-		log.Printf("Utilisateur non trouvé: %s", creds.Username)
+
+	// Vérification du mot de passe. Un compte inexistant ou supprimé compte
+	// comme un échec, pour ne pas révéler quels comptes existent.
+	if user == nil || user.DeletedAt != nil || database.TestPassword(user.Password, creds.Password) != nil {
+		h.recordAudit(r, nil, "login.failed", "user:"+creds.Username, nil)
+		h.auditLockouts(r, h.loginLimits.fail(keys, time.Now()))
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Identifiants invalides",
@@ -128,44 +151,92 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Vérification du mot de passe
-	err = database.TestPassword(user.Password, creds.Password)
-	if err != nil {
-		log.Printf("Mot de passe incorrect pour l'utilisateur %s: %v", creds.Username, err)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Identifiants invalides",
-		})
+	// Le motif d'une suspension n'est communiqué qu'avec le bon mot de passe
+	if h.accountBlocked(w, user) {
 		return
 	}
 
-	// Création du token JWT
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		Username: creds.Username,
-		IsAdmin:  user.IsAdmin,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+	// Avec la double authentification, la session n'est ouverte qu'après la
+	// vérification du code (MFALoginHandler)
+	mfa, err := h.store.GetUserMFA(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
+	if mfa != nil && mfa.Enabled {
+		if err := h.writeMFAChallenge(w, user); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	h.loginLimits.succeed("user:" + creds.Username)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
-	if err != nil {
+	// Ouverture d'une session et création des tokens
+	if err := h.issueSession(w, r, user); err != nil {
 		log.Printf("Erreur lors de la création du token: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	h.recordAudit(r, user, "login.succeeded", "user:"+user.Username, map[string]interface{}{
+		"method": "password",
+	})
 
 	log.Printf("Connexion réussie pour l'utilisateur %s (admin: %v)", creds.Username, user.IsAdmin)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": tokenString,
-	})
+}
+
+// AuthenticateToken vérifie un token d'accès, sa session et renvoie
+// l'utilisateur correspondant. Elle est aussi utilisée pour identifier les
+// joueurs à la connexion WebSocket.
+func (h *Handler) AuthenticateToken(tokenString string) (*database.User, *token.Claims, error) {
+	claims, err := h.tokens.Parse(tokenString)
+	if err != nil {
+		return nil, nil, errInvalidToken
+	}
+
+	// Les tokens sans session (émis avant les refresh tokens) ne sont plus acceptés
+	session, err := h.store.GetSession(claims.SessionID)
+	if err != nil || session == nil {
+		return nil, nil, errSessionRevoked
+	}
+
+	// La suspension révoque les sessions : elle est vérifiée avant pour que
+	// le client en reçoive le motif plutôt qu'une session expirée
+	suspension, err := h.store.GetActiveSuspension(session.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if suspension != nil {
+		return nil, nil, &SuspendedError{Suspension: suspension}
+	}
+	if !session.Active() {
+		return nil, nil, errSessionRevoked
+	}
+
+	// L'utilisateur est retrouvé par sa session : un token émis avant un
+	// changement de nom reste valide
+	user, err := h.store.GetUserByID(session.UserID)
+	if err != nil || user == nil || user.DeletedAt != nil {
+		return nil, nil, errUserNotFound
+	}
+
+	// Les permissions sont relues à chaque requête : un rôle retiré prend
+	// effet immédiatement
+	user.Permissions, err = h.store.GetUserPermissions(user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, claims, nil
+}
+
+// Routes accessibles à un compte qui doit changer son mot de passe
+var mustChangePasswordPaths = map[string]bool{
+	"/api/password/change": true,
+	"/api/logout":          true,
 }
 
 // AuthMiddleware vérifie si l'utilisateur est authentifié
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+func (h *Handler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
@@ -186,38 +257,46 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		tokenString := tokenParts[1]
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
-
-		if err != nil || !token.Valid {
+		user, claims, err := h.AuthenticateToken(tokenParts[1])
+		if suspended, ok := err.(*SuspendedError); ok {
+			writeSuspended(w, suspended.Suspension)
+			return
+		}
+		if err != nil {
+			message := "Token invalide"
+			switch err {
+			case errUserNotFound:
+				message = "Utilisateur non trouvé"
+			case errSessionRevoked:
+				message = "Session expirée ou révoquée"
+			}
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Token invalide",
+				"error": message,
 			})
 			return
 		}
 
-		// Ajouter l'utilisateur au contexte de la requête
-		user, err := database.GetUserByUsername(claims.Username)
-		if err != nil || user == nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Utilisateur non trouvé",
+		// Un compte dont le mot de passe doit être changé n'a accès qu'au
+		// changement de mot de passe et à la déconnexion
+		if user.MustChangePassword && !mustChangePasswordPaths[r.URL.Path] {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":                "Vous devez changer votre mot de passe",
+				"must_change_password": true,
 			})
 			return
 		}
 
-		// Stocker l'utilisateur dans le contexte
+		// Stocker l'utilisateur et sa session dans le contexte
 		ctx := context.WithValue(r.Context(), "user", user)
+		ctx = context.WithValue(ctx, "session_id", claims.SessionID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
 // ProfileHandler gère la récupération des informations du profil
-func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) ProfileHandler(w http.ResponseWriter, r *http.Request) {
 	// Récupérer l'utilisateur depuis le contexte (ajouté par le middleware)
 	user := r.Context().Value("user").(*database.User)
 	if user == nil {
@@ -228,12 +307,81 @@ func ProfileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	mfa, err := h.store.GetUserMFA(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	roles, err := h.store.GetUserRoles(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	// Renvoyer les informations du profil
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"username":   user.Username,
-		"email":      user.Email,
-		"created_at": user.CreatedAt,
-		"is_admin":   user.IsAdmin,
+		"username":       user.Username,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"created_at":     user.CreatedAt,
+		"is_admin":       user.IsAdmin,
+		"roles":          roles,
+		"permissions":    user.Permissions,
+		"avatar_url":     avatarURL(user),
+		"mfa_enabled":    mfa != nil && mfa.Enabled,
 	})
 }
+
+// ChangePasswordHandler permet à l'utilisateur connecté de changer son mot de
+// passe en fournissant l'actuel. Ses autres sessions sont révoquées.
+func (h *Handler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := r.Context().Value("user").(*database.User)
+	sessionID := r.Context().Value("session_id").(string)
+
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !h.verifyCurrentPassword(w, r, user, body.CurrentPassword) {
+		return
+	}
+
+	errs := validation.Errors{}
+	errs.Add("new_password", validation.Password(body.NewPassword, user.Username, user.Email))
+	if errs.Empty() && body.NewPassword == body.CurrentPassword {
+		errs.Add("new_password", "Le nouveau mot de passe doit être différent de l'actuel")
+	}
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.store.UpdatePassword(user.ID, hashedPassword, false); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.RevokeOtherSessions(user.ID, sessionID); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, user, "password.changed", "user:"+user.Username, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
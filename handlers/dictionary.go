@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"motzarella/database"
+	"motzarella/dictionary"
+)
+
+const (
+	defaultWordsPerPage = 100
+	maxWordsPerPage     = 500
+	// Taille maximale d'un fichier importé
+	maxDictionaryImportSize = 2 << 20
+	// Longueur maximale d'une définition ou d'un motif de signalement
+	maxDefinitionLength = 500
+)
+
+// LoadDictionary charge le dictionnaire en mémoire. Au premier démarrage, la
+// table est remplie avec la liste par défaut.
+func (h *Handler) LoadDictionary() error {
+	count, err := h.store.CountDictionaryWords()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		var words []database.DictionaryWord
+		for _, raw := range dictionary.DefaultWords {
+			word, err := dictionary.Normalize(raw)
+			if err != nil {
+				log.Printf("Mot par défaut ignoré: %s (%v)", raw, err)
+				continue
+			}
+			words = append(words, database.DictionaryWord{Word: word})
+		}
+		added, _, err := h.store.ImportDictionaryWords(words, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Dictionnaire initialisé avec %d mots", added)
+	}
+	return h.reloadDictionary()
+}
+
+// reloadDictionary remplace les mots utilisés par les parties par le contenu
+// de la base
+func (h *Handler) reloadDictionary() error {
+	return h.loadDictionaryWords(true)
+}
+
+// SyncDictionary recharge les mots utilisés par les parties si le
+// dictionnaire a été modifié depuis le dernier chargement, par exemple par un
+// autre serveur partageant la base
+func (h *Handler) SyncDictionary() error {
+	return h.loadDictionaryWords(false)
+}
+
+func (h *Handler) loadDictionaryWords(force bool) error {
+	h.dictionaryMu.Lock()
+	defer h.dictionaryMu.Unlock()
+
+	// La version est lue avant les mots : une modification faite entre les
+	// deux sera rechargée à la synchronisation suivante
+	version, err := h.store.DictionaryVersion()
+	if err != nil {
+		return err
+	}
+	if !force && version == h.dictionaryVersion {
+		return nil
+	}
+
+	entries, _, err := h.store.ListDictionaryWords(database.DictionaryFilter{})
+	if err != nil {
+		return err
+	}
+	words := make([]dictionary.Word, len(entries))
+	for i, entry := range entries {
+		words[i] = dictionary.Word{Word: entry.Word, Flagged: entry.Flagged}
+	}
+	h.words.Replace(words)
+	h.dictionaryVersion = version
+	return nil
+}
+
+// DictionaryHandler gère /api/admin/dictionary :
+// GET liste paginée (?page=&limit=&q=&flagged=true|false), POST ajout d'un mot
+// ({"word": "...", "definition": "..."})
+func (h *Handler) DictionaryHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listDictionaryHandler(w, r)
+	case http.MethodPost:
+		h.addWordHandler(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) listDictionaryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := parsePositiveInt(query.Get("page"), 1)
+	limit := parsePositiveInt(query.Get("limit"), defaultWordsPerPage)
+	if limit > maxWordsPerPage {
+		limit = maxWordsPerPage
+	}
+
+	filter := database.DictionaryFilter{
+		Search: strings.ToUpper(strings.TrimSpace(query.Get("q"))),
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+	switch query.Get("flagged") {
+	case "true":
+		flagged := true
+		filter.Flagged = &flagged
+	case "false":
+		flagged := false
+		filter.Flagged = &flagged
+	}
+
+	words, total, err := h.store.ListDictionaryWords(filter)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération du dictionnaire", http.StatusInternalServerError)
+		return
+	}
+	accepted, playable := h.words.Len()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"words":    words,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+		"accepted": accepted,
+		"playable": playable,
+	})
+}
+
+func (h *Handler) addWordHandler(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value("user").(*database.User)
+	var body struct {
+		Word       string `json:"word"`
+		Definition string `json:"definition"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Corps de requête invalide", http.StatusBadRequest)
+		return
+	}
+
+	word, err := dictionary.Normalize(body.Word)
+	if err != nil {
+		http.Error(w, wordErrorMessage(err), http.StatusBadRequest)
+		return
+	}
+	definition := strings.TrimSpace(body.Definition)
+	if utf8.RuneCountInString(definition) > maxDefinitionLength {
+		http.Error(w, "Définition trop longue", http.StatusBadRequest)
+		return
+	}
+
+	err = h.store.AddDictionaryWord(word, definition, &actor.ID)
+	if err == database.ErrWordExists {
+		http.Error(w, "Mot déjà présent dans le dictionnaire", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de l'ajout du mot %s: %v", word, err)
+		http.Error(w, "Erreur lors de l'ajout du mot", http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, actor, "dictionary.added", "word:"+word, nil)
+	h.writeDictionaryWord(w, word, http.StatusCreated)
+}
+
+// DictionaryWordHandler gère les actions sur un mot :
+// DELETE /api/admin/dictionary/{mot}, POST et DELETE /api/admin/dictionary/{mot}/flag
+// (corps {"reason": "..."} pour signaler), et l'import en masse
+// POST /api/admin/dictionary/import
+func (h *Handler) DictionaryWordHandler(w http.ResponseWriter, r *http.Request) {
+	// [, api, admin, dictionary, mot] ou [, api, admin, dictionary, mot, flag]
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 || len(parts) > 6 || parts[4] == "" {
+		http.Error(w, "Chemin invalide", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 5 && parts[4] == "import" {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h.importDictionaryHandler(w, r)
+		return
+	}
+
+	word, err := dictionary.Normalize(parts[4])
+	if err != nil {
+		http.Error(w, "Mot non trouvé", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 5 && r.Method == http.MethodDelete:
+		h.removeWordHandler(w, r, word)
+	case len(parts) == 6 && parts[5] == "flag" && r.Method == http.MethodPost:
+		h.flagWordHandler(w, r, word, true)
+	case len(parts) == 6 && parts[5] == "flag" && r.Method == http.MethodDelete:
+		h.flagWordHandler(w, r, word, false)
+	default:
+		http.Error(w, "Chemin invalide", http.StatusNotFound)
+	}
+}
+
+func (h *Handler) removeWordHandler(w http.ResponseWriter, r *http.Request, word string) {
+	actor := r.Context().Value("user").(*database.User)
+	if err := h.store.RemoveDictionaryWord(word); err != nil {
+		writeDictionaryError(w, err)
+		return
+	}
+	h.recordAudit(r, actor, "dictionary.removed", "word:"+word, nil)
+	if err := h.reloadDictionary(); err != nil {
+		log.Printf("Erreur lors du rechargement du dictionnaire: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// flagWordHandler signale un mot, qui n'est alors plus tiré comme mot
+// mystère, ou retire son signalement
+func (h *Handler) flagWordHandler(w http.ResponseWriter, r *http.Request, word string, flagged bool) {
+	actor := r.Context().Value("user").(*database.User)
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if flagged {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Motif du signalement attendu", http.StatusBadRequest)
+			return
+		}
+		body.Reason = strings.TrimSpace(body.Reason)
+		if body.Reason == "" || utf8.RuneCountInString(body.Reason) > maxDefinitionLength {
+			http.Error(w, "Motif du signalement attendu", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.store.SetWordFlag(word, flagged, body.Reason); err != nil {
+		writeDictionaryError(w, err)
+		return
+	}
+	if flagged {
+		h.recordAudit(r, actor, "dictionary.flagged", "word:"+word, map[string]interface{}{
+			"reason": body.Reason,
+		})
+	} else {
+		h.recordAudit(r, actor, "dictionary.unflagged", "word:"+word, nil)
+	}
+	h.writeDictionaryWord(w, word, http.StatusOK)
+}
+
+// importSkip décrit une ligne ignorée lors d'un import
+type importSkip struct {
+	Line  int    `json:"line"`
+	Word  string `json:"word"`
+	Error string `json:"error"`
+}
+
+// importDictionaryHandler ajoute des mots en masse. Le corps est soit du
+// texte avec un mot par ligne, soit du CSV (Content-Type text/csv) avec le
+// mot puis sa définition. Les lignes vides et celles commençant par # sont
+// ignorées.
+func (h *Handler) importDictionaryHandler(w http.ResponseWriter, r *http.Request) {
+	actor := r.Context().Value("user").(*database.User)
+	body := http.MaxBytesReader(w, r.Body, maxDictionaryImportSize)
+
+	// Champs de chaque ligne du fichier, avec son numéro
+	type importLine struct {
+		number int
+		fields []string
+	}
+	var lines []importLine
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		reader := csv.NewReader(body)
+		reader.FieldsPerRecord = -1
+		reader.Comment = '#'
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, "Fichier CSV invalide", http.StatusBadRequest)
+				return
+			}
+			line, _ := reader.FieldPos(0)
+			// Ligne d'en-tête facultative
+			if len(lines) == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "word") {
+				continue
+			}
+			lines = append(lines, importLine{line, record})
+		}
+	} else {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, "Fichier trop volumineux", http.StatusRequestEntityTooLarge)
+			return
+		}
+		for i, text := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(text), "#") {
+				continue
+			}
+			lines = append(lines, importLine{i + 1, []string{text}})
+		}
+	}
+
+	var words []database.DictionaryWord
+	skipped := []importSkip{}
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		record := line.fields
+		if strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		raw := strings.TrimSpace(record[0])
+		word, err := dictionary.Normalize(raw)
+		if err != nil {
+			skipped = append(skipped, importSkip{line.number, raw, wordErrorMessage(err)})
+			continue
+		}
+		if seen[word] {
+			skipped = append(skipped, importSkip{line.number, raw, "Mot en double dans le fichier"})
+			continue
+		}
+		definition := ""
+		if len(record) > 1 {
+			definition = strings.TrimSpace(record[1])
+		}
+		if utf8.RuneCountInString(definition) > maxDefinitionLength {
+			skipped = append(skipped, importSkip{line.number, raw, "Définition trop longue"})
+			continue
+		}
+		seen[word] = true
+		words = append(words, database.DictionaryWord{Word: word, Definition: definition})
+	}
+
+	added, updated, err := h.store.ImportDictionaryWords(words, &actor.ID)
+	if err != nil {
+		log.Printf("Erreur lors de l'import du dictionnaire: %v", err)
+		http.Error(w, "Erreur lors de l'import", http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, actor, "dictionary.imported", "", map[string]interface{}{
+		"added":   added,
+		"updated": updated,
+		"skipped": len(skipped),
+	})
+	if err := h.reloadDictionary(); err != nil {
+		log.Printf("Erreur lors du rechargement du dictionnaire: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"added":   added,
+		"updated": updated,
+		"skipped": skipped,
+	})
+}
+
+// writeDictionaryWord recharge le dictionnaire puis renvoie l'état à jour
+// d'un mot
+func (h *Handler) writeDictionaryWord(w http.ResponseWriter, word string, status int) {
+	if err := h.reloadDictionary(); err != nil {
+		log.Printf("Erreur lors du rechargement du dictionnaire: %v", err)
+	}
+	entry, err := h.store.GetDictionaryWord(word)
+	if err != nil || entry == nil {
+		http.Error(w, "Erreur lors de la récupération du mot", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(entry)
+}
+
+func writeDictionaryError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		http.Error(w, "Mot non trouvé", http.StatusNotFound)
+	case database.ErrLastPlayableWord:
+		http.Error(w, "Impossible de retirer le dernier mot jouable", http.StatusConflict)
+	default:
+		log.Printf("Erreur lors de la mise à jour du dictionnaire: %v", err)
+		http.Error(w, "Erreur lors de la mise à jour du dictionnaire", http.StatusInternalServerError)
+	}
+}
+
+// wordErrorMessage traduit une erreur de validation d'un mot
+func wordErrorMessage(err error) string {
+	if err == dictionary.ErrInvalidLength {
+		return fmt.Sprintf("Le mot doit contenir entre %d et %d lettres", dictionary.MinLength, dictionary.MaxLength)
+	}
+	return "Le mot ne doit contenir que des lettres"
+}
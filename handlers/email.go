@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"motzarella/database"
+	"motzarella/mailer"
+	"motzarella/token"
+	"motzarella/validation"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Durée de validité d'un lien de vérification d'email
+	verifyEmailTTL = 24 * time.Hour
+	// Durée de validité d'un lien de réinitialisation du mot de passe
+	passwordResetTTL = time.Hour
+)
+
+// sendActionEmail crée un token à usage unique et envoie le lien correspondant
+func (h *Handler) sendActionEmail(user *database.User, purpose, page string, ttl time.Duration, subject, body string) error {
+	now := time.Now()
+	emailToken := &database.EmailToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := h.store.CreateEmailToken(emailToken); err != nil {
+		return err
+	}
+
+	signed, err := h.tokens.IssueAction(purpose, user.ID, emailToken.ID, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/html/%s?token=%s", h.appURL, page, url.QueryEscape(signed))
+	return h.mail.Send(mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf(body, user.Username, link),
+	})
+}
+
+// sendVerificationEmail envoie le lien de vérification de l'adresse de l'utilisateur
+func (h *Handler) sendVerificationEmail(user *database.User) error {
+	return h.sendActionEmail(user, token.PurposeVerifyEmail, "verify-email.html", verifyEmailTTL,
+		"Confirmez votre adresse email",
+		"Bonjour %s,\n\nConfirmez votre adresse email en ouvrant ce lien (valable 24 heures) :\n%s\n\nSi vous n'êtes pas à l'origine de cette demande, ignorez cet email.\n")
+}
+
+// sendPasswordResetEmail envoie le lien de réinitialisation du mot de passe
+func (h *Handler) sendPasswordResetEmail(user *database.User) error {
+	return h.sendActionEmail(user, token.PurposePasswordReset, "reset-password.html", passwordResetTTL,
+		"Réinitialisation de votre mot de passe",
+		"Bonjour %s,\n\nPour choisir un nouveau mot de passe, ouvrez ce lien (valable 1 heure) :\n%s\n\nSi vous n'avez rien demandé, ignorez cet email : votre mot de passe reste inchangé.\n")
+}
+
+// consumeActionToken vérifie un token d'action et le marque comme utilisé.
+// Renvoie nil si le token est invalide, expiré, déjà utilisé ou si l'adresse
+// de l'utilisateur a changé depuis l'envoi.
+func (h *Handler) consumeActionToken(purpose, signed string, user *database.User) (*database.EmailToken, error) {
+	claims, err := h.tokens.ParseAction(purpose, signed)
+	if err != nil {
+		return nil, nil
+	}
+	emailToken, err := h.store.ConsumeEmailToken(claims.ID, purpose)
+	if err != nil || emailToken == nil {
+		return nil, err
+	}
+	if emailToken.UserID != user.ID || emailToken.Email != user.Email {
+		return nil, nil
+	}
+	return emailToken, nil
+}
+
+// actionTokenUser renvoie l'utilisateur désigné par un token d'action, sans le consommer
+func (h *Handler) actionTokenUser(purpose, signed string) (*database.User, error) {
+	claims, err := h.tokens.ParseAction(purpose, signed)
+	if err != nil {
+		return nil, nil
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, nil
+	}
+	user, err := h.store.GetUserByID(userID)
+	if err != nil || user == nil || user.DeletedAt != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func writeInvalidLink(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Lien invalide ou expiré",
+	})
+}
+
+// VerifyEmailHandler confirme l'adresse email d'un utilisateur (POST {"token"})
+func (h *Handler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.actionTokenUser(token.PurposeVerifyEmail, body.Token)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		writeInvalidLink(w)
+		return
+	}
+
+	emailToken, err := h.consumeActionToken(token.PurposeVerifyEmail, body.Token, user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if emailToken == nil {
+		writeInvalidLink(w)
+		return
+	}
+
+	if err := h.store.SetEmailVerified(user.ID, emailToken.Email); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ResendVerificationHandler renvoie le lien de vérification à l'utilisateur connecté
+func (h *Handler) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := r.Context().Value("user").(*database.User)
+	if user.EmailVerified {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Votre adresse email est déjà vérifiée",
+		})
+		return
+	}
+
+	if wait := h.loginLimits.allow(loginLimitKeys(clientIP(r), user.Username), time.Now()); wait > 0 {
+		writeRetryAfter(w, wait)
+		return
+	}
+
+	if err := h.sendVerificationEmail(user); err != nil {
+		log.Printf("Erreur lors de l'envoi de l'email de vérification à %s: %v", user.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ForgotPasswordHandler envoie un lien de réinitialisation (POST {"email"}).
+// La réponse est identique que l'adresse soit connue ou non.
+func (h *Handler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Les demandes sont limitées comme les connexions, pour éviter l'envoi
+	// massif d'emails
+	if wait := h.loginLimits.allow(loginLimitKeys(clientIP(r), "email:"+body.Email), time.Now()); wait > 0 {
+		writeRetryAfter(w, wait)
+		return
+	}
+
+	user, err := h.store.GetUserByEmail(body.Email)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user != nil && user.DeletedAt == nil {
+		if err := h.sendPasswordResetEmail(user); err != nil {
+			log.Printf("Erreur lors de l'envoi de l'email de réinitialisation à %s: %v", user.Username, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Si un compte utilise cette adresse, un lien de réinitialisation vient d'y être envoyé",
+	})
+}
+
+// ResetPasswordHandler remplace le mot de passe à partir d'un lien de
+// réinitialisation (POST {"token", "new_password"}) et révoque toutes les
+// sessions de l'utilisateur
+func (h *Handler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.actionTokenUser(token.PurposePasswordReset, body.Token)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		writeInvalidLink(w)
+		return
+	}
+
+	// Le mot de passe est validé avant de consommer le token, pour que
+	// l'utilisateur puisse corriger sa saisie
+	errs := validation.Errors{}
+	errs.Add("new_password", validation.Password(body.NewPassword, user.Username, user.Email))
+	if !errs.Empty() {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	emailToken, err := h.consumeActionToken(token.PurposePasswordReset, body.Token, user)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if emailToken == nil {
+		writeInvalidLink(w)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.UpdatePassword(user.ID, hashedPassword, false); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.RevokeUserSessions(user.ID); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, user, "password.reset", "user:"+user.Username, nil)
+
+	// Le lien a été reçu à cette adresse : elle est donc vérifiée, et le
+	// verrouillage éventuel du compte n'a plus lieu d'être
+	if err := h.store.SetEmailVerified(user.ID, emailToken.Email); err != nil {
+		log.Printf("Erreur lors de la vérification de l'email de %s: %v", user.Username, err)
+	}
+	h.loginLimits.succeed("user:" + user.Username)
+
+	w.WriteHeader(http.StatusOK)
+}
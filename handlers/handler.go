@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+
+	"motzarella/database"
+	"motzarella/dictionary"
+	"motzarella/mailer"
+	"motzarella/oidc"
+	"motzarella/token"
+)
+
+// Handler regroupe les handlers de l'API et leurs dépendances : base,
+// emails, clés des tokens, dictionnaire et dossier des avatars lui sont
+// fournis par New. En dehors de tables en lecture seule, il ne dépend
+// d'aucune variable globale : un test peut en créer un sur un
+// database.MemoryStore, un dictionnaire vide et un dossier temporaire.
+type Handler struct {
+	store  database.Store
+	mail   mailer.Mailer
+	tokens *token.Issuer
+	words  *dictionary.Store
+	// Adresse publique du site, utilisée dans les liens des emails
+	appURL    string
+	avatarDir string
+
+	// Fournisseurs d'identité configurés, indexés par nom
+	oidcProviders map[string]*oidc.Provider
+	oidcFlows     oidcFlowSet
+	loginLimits   *loginLimiter
+
+	// Version du dictionnaire chargée en mémoire, voir SyncDictionary
+	dictionaryMu      sync.Mutex
+	dictionaryVersion int64
+
+	// disconnectUser ferme les WebSockets ouvertes d'un utilisateur après lui
+	// avoir envoyé message. Fournie par le serveur de jeu via ConfigureDisconnect.
+	disconnectUser func(userID int, message map[string]interface{})
+}
+
+// Config décrit les dépendances des handlers
+type Config struct {
+	Store database.Store
+	// Envoi des emails, dont les liens pointent vers BaseURL
+	Mailer mailer.Mailer
+	// Signature et vérification des tokens d'accès et d'action
+	Tokens *token.Issuer
+	// Mots jouables, remplis par LoadDictionary et partagés avec les parties
+	Words *dictionary.Store
+	// Adresse publique du site
+	BaseURL string
+	// Dossier où sont enregistrés les avatars
+	AvatarDir string
+}
+
+// New crée les handlers de l'API
+func New(config Config) *Handler {
+	return &Handler{
+		store:          config.Store,
+		mail:           config.Mailer,
+		tokens:         config.Tokens,
+		words:          config.Words,
+		appURL:         strings.TrimSuffix(config.BaseURL, "/"),
+		avatarDir:      config.AvatarDir,
+		oidcProviders:  make(map[string]*oidc.Provider),
+		oidcFlows:      oidcFlowSet{m: make(map[string]oidcFlow)},
+		loginLimits:    newLoginLimiter(),
+		disconnectUser: func(userID int, message map[string]interface{}) {},
+	}
+}
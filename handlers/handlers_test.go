@@ -0,0 +1,305 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"motzarella/database"
+	"motzarella/dictionary"
+	"motzarella/handlers"
+	"motzarella/mailer"
+	"motzarella/token"
+)
+
+const testPassword = "Quatre-Chats-Verts9"
+
+// newTestServer monte les routes d'authentification sur un MemoryStore
+func newTestServer(t *testing.T) (*httptest.Server, database.Store) {
+	store := database.NewMemoryStore()
+	return newTestServerWithStore(t, store), store
+}
+
+// newTestAPI crée les handlers sur store, avec des dépendances de test
+func newTestAPI(t *testing.T, store database.Store) *handlers.Handler {
+	return handlers.New(handlers.Config{
+		Store:     store,
+		Mailer:    &mailer.LogMailer{},
+		Tokens:    token.NewIssuer(map[string][]byte{"test": []byte("secret-de-test")}, "test"),
+		Words:     dictionary.NewStore(),
+		BaseURL:   "http://localhost",
+		AvatarDir: t.TempDir(),
+	})
+}
+
+func newTestServerWithStore(t *testing.T, store database.Store) *httptest.Server {
+	return newTestServerWithAPI(t, newTestAPI(t, store))
+}
+
+func newTestServerWithAPI(t *testing.T, api *handlers.Handler) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/register", api.RegisterHandler)
+	mux.HandleFunc("/api/login", api.LoginHandler)
+	mux.HandleFunc("/api/login/mfa", api.MFALoginHandler)
+	mux.HandleFunc("/api/refresh", api.RefreshHandler)
+	mux.HandleFunc("/api/profile", api.AuthMiddleware(api.ProfileHandler))
+	mux.HandleFunc("/api/logout", api.AuthMiddleware(api.LogoutHandler))
+	mux.HandleFunc("/api/password/change", api.AuthMiddleware(api.ChangePasswordHandler))
+	mux.HandleFunc("/api/account/email", api.AuthMiddleware(api.ChangeEmailHandler))
+	mux.HandleFunc("/api/account", api.AuthMiddleware(api.DeleteAccountHandler))
+	mux.HandleFunc("/api/mfa/enroll", api.AuthMiddleware(api.MFAEnrollHandler))
+	mux.HandleFunc("/api/mfa/verify", api.AuthMiddleware(api.MFAVerifyHandler))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// call envoie une requête JSON et décode la réponse, si elle en contient une
+func call(t *testing.T, server *httptest.Server, method, path, accessToken string, body interface{}) (*http.Response, map[string]interface{}) {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var data map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&data)
+	return resp, data
+}
+
+// register crée un compte et renvoie ses tokens
+func register(t *testing.T, server *httptest.Server, username string) (string, string) {
+	t.Helper()
+	resp, data := call(t, server, http.MethodPost, "/api/register", "", map[string]string{
+		"username": username, "email": username + "@example.com", "password": testPassword,
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("inscription de %s: statut %d, %v", username, resp.StatusCode, data)
+	}
+	accessToken, _ := data["token"].(string)
+	refreshToken, _ := data["refresh_token"].(string)
+	if accessToken == "" || refreshToken == "" {
+		t.Fatalf("inscription de %s: tokens absents de %v", username, data)
+	}
+	return accessToken, refreshToken
+}
+
+func TestRegisterAndProfile(t *testing.T) {
+	server, _ := newTestServer(t)
+	accessToken, _ := register(t, server, "alice")
+
+	resp, data := call(t, server, http.MethodGet, "/api/profile", accessToken, nil)
+	if resp.StatusCode != http.StatusOK || data["username"] != "alice" {
+		t.Errorf("profil: statut %d, %v", resp.StatusCode, data)
+	}
+	if resp, _ := call(t, server, http.MethodGet, "/api/profile", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("profil sans token: statut %d, 401 attendu", resp.StatusCode)
+	}
+
+	if resp, _ := call(t, server, http.MethodPost, "/api/register", "", map[string]string{
+		"username": "alice", "email": "other@example.com", "password": testPassword,
+	}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("inscription d'un nom pris: statut %d, 409 attendu", resp.StatusCode)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	server, _ := newTestServer(t)
+	accessToken, refreshToken := register(t, server, "bob")
+
+	if resp, _ := call(t, server, http.MethodPost, "/api/logout", accessToken, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("déconnexion: statut %d", resp.StatusCode)
+	}
+	if resp, _ := call(t, server, http.MethodGet, "/api/profile", accessToken, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("token d'une session révoquée: statut %d, 401 attendu", resp.StatusCode)
+	}
+	if resp, _ := call(t, server, http.MethodPost, "/api/refresh", "", map[string]string{"refresh_token": refreshToken}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("refresh d'une session révoquée: statut %d, 401 attendu", resp.StatusCode)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	server, _ := newTestServer(t)
+	_, first := register(t, server, "carol")
+
+	resp, data := call(t, server, http.MethodPost, "/api/refresh", "", map[string]string{"refresh_token": first})
+	second, _ := data["refresh_token"].(string)
+	if resp.StatusCode != http.StatusOK || second == "" || second == first {
+		t.Fatalf("refresh: statut %d, %v", resp.StatusCode, data)
+	}
+
+	// L'ancien refresh token est refusé et révoque la session entière
+	if resp, _ := call(t, server, http.MethodPost, "/api/refresh", "", map[string]string{"refresh_token": first}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("réutilisation: statut %d, 401 attendu", resp.StatusCode)
+	}
+	if resp, _ := call(t, server, http.MethodPost, "/api/refresh", "", map[string]string{"refresh_token": second}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("refresh après réutilisation: statut %d, 401 attendu", resp.StatusCode)
+	}
+}
+
+// slowSessionStore ralentit la lecture des sessions, pour que des refresh
+// concurrents lisent tous la session avant que l'un d'eux ne la renouvelle
+type slowSessionStore struct {
+	database.Store
+}
+
+func (s slowSessionStore) GetSession(id string) (*database.Session, error) {
+	session, err := s.Store.GetSession(id)
+	time.Sleep(50 * time.Millisecond)
+	return session, err
+}
+
+func TestConcurrentRefreshRotatesOnce(t *testing.T) {
+	server := newTestServerWithStore(t, slowSessionStore{database.NewMemoryStore()})
+	_, refreshToken := register(t, server, "frank")
+
+	const attempts = 10
+	payload, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(server.URL+"/api/refresh", "application/json", bytes.NewReader(payload))
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	succeeded := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			succeeded++
+		case http.StatusUnauthorized:
+		default:
+			t.Errorf("refresh concurrent: statut %d", status)
+		}
+	}
+	if succeeded > 1 {
+		t.Errorf("refresh concurrent: %d renouvellements avec le même token, 1 au plus attendu", succeeded)
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	server, _ := newTestServer(t)
+	register(t, server, "dave")
+
+	var resp *http.Response
+	for i := 0; i < 20; i++ {
+		resp, _ = call(t, server, http.MethodPost, "/api/login", "", map[string]string{"username": "dave", "password": "mauvais"})
+		if resp.StatusCode != http.StatusUnauthorized {
+			break
+		}
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("échecs répétés: statut %d, Retry-After %q, 429 attendu", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestCurrentPasswordRateLimit(t *testing.T) {
+	routes := []struct {
+		method, path string
+		body         func(password string) map[string]string
+	}{
+		{http.MethodPost, "/api/password/change", func(password string) map[string]string {
+			return map[string]string{"current_password": password, "new_password": "Cinq-Loups-Gris42"}
+		}},
+		{http.MethodPost, "/api/account/email", func(password string) map[string]string {
+			return map[string]string{"email": "nouvelle@example.com", "password": password}
+		}},
+		{http.MethodDelete, "/api/account", func(password string) map[string]string {
+			return map[string]string{"password": password, "confirm": "gina"}
+		}},
+	}
+
+	for _, route := range routes {
+		t.Run(route.path, func(t *testing.T) {
+			server, _ := newTestServer(t)
+			accessToken, _ := register(t, server, "gina")
+
+			var resp *http.Response
+			for i := 0; i < 20; i++ {
+				resp, _ = call(t, server, route.method, route.path, accessToken, route.body("mauvais"))
+				if resp.StatusCode != http.StatusUnauthorized {
+					break
+				}
+			}
+			if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+				t.Fatalf("échecs répétés: statut %d, 429 attendu", resp.StatusCode)
+			}
+
+			// Le bon mot de passe est lui aussi refusé tant que la limite s'applique
+			if resp, _ := call(t, server, route.method, route.path, accessToken, route.body(testPassword)); resp.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("bon mot de passe pendant la limitation: statut %d, 429 attendu", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestDeleteAccountDisconnectsUser(t *testing.T) {
+	store := database.NewMemoryStore()
+	api := newTestAPI(t, store)
+	var disconnected []int
+	api.ConfigureDisconnect(func(userID int, message map[string]interface{}) {
+		if message["code"] == "account_deleted" {
+			disconnected = append(disconnected, userID)
+		}
+	})
+	server := newTestServerWithAPI(t, api)
+	accessToken, _ := register(t, server, "jade")
+	jade, _ := store.GetUserByUsername("jade")
+
+	resp, _ := call(t, server, http.MethodDelete, "/api/account", accessToken, map[string]string{"password": testPassword, "confirm": "jade"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("suppression du compte: statut %d", resp.StatusCode)
+	}
+	if len(disconnected) != 1 || disconnected[0] != jade.ID {
+		t.Errorf("utilisateurs déconnectés: %v, %d attendu", disconnected, jade.ID)
+	}
+}
+
+func TestSuspendedUserCannotLogIn(t *testing.T) {
+	server, store := newTestServer(t)
+	accessToken, _ := register(t, server, "erin")
+	register(t, server, "martin")
+	erin, _ := store.GetUserByUsername("erin")
+	moderator, _ := store.GetUserByUsername("martin")
+
+	if _, err := store.SuspendUser(erin.ID, "spam", nil, moderator.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, data := call(t, server, http.MethodPost, "/api/login", "", map[string]string{"username": "erin", "password": testPassword})
+	if resp.StatusCode != http.StatusForbidden || data["reason"] != "spam" {
+		t.Errorf("connexion suspendue: statut %d, %v, 403 attendu avec le motif", resp.StatusCode, data)
+	}
+	if resp, _ := call(t, server, http.MethodGet, "/api/profile", accessToken, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("token d'un compte suspendu: statut %d, 403 attendu", resp.StatusCode)
+	}
+}
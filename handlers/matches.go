@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"motzarella/database"
+)
+
+const (
+	defaultMatchesPerPage = 20
+	maxMatchesPerPage     = 100
+)
+
+// MatchHandler renvoie la chronologie complète d'une partie terminée
+// (/api/matches/{id}) pour permettre de la rejouer pas à pas
+func (h *Handler) MatchHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*database.User)
+
+	matchID := strings.TrimPrefix(r.URL.Path, "/api/matches/")
+	if matchID == "" || strings.Contains(matchID, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Identifiant de partie invalide",
+		})
+		return
+	}
+
+	match, err := h.store.GetMatch(matchID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Une partie privée n'est visible que par ses joueurs et les administrateurs
+	if match == nil || (match.Private && !user.Can(database.PermMatchesReadPrivate) && !isMatchPlayer(match, user.Username)) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Partie introuvable",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(match)
+}
+
+// UserMatchesHandler renvoie l'historique paginé d'un joueur
+// (/api/users/{username}/matches?page=1&limit=20)
+func (h *Handler) UserMatchesHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*database.User)
+
+	// [, api, users, username, matches]
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 || parts[3] == "" || parts[4] != "matches" {
+		http.NotFound(w, r)
+		return
+	}
+	username := parts[3]
+
+	page := parsePositiveInt(r.URL.Query().Get("page"), 1)
+	limit := parsePositiveInt(r.URL.Query().Get("limit"), defaultMatchesPerPage)
+	if limit > maxMatchesPerPage {
+		limit = maxMatchesPerPage
+	}
+
+	includePrivate := user.Can(database.PermMatchesReadPrivate) || user.Username == username
+	matches, total, err := h.store.GetUserMatches(username, includePrivate, limit, (page-1)*limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"matches": matches,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+func isMatchPlayer(match *database.Match, username string) bool {
+	for _, player := range match.Players {
+		if player.Username != "" && player.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePositiveInt renvoie la valeur entière strictement positive de s, ou def
+func parsePositiveInt(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"motzarella/database"
+	"motzarella/token"
+	"motzarella/totp"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Durée laissée pour saisir le code de double authentification
+	mfaPendingTTL = 5 * time.Minute
+	// Nombre de codes de secours générés
+	recoveryCodeCount = 10
+	// Émetteur affiché dans les applications d'authentification
+	mfaIssuer = "Motzarella"
+)
+
+// generateRecoveryCodes génère des codes de secours de la forme "xxxxx-xxxxx"
+// et leurs hachés à conserver en base
+func generateRecoveryCodes() ([]string, [][]byte, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hache un code de secours, sans tenir compte de la casse,
+// des tirets ni des espaces
+func hashRecoveryCode(code string) []byte {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(normalized))
+	return hash[:]
+}
+
+// checkMFACode vérifie un code TOTP ou, à défaut, un code de secours.
+// Chaque code ne peut servir qu'une fois.
+func (h *Handler) checkMFACode(r *http.Request, user *database.User, mfa *database.UserMFA, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := totp.Validate(mfa.Secret, code, time.Now(), mfa.LastStep)
+		if !ok {
+			return false, nil
+		}
+		return h.store.UseMFAStep(user.ID, step)
+	}
+
+	if recoveryCode != "" {
+		ok, err := h.store.UseRecoveryCode(user.ID, hashRecoveryCode(recoveryCode))
+		if err != nil || !ok {
+			return false, err
+		}
+		remaining, err := h.store.CountRecoveryCodes(user.ID)
+		if err != nil {
+			return false, err
+		}
+		h.recordAudit(r, user, "mfa.recovery_code_used", "user:"+user.Username, map[string]interface{}{
+			"remaining": remaining,
+		})
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func writeInvalidMFACode(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Code de vérification invalide",
+	})
+}
+
+// writeMFAChallenge répond à une connexion dont le mot de passe est correct
+// mais qui attend encore le code de double authentification
+func (h *Handler) writeMFAChallenge(w http.ResponseWriter, user *database.User) error {
+	pending, err := h.tokens.IssueAction(token.PurposeMFAPending, user.ID, uuid.New().String(), mfaPendingTTL)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mfa_required": true,
+		"mfa_token":    pending,
+		"expires_in":   int(mfaPendingTTL.Seconds()),
+	})
+	return nil
+}
+
+// MFALoginHandler termine une connexion avec double authentification
+// (POST {"mfa_token", "code"} ou {"mfa_token", "recovery_code"})
+func (h *Handler) MFALoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.actionTokenUser(token.PurposeMFAPending, body.MFAToken)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Connexion expirée, reconnectez-vous",
+		})
+		return
+	}
+	if h.accountBlocked(w, user) {
+		return
+	}
+
+	// Les codes erronés comptent comme des échecs de connexion
+	keys := loginLimitKeys(clientIP(r), user.Username)
+	if wait := h.loginLimits.allow(keys, time.Now()); wait > 0 {
+		writeRetryAfter(w, wait)
+		return
+	}
+
+	mfa, err := h.store.GetUserMFA(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if mfa == nil || !mfa.Enabled {
+		// Double authentification désactivée entre-temps : reconnexion nécessaire
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Connexion expirée, reconnectez-vous",
+		})
+		return
+	}
+
+	ok, err := h.checkMFACode(r, user, mfa, body.Code, body.RecoveryCode)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.recordAudit(r, nil, "login.failed", "user:"+user.Username, map[string]interface{}{
+			"method": "mfa",
+		})
+		h.auditLockouts(r, h.loginLimits.fail(keys, time.Now()))
+		writeInvalidMFACode(w)
+		return
+	}
+	h.loginLimits.succeed("user:" + user.Username)
+
+	if err := h.issueSession(w, r, user); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, user, "login.succeeded", "user:"+user.Username, map[string]interface{}{
+		"method": "mfa",
+	})
+}
+
+// MFAEnrollHandler génère un nouveau secret TOTP pour l'utilisateur connecté
+// (POST {"password"}) et renvoie l'URI otpauth:// à scanner. Le secret n'est
+// actif qu'après MFAVerifyHandler. Le mot de passe est exigé comme pour la
+// désactivation : un token d'accès volé ne permet pas d'enrôler le secret
+// d'un attaquant.
+func (h *Handler) MFAEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := r.Context().Value("user").(*database.User)
+
+	var body struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !h.verifyCurrentPassword(w, r, user, body.Password) {
+		return
+	}
+
+	mfa, err := h.store.GetUserMFA(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if mfa != nil && mfa.Enabled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "La double authentification est déjà activée",
+		})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.SetPendingMFA(user.ID, secret); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(secret, mfaIssuer, user.Username),
+	})
+}
+
+// MFAVerifyHandler active la double authentification après vérification du
+// premier code (POST {"code"}) et renvoie les codes de secours, affichés une
+// seule fois
+func (h *Handler) MFAVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := r.Context().Value("user").(*database.User)
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	mfa, err := h.store.GetUserMFA(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if mfa == nil || mfa.Enabled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Aucune activation de double authentification en cours",
+		})
+		return
+	}
+
+	// Les codes erronés comptent comme des échecs de connexion
+	keys := loginLimitKeys(clientIP(r), user.Username)
+	if wait := h.loginLimits.allow(keys, time.Now()); wait > 0 {
+		writeRetryAfter(w, wait)
+		return
+	}
+
+	step, ok := totp.Validate(mfa.Secret, body.Code, time.Now(), mfa.LastStep)
+	if !ok {
+		h.auditLockouts(r, h.loginLimits.fail(keys, time.Now()))
+		writeInvalidMFACode(w)
+		return
+	}
+	h.loginLimits.succeed("user:" + user.Username)
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.EnableMFA(user.ID, step, hashes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, user, "mfa.enabled", "user:"+user.Username, nil)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// MFARecoveryCodesHandler remplace les codes de secours (POST {"code"})
+func (h *Handler) MFARecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := r.Context().Value("user").(*database.User)
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Les codes erronés comptent comme des échecs de connexion : un token
+	// d'accès volé ne suffit pas à deviner le code
+	keys := loginLimitKeys(clientIP(r), user.Username)
+	if wait := h.loginLimits.allow(keys, time.Now()); wait > 0 {
+		writeRetryAfter(w, wait)
+		return
+	}
+
+	mfa, ok := h.requireMFA(w, user)
+	if !ok {
+		return
+	}
+	if valid, err := h.checkMFACode(r, user, mfa, body.Code, ""); err != nil || !valid {
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		h.auditLockouts(r, h.loginLimits.fail(keys, time.Now()))
+		writeInvalidMFACode(w)
+		return
+	}
+	h.loginLimits.succeed("user:" + user.Username)
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// MFADisableHandler désactive la double authentification
+// (POST {"password", "code"} ou {"password", "recovery_code"})
+func (h *Handler) MFADisableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := r.Context().Value("user").(*database.User)
+
+	var body struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Mot de passe et code erronés comptent comme des échecs de connexion
+	keys := loginLimitKeys(clientIP(r), user.Username)
+	if wait := h.loginLimits.allow(keys, time.Now()); wait > 0 {
+		writeRetryAfter(w, wait)
+		return
+	}
+
+	if !checkCurrentPassword(w, user, body.Password) {
+		h.auditLockouts(r, h.loginLimits.fail(keys, time.Now()))
+		return
+	}
+	mfa, ok := h.requireMFA(w, user)
+	if !ok {
+		return
+	}
+	valid, err := h.checkMFACode(r, user, mfa, body.Code, body.RecoveryCode)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		h.auditLockouts(r, h.loginLimits.fail(keys, time.Now()))
+		writeInvalidMFACode(w)
+		return
+	}
+	h.loginLimits.succeed("user:" + user.Username)
+
+	if err := h.store.DisableMFA(user.ID); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, user, "mfa.disabled", "user:"+user.Username, nil)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// requireMFA renvoie la double authentification active de l'utilisateur, ou
+// répond 409 s'il ne l'a pas activée
+func (h *Handler) requireMFA(w http.ResponseWriter, user *database.User) (*database.UserMFA, bool) {
+	mfa, err := h.store.GetUserMFA(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if mfa == nil || !mfa.Enabled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "La double authentification n'est pas activée",
+		})
+		return nil, false
+	}
+	return mfa, true
+}
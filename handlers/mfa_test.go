@@ -0,0 +1,98 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"motzarella/totp"
+)
+
+func TestMFAEnrollRequiresPassword(t *testing.T) {
+	server, store := newTestServer(t)
+	accessToken, _ := register(t, server, "hugo")
+	hugo, _ := store.GetUserByUsername("hugo")
+
+	for _, body := range []map[string]string{{}, {"password": "mauvais"}} {
+		if resp, _ := call(t, server, http.MethodPost, "/api/mfa/enroll", accessToken, body); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("enrôlement avec %v: statut %d, 401 attendu", body, resp.StatusCode)
+		}
+	}
+	if mfa, _ := store.GetUserMFA(hugo.ID); mfa != nil {
+		t.Fatalf("enrôlement refusé: secret %+v enregistré", mfa)
+	}
+
+	resp, data := call(t, server, http.MethodPost, "/api/mfa/enroll", accessToken, map[string]string{"password": testPassword})
+	if resp.StatusCode != http.StatusOK || data["secret"] == "" {
+		t.Fatalf("enrôlement: statut %d, %v", resp.StatusCode, data)
+	}
+	if mfa, _ := store.GetUserMFA(hugo.ID); mfa == nil || mfa.Enabled {
+		t.Errorf("enrôlement: %+v, secret en attente attendu", mfa)
+	}
+}
+
+func TestMFAVerifyRateLimit(t *testing.T) {
+	server, _ := newTestServer(t)
+	accessToken, _ := register(t, server, "iris")
+	if resp, _ := call(t, server, http.MethodPost, "/api/mfa/enroll", accessToken, map[string]string{"password": testPassword}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("enrôlement: statut %d", resp.StatusCode)
+	}
+
+	var resp *http.Response
+	for i := 0; i < 20; i++ {
+		resp, _ = call(t, server, http.MethodPost, "/api/mfa/verify", accessToken, map[string]string{"code": "000000"})
+		if resp.StatusCode != http.StatusUnauthorized {
+			break
+		}
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("codes erronés répétés: statut %d, 429 attendu", resp.StatusCode)
+	}
+}
+
+// enableMFA active la double authentification et renvoie les codes de secours
+func enableMFA(t *testing.T, server *httptest.Server, accessToken string) []string {
+	t.Helper()
+	resp, data := call(t, server, http.MethodPost, "/api/mfa/enroll", accessToken, map[string]string{"password": testPassword})
+	secret, _ := data["secret"].(string)
+	if resp.StatusCode != http.StatusOK || secret == "" {
+		t.Fatalf("enrôlement: statut %d, %v", resp.StatusCode, data)
+	}
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, data = call(t, server, http.MethodPost, "/api/mfa/verify", accessToken, map[string]string{"code": code})
+	list, _ := data["recovery_codes"].([]interface{})
+	if resp.StatusCode != http.StatusOK || len(list) == 0 {
+		t.Fatalf("activation: statut %d, %v", resp.StatusCode, data)
+	}
+	codes := make([]string, len(list))
+	for i, c := range list {
+		codes[i], _ = c.(string)
+	}
+	return codes
+}
+
+func TestRecoveryCodeIsSingleUse(t *testing.T) {
+	server, _ := newTestServer(t)
+	accessToken, _ := register(t, server, "karl")
+	recovery := enableMFA(t, server, accessToken)[0]
+
+	resp, data := call(t, server, http.MethodPost, "/api/login", "", map[string]string{"username": "karl", "password": testPassword})
+	pending, _ := data["mfa_token"].(string)
+	if resp.StatusCode != http.StatusOK || pending == "" {
+		t.Fatalf("connexion: statut %d, %v, mfa_token attendu", resp.StatusCode, data)
+	}
+
+	// Le même token de connexion en attente sert aux deux essais : seul le
+	// code de secours peut faire la différence
+	body := map[string]string{"mfa_token": pending, "recovery_code": recovery}
+	if resp, _ := call(t, server, http.MethodPost, "/api/login/mfa", "", body); resp.StatusCode != http.StatusOK {
+		t.Fatalf("premier usage du code de secours: statut %d", resp.StatusCode)
+	}
+	if resp, _ := call(t, server, http.MethodPost, "/api/login/mfa", "", body); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("deuxième usage du code de secours: statut %d, 401 attendu", resp.StatusCode)
+	}
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"motzarella/database"
+	"motzarella/oidc"
+	"motzarella/token"
+	"motzarella/validation"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Durée laissée pour se connecter chez le fournisseur d'identité
+	oidcFlowTTL = 10 * time.Minute
+	// Cookie liant le retour du fournisseur au navigateur qui a lancé la connexion
+	oidcStateCookie = "oidc_state"
+)
+
+var (
+	errOIDCEmailMissing  = errors.New("oidc: missing email")
+	errOIDCEmailConflict = errors.New("oidc: email already used by another account")
+
+	// Messages affichés sur la page de connexion
+	oidcUserErrors = map[error]string{
+		errOIDCEmailMissing:  "Le fournisseur n'a pas communiqué d'adresse email",
+		errOIDCEmailConflict: "Un compte utilise déjà cette adresse : connectez-vous avec votre mot de passe",
+	}
+)
+
+// oidcFlow est une connexion en cours chez un fournisseur
+type oidcFlow struct {
+	provider     string
+	nonce        string
+	codeVerifier string
+	expiresAt    time.Time
+}
+
+// oidcFlowSet contient les connexions en cours, indexées par state
+type oidcFlowSet struct {
+	sync.Mutex
+	m map[string]oidcFlow
+}
+
+// LoadOIDCProviders lit les fournisseurs d'identité dans l'environnement :
+//
+//	OIDC_PROVIDERS              noms des fournisseurs, "entreprise,google"
+//	OIDC_<NOM>_ISSUER           émetteur (URL du document de découverte sans /.well-known)
+//	OIDC_<NOM>_CLIENT_ID        identifiant du client
+//	OIDC_<NOM>_CLIENT_SECRET    secret du client, vide pour un client public
+//	OIDC_<NOM>_DISPLAY_NAME     nom affiché sur la page de connexion
+//	OIDC_<NOM>_SCOPES           scopes demandés, "openid email profile" par défaut
+//
+// L'adresse de retour est <adresse du site>/api/oidc/<nom>/callback.
+func (h *Handler) LoadOIDCProviders() error {
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return fmt.Errorf("nom de fournisseur OIDC invalide: %q", name)
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := oidc.Config{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  h.appURL + "/api/oidc/" + name + "/callback",
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return fmt.Errorf("%sISSUER et %sCLIENT_ID sont obligatoires", prefix, prefix)
+		}
+		if config.DisplayName == "" {
+			config.DisplayName = name
+		}
+		h.oidcProviders[name] = oidc.NewProvider(config)
+		log.Printf("Connexion OIDC activée : %s (%s)", name, config.Issuer)
+	}
+	return nil
+}
+
+// OIDCProvidersHandler liste les fournisseurs proposés sur la page de connexion
+func (h *Handler) OIDCProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	list := []map[string]string{}
+	for name, provider := range h.oidcProviders {
+		list = append(list, map[string]string{
+			"name":         name,
+			"display_name": provider.DisplayName,
+			"login_url":    "/api/oidc/" + name + "/login",
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i]["name"] < list[j]["name"] })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// OIDCHandler gère /api/oidc/{fournisseur}/login, qui redirige vers le
+// fournisseur, et /api/oidc/{fournisseur}/callback, qui termine la connexion
+func (h *Handler) OIDCHandler(w http.ResponseWriter, r *http.Request) {
+	// [, api, oidc, fournisseur, action]
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 5 || r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	provider, ok := h.oidcProviders[parts[3]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch parts[4] {
+	case "login":
+		h.startOIDCLogin(w, r, provider)
+	case "callback":
+		h.finishOIDCLogin(w, r, provider)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) startOIDCLogin(w http.ResponseWriter, r *http.Request, provider *oidc.Provider) {
+	state, err1 := oidc.RandomString()
+	nonce, err2 := oidc.RandomString()
+	verifier, err3 := oidc.RandomString()
+	if err1 != nil || err2 != nil || err3 != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("Fournisseur OIDC %s indisponible: %v", provider.Name, err)
+		redirectLoginError(w, r, "Le fournisseur d'identité est indisponible")
+		return
+	}
+
+	now := time.Now()
+	h.oidcFlows.Lock()
+	for key, flow := range h.oidcFlows.m {
+		if now.After(flow.expiresAt) {
+			delete(h.oidcFlows.m, key)
+		}
+	}
+	h.oidcFlows.m[state] = oidcFlow{
+		provider:     provider.Name,
+		nonce:        nonce,
+		codeVerifier: verifier,
+		expiresAt:    now.Add(oidcFlowTTL),
+	}
+	h.oidcFlows.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/oidc/",
+		MaxAge:   int(oidcFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.appURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *Handler) finishOIDCLogin(w http.ResponseWriter, r *http.Request, provider *oidc.Provider) {
+	// Le cookie n'est valable que pour une tentative
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/oidc/", MaxAge: -1})
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		redirectLoginError(w, r, "Connexion refusée par le fournisseur d'identité")
+		return
+	}
+
+	// Le state doit correspondre au cookie du navigateur (protection CSRF)
+	// et à une connexion en cours, utilisable une seule fois
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		redirectLoginError(w, r, "Connexion expirée, veuillez réessayer")
+		return
+	}
+	h.oidcFlows.Lock()
+	flow, ok := h.oidcFlows.m[state]
+	delete(h.oidcFlows.m, state)
+	h.oidcFlows.Unlock()
+	if !ok || flow.provider != provider.Name || time.Now().After(flow.expiresAt) {
+		redirectLoginError(w, r, "Connexion expirée, veuillez réessayer")
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), flow.codeVerifier, flow.nonce)
+	if err != nil {
+		log.Printf("Échec de la connexion OIDC via %s: %v", provider.Name, err)
+		redirectLoginError(w, r, "Échec de la connexion avec le fournisseur d'identité")
+		return
+	}
+
+	user, err := h.resolveOIDCUser(r, provider.Name, claims)
+	if message, ok := oidcUserErrors[err]; ok {
+		redirectLoginError(w, r, message)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors du rattachement de l'identité OIDC %s/%s: %v", provider.Name, claims.Subject, err)
+		redirectLoginError(w, r, "Erreur lors de la connexion")
+		return
+	}
+	if user.DeletedAt != nil {
+		redirectLoginError(w, r, "Ce compte a été supprimé")
+		return
+	}
+	suspension, err := h.store.GetActiveSuspension(user.ID)
+	if err != nil {
+		redirectLoginError(w, r, "Erreur lors de la connexion")
+		return
+	}
+	if suspension != nil {
+		redirectLoginError(w, r, "Compte suspendu : "+suspension.Reason)
+		return
+	}
+
+	// Les tokens sont transmis dans le fragment de l'URL, qui n'est jamais
+	// envoyé au serveur ni conservé dans les logs
+	fragment := url.Values{}
+	mfa, err := h.store.GetUserMFA(user.ID)
+	if err != nil {
+		redirectLoginError(w, r, "Erreur lors de la connexion")
+		return
+	}
+	if mfa != nil && mfa.Enabled {
+		pending, err := h.tokens.IssueAction(token.PurposeMFAPending, user.ID, uuid.New().String(), mfaPendingTTL)
+		if err != nil {
+			redirectLoginError(w, r, "Erreur lors de la connexion")
+			return
+		}
+		fragment.Set("mfa_token", pending)
+	} else {
+		sessionID, refreshToken, err := h.openSession(r, user)
+		if err != nil {
+			redirectLoginError(w, r, "Erreur lors de la connexion")
+			return
+		}
+		accessToken, err := h.tokens.Issue(user.Username, user.IsAdmin, sessionID, accessTokenTTL)
+		if err != nil {
+			redirectLoginError(w, r, "Erreur lors de la connexion")
+			return
+		}
+		fragment.Set("token", accessToken)
+		fragment.Set("refresh_token", refreshToken)
+		fragment.Set("must_change_password", strconv.FormatBool(user.MustChangePassword))
+		h.recordAudit(r, user, "login.succeeded", "user:"+user.Username, map[string]interface{}{
+			"method":   "oidc",
+			"provider": provider.Name,
+		})
+	}
+
+	log.Printf("Connexion OIDC réussie pour l'utilisateur %s via %s", user.Username, provider.Name)
+	http.Redirect(w, r, "/html/login.html#"+fragment.Encode(), http.StatusFound)
+}
+
+// resolveOIDCUser renvoie le compte rattaché à une identité externe. À la
+// première connexion, l'identité est rattachée au compte qui a la même
+// adresse si les deux côtés l'ont vérifiée, sinon un compte est créé.
+func (h *Handler) resolveOIDCUser(r *http.Request, provider string, claims *oidc.IDClaims) (*database.User, error) {
+	user, err := h.store.GetUserByIdentity(provider, claims.Subject)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	if claims.Email == "" || validation.Email(claims.Email) != "" {
+		return nil, errOIDCEmailMissing
+	}
+
+	existing, err := h.store.GetUserByEmail(claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// Sans double vérification, rattacher l'identité permettrait de
+		// prendre le contrôle d'un compte avec une adresse non prouvée
+		if !claims.EmailVerified || !existing.EmailVerified {
+			return nil, errOIDCEmailConflict
+		}
+		if err := h.store.LinkIdentity(existing.ID, provider, claims.Subject, claims.Email); err != nil {
+			return nil, err
+		}
+		h.recordAudit(r, existing, "oidc.linked", "user:"+existing.Username, map[string]interface{}{
+			"provider": provider,
+			"subject":  claims.Subject,
+		})
+		return existing, nil
+	}
+
+	hashedPassword, err := unusablePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	base := oidcUsername(claims)
+	for attempt := 0; attempt < 20; attempt++ {
+		username := base
+		if attempt > 0 {
+			suffix := strconv.Itoa(attempt + 1)
+			if len(username)+len(suffix) > validation.MaxUsernameLength {
+				username = username[:validation.MaxUsernameLength-len(suffix)]
+			}
+			username += suffix
+		}
+
+		user, err := h.store.CreateExternalUser(username, claims.Email, claims.EmailVerified, hashedPassword, provider, claims.Subject)
+		if err == database.ErrUsernameTaken {
+			continue
+		}
+		if err == database.ErrEmailTaken {
+			return nil, errOIDCEmailConflict
+		}
+		if err != nil {
+			return nil, err
+		}
+		h.recordAudit(r, user, "oidc.registered", "user:"+user.Username, map[string]interface{}{
+			"provider": provider,
+			"subject":  claims.Subject,
+		})
+		return user, nil
+	}
+	return nil, database.ErrUsernameTaken
+}
+
+// oidcUsername propose un pseudo valide à partir de l'identité externe
+func oidcUsername(claims *oidc.IDClaims) string {
+	candidate := claims.PreferredUsername
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	username := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		}
+		return -1
+	}, candidate)
+	if len(username) > validation.MaxUsernameLength {
+		username = username[:validation.MaxUsernameLength]
+	}
+	if validation.Username(username) != "" {
+		username = "joueur"
+	}
+	return username
+}
+
+// redirectLoginError renvoie le navigateur sur la page de connexion avec un message
+func redirectLoginError(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, "/html/login.html?error="+url.QueryEscape(message), http.StatusFound)
+}
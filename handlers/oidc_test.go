@@ -0,0 +1,246 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"motzarella/database"
+	"motzarella/oidc"
+)
+
+// oidcTest monte la connexion OIDC sur un MockIssuer servi par httptest. Le
+// client joue le navigateur : il ne suit pas les redirections, pour que le
+// test puisse modifier chaque étape.
+type oidcTest struct {
+	server *httptest.Server
+	issuer *httptest.Server
+	store  database.Store
+	client *http.Client
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	var mock *oidc.MockIssuer
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(issuer.Close)
+	mock, err := oidc.NewMockIssuer(issuer.URL, "motzarella")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("OIDC_PROVIDERS", "mock")
+	t.Setenv("OIDC_MOCK_ISSUER", issuer.URL)
+	t.Setenv("OIDC_MOCK_CLIENT_ID", "motzarella")
+	store := database.NewMemoryStore()
+	api := newTestAPI(t, store)
+	if err := api.LoadOIDCProviders(); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/oidc/", api.OIDCHandler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &oidcTest{
+		server: server,
+		issuer: issuer,
+		store:  store,
+		client: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+	}
+}
+
+// redirect envoie une requête et renvoie l'adresse vers laquelle la réponse redirige
+func (o *oidcTest) redirect(t *testing.T, req *http.Request) (*url.URL, *http.Response) {
+	t.Helper()
+	resp, err := o.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatalf("%s %s: statut %d sans redirection", req.Method, req.URL.Path, resp.StatusCode)
+	}
+	return location, resp
+}
+
+// start lance une connexion et renvoie les paramètres d'autorisation envoyés
+// au fournisseur, avec le cookie de state posé sur le navigateur
+func (o *oidcTest) start(t *testing.T) (url.Values, *http.Cookie) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, o.server.URL+"/api/oidc/mock/login", nil)
+	location, resp := o.redirect(t, req)
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "oidc_state" {
+			return location.Query(), cookie
+		}
+	}
+	t.Fatal("connexion OIDC: cookie de state absent")
+	return nil, nil
+}
+
+// authorize se connecte chez le fournisseur avec les paramètres donnés et
+// renvoie ceux du retour vers le site (code et state)
+func (o *oidcTest) authorize(t *testing.T, params url.Values, username, email string) url.Values {
+	t.Helper()
+	form := url.Values{}
+	for name, values := range params {
+		form[name] = values
+	}
+	form.Set("username", username)
+	form.Set("email", email)
+
+	resp, err := o.client.PostForm(o.issuer.URL+"/authorize", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatalf("autorisation: statut %d sans redirection", resp.StatusCode)
+	}
+	return location.Query()
+}
+
+// callback termine la connexion et renvoie la redirection vers la page de
+// connexion : tokens dans le fragment, ou message dans le paramètre error
+func (o *oidcTest) callback(t *testing.T, query url.Values, cookie *http.Cookie) *url.URL {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, o.server.URL+"/api/oidc/mock/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	location, _ := o.redirect(t, req)
+	return location
+}
+
+// login mène une connexion complète sans rien modifier
+func (o *oidcTest) login(t *testing.T, username, email string) *url.URL {
+	t.Helper()
+	params, cookie := o.start(t)
+	return o.callback(t, o.authorize(t, params, username, email), cookie)
+}
+
+// expectLoggedIn vérifie que la connexion a abouti et renvoie les tokens
+func expectLoggedIn(t *testing.T, location *url.URL) url.Values {
+	t.Helper()
+	fragment, _ := url.ParseQuery(location.Fragment)
+	if location.Path != "/html/login.html" || fragment.Get("token") == "" || fragment.Get("refresh_token") == "" {
+		t.Fatalf("connexion OIDC: redirection vers %s, tokens attendus", location)
+	}
+	return fragment
+}
+
+// expectLoginError vérifie que la connexion a été refusée avec le message attendu
+func expectLoginError(t *testing.T, location *url.URL, message string) {
+	t.Helper()
+	if location.Path != "/html/login.html" || location.Fragment != "" || location.Query().Get("error") != message {
+		t.Errorf("connexion OIDC: redirection vers %s, erreur %q attendue", location, message)
+	}
+}
+
+func TestOIDCLoginCreatesAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	expectLoggedIn(t, o.login(t, "gaston", "gaston@example.com"))
+
+	user, err := o.store.GetUserByIdentity("mock", "mock|gaston")
+	if err != nil || user == nil || user.Username != "gaston" || !user.EmailVerified {
+		t.Fatalf("compte créé: %+v, %v", user, err)
+	}
+
+	// La connexion suivante retrouve le même compte
+	expectLoggedIn(t, o.login(t, "gaston", "gaston@example.com"))
+	if again, _ := o.store.GetUserByIdentity("mock", "mock|gaston"); again == nil || again.ID != user.ID {
+		t.Errorf("deuxième connexion: compte %+v, %d attendu", again, user.ID)
+	}
+}
+
+func TestOIDCCallbackRejectsWrongState(t *testing.T) {
+	o := newOIDCTest(t)
+
+	// Retour dont le state ne correspond pas au cookie du navigateur
+	params, cookie := o.start(t)
+	query := o.authorize(t, params, "hector", "hector@example.com")
+	query.Set("state", "autre-state")
+	expectLoginError(t, o.callback(t, query, cookie), "Connexion expirée, veuillez réessayer")
+
+	// Retour sans cookie
+	params, _ = o.start(t)
+	expectLoginError(t, o.callback(t, o.authorize(t, params, "hector", "hector@example.com"), nil), "Connexion expirée, veuillez réessayer")
+
+	// State et cookie cohérents, mais qui ne correspondent à aucune connexion lancée ici
+	params, _ = o.start(t)
+	query = o.authorize(t, params, "hector", "hector@example.com")
+	query.Set("state", "state-forge")
+	expectLoginError(t, o.callback(t, query, &http.Cookie{Name: "oidc_state", Value: "state-forge"}), "Connexion expirée, veuillez réessayer")
+
+	// Un state ne sert qu'une fois
+	params, cookie = o.start(t)
+	query = o.authorize(t, params, "hector", "hector@example.com")
+	expectLoggedIn(t, o.callback(t, query, cookie))
+	expectLoginError(t, o.callback(t, query, cookie), "Connexion expirée, veuillez réessayer")
+}
+
+func TestOIDCCallbackRejectsWrongNonce(t *testing.T) {
+	o := newOIDCTest(t)
+
+	// Le jeton d'identité porte un nonce différent de celui de la connexion
+	params, cookie := o.start(t)
+	params.Set("nonce", "autre-nonce")
+	location := o.callback(t, o.authorize(t, params, "ines", "ines@example.com"), cookie)
+	expectLoginError(t, location, "Échec de la connexion avec le fournisseur d'identité")
+
+	if user, _ := o.store.GetUserByIdentity("mock", "mock|ines"); user != nil {
+		t.Errorf("nonce invalide: compte %s créé", user.Username)
+	}
+}
+
+func TestOIDCCallbackRejectsWrongCodeVerifier(t *testing.T) {
+	o := newOIDCTest(t)
+
+	// Le code a été obtenu avec le challenge d'un autre verifier que celui
+	// de la connexion : le fournisseur refuse de l'échanger
+	params, cookie := o.start(t)
+	params.Set("code_challenge", oidc.CodeChallenge("autre-verifier"))
+	location := o.callback(t, o.authorize(t, params, "jules", "jules@example.com"), cookie)
+	expectLoginError(t, location, "Échec de la connexion avec le fournisseur d'identité")
+
+	if user, _ := o.store.GetUserByIdentity("mock", "mock|jules"); user != nil {
+		t.Errorf("verifier invalide: compte %s créé", user.Username)
+	}
+}
+
+func TestOIDCLinksAccountByVerifiedEmail(t *testing.T) {
+	o := newOIDCTest(t)
+	for _, username := range []string{"karim", "lena"} {
+		if err := o.store.CreateUser(username, username+"@example.com", "hash"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	karim, _ := o.store.GetUserByUsername("karim")
+	if err := o.store.SetEmailVerified(karim.ID, karim.Email); err != nil {
+		t.Fatal(err)
+	}
+
+	// Adresse vérifiée des deux côtés : l'identité est rattachée au compte
+	expectLoggedIn(t, o.login(t, "karim-sso", "karim@example.com"))
+	if user, err := o.store.GetUserByIdentity("mock", "mock|karim-sso"); err != nil || user == nil || user.ID != karim.ID {
+		t.Errorf("identité rattachée à %+v, %v, compte %d attendu", user, err, karim.ID)
+	}
+	if user, _ := o.store.GetUserByUsername("karim-sso"); user != nil {
+		t.Errorf("compte %s créé au lieu du rattachement", user.Username)
+	}
+
+	// Adresse non vérifiée sur le compte local : pas de rattachement
+	expectLoginError(t, o.login(t, "lena-sso", "lena@example.com"),
+		"Un compte utilise déjà cette adresse : connectez-vous avec votre mot de passe")
+	if user, _ := o.store.GetUserByIdentity("mock", "mock|lena-sso"); user != nil {
+		t.Errorf("identité rattachée au compte non vérifié %s", user.Username)
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"motzarella/database"
+)
+
+// limitPolicy décrit la limitation appliquée à un compte ou à une adresse
+type limitPolicy struct {
+	// Tentatives autorisées d'affilée, puis une tentative regagnée par refill
+	burst  float64
+	refill time.Duration
+	// Nombre d'échecs à partir duquel chaque tentative est retardée
+	delayAfter int
+	// Nombre d'échecs entraînant un verrouillage temporaire
+	lockAfter int
+}
+
+var (
+	accountLimitPolicy = limitPolicy{burst: 5, refill: 12 * time.Second, delayAfter: 3, lockAfter: 10}
+	ipLimitPolicy      = limitPolicy{burst: 20, refill: 3 * time.Second, delayAfter: 10, lockAfter: 50}
+)
+
+const (
+	// Délai maximal imposé entre deux tentatives avant le verrouillage
+	maxLoginDelay = time.Minute
+	// Durée d'un verrouillage
+	loginLockoutDuration = 15 * time.Minute
+	// Les échecs plus anciens sont oubliés
+	loginFailureWindow = 15 * time.Minute
+	// Fréquence du nettoyage des compteurs inutiles
+	loginPruneInterval = 10 * time.Minute
+)
+
+// limitKey identifie un compte ("user:<nom>") ou une adresse ("ip:<adresse>")
+type limitKey struct {
+	key    string
+	policy limitPolicy
+}
+
+func loginLimitKeys(ip, username string) []limitKey {
+	return []limitKey{
+		{key: "ip:" + ip, policy: ipLimitPolicy},
+		{key: "user:" + username, policy: accountLimitPolicy},
+	}
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill ajoute les tentatives regagnées depuis la dernière mise à jour
+func (b *tokenBucket) refill(policy limitPolicy, now time.Time) {
+	b.tokens = math.Min(policy.burst, b.tokens+now.Sub(b.updated).Seconds()/policy.refill.Seconds())
+	b.updated = now
+}
+
+// loginLimiter limite les tentatives de connexion par adresse et par compte :
+// un token bucket limite le débit, et les échecs répétés imposent un délai
+// croissant puis un verrouillage temporaire.
+type loginLimiter struct {
+	mu       sync.Mutex
+	buckets  map[string]*tokenBucket
+	failures map[string]*database.LoginFailures
+	// Stockage des échecs, nil s'ils ne sont gardés qu'en mémoire
+	store     database.LoginFailureStore
+	lastPrune time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		failures:  make(map[string]*database.LoginFailures),
+		lastPrune: time.Now(),
+	}
+}
+
+// LoadLoginLimiter active la persistance des échecs de connexion en base si
+// LOGIN_LIMIT_PERSIST est positionné, et recharge les verrouillages en cours
+func (h *Handler) LoadLoginLimiter() error {
+	persist, _ := strconv.ParseBool(os.Getenv("LOGIN_LIMIT_PERSIST"))
+	if !persist {
+		return nil
+	}
+
+	list, err := h.store.GetLoginFailures(time.Now().Add(-loginFailureWindow))
+	if err != nil {
+		return err
+	}
+
+	h.loginLimits.mu.Lock()
+	defer h.loginLimits.mu.Unlock()
+	h.loginLimits.store = h.store
+	for i := range list {
+		h.loginLimits.failures[list[i].Key] = &list[i]
+	}
+	return nil
+}
+
+// failureWait renvoie l'attente imposée par les échecs d'une clé
+func failureWait(f *database.LoginFailures, policy limitPolicy, now time.Time) time.Duration {
+	if now.Before(f.LockedUntil) {
+		return f.LockedUntil.Sub(now)
+	}
+	if f.Failures < policy.delayAfter {
+		return 0
+	}
+
+	// Le délai double à chaque échec : 1s, 2s, 4s... jusqu'à maxLoginDelay
+	delay := maxLoginDelay
+	if shift := f.Failures - policy.delayAfter; shift < 6 {
+		delay = time.Second << shift
+		if delay > maxLoginDelay {
+			delay = maxLoginDelay
+		}
+	}
+	if wait := f.LastFailure.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// currentFailures renvoie les échecs encore pris en compte pour une clé
+func (l *loginLimiter) currentFailures(key string, now time.Time) *database.LoginFailures {
+	f := l.failures[key]
+	if f == nil {
+		return nil
+	}
+	if now.Before(f.LockedUntil) {
+		return f
+	}
+	// Verrouillage terminé ou échecs trop anciens : le compteur repart de zéro
+	if !f.LockedUntil.IsZero() || now.Sub(f.LastFailure) > loginFailureWindow {
+		delete(l.failures, key)
+		return nil
+	}
+	return f
+}
+
+// allow consomme une tentative pour chaque clé, ou renvoie l'attente
+// nécessaire avant la prochaine tentative autorisée
+func (l *loginLimiter) allow(keys []limitKey, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	var wait time.Duration
+	for _, k := range keys {
+		if f := l.currentFailures(k.key, now); f != nil {
+			if w := failureWait(f, k.policy, now); w > wait {
+				wait = w
+			}
+		}
+
+		b := l.buckets[k.key]
+		if b == nil {
+			b = &tokenBucket{tokens: k.policy.burst, updated: now}
+			l.buckets[k.key] = b
+		}
+		b.refill(k.policy, now)
+		if b.tokens < 1 {
+			w := time.Duration((1 - b.tokens) * float64(k.policy.refill))
+			if w > wait {
+				wait = w
+			}
+		}
+	}
+
+	if wait > 0 {
+		return wait
+	}
+	for _, k := range keys {
+		l.buckets[k.key].tokens--
+	}
+	return 0
+}
+
+// fail enregistre un échec pour chaque clé et renvoie celles qui viennent
+// d'être verrouillées
+func (l *loginLimiter) fail(keys []limitKey, now time.Time) []database.LoginFailures {
+	var updated, locked []database.LoginFailures
+
+	l.mu.Lock()
+	for _, k := range keys {
+		f := l.currentFailures(k.key, now)
+		if f == nil {
+			f = &database.LoginFailures{Key: k.key}
+			l.failures[k.key] = f
+		}
+		f.Failures++
+		f.LastFailure = now
+		if f.Failures >= k.policy.lockAfter && f.LockedUntil.IsZero() {
+			f.LockedUntil = now.Add(loginLockoutDuration)
+			locked = append(locked, *f)
+		}
+		updated = append(updated, *f)
+	}
+	store := l.store
+	l.mu.Unlock()
+
+	if store != nil {
+		for i := range updated {
+			if err := store.SaveLoginFailures(&updated[i]); err != nil {
+				log.Printf("Erreur lors de l'enregistrement des échecs de connexion: %v", err)
+			}
+		}
+	}
+	return locked
+}
+
+// succeed efface les échecs d'une clé après une connexion réussie
+func (l *loginLimiter) succeed(key string) {
+	l.mu.Lock()
+	_, existed := l.failures[key]
+	delete(l.failures, key)
+	store := l.store
+	l.mu.Unlock()
+
+	if store != nil && existed {
+		if err := store.DeleteLoginFailures(key); err != nil {
+			log.Printf("Erreur lors de la suppression des échecs de connexion: %v", err)
+		}
+	}
+}
+
+// prune supprime les compteurs revenus à leur état initial. Appelée avec l.mu verrouillé.
+func (l *loginLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < loginPruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > loginFailureWindow {
+			delete(l.buckets, key)
+		}
+	}
+	for key := range l.failures {
+		l.currentFailures(key, now)
+	}
+}
+
+// writeRetryAfter répond 429 avec l'attente avant la prochaine tentative
+func writeRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       "Trop de tentatives de connexion, réessayez plus tard",
+		"retry_after": seconds,
+	})
+}
+
+// auditLockouts journalise les verrouillages déclenchés par une tentative
+func (h *Handler) auditLockouts(r *http.Request, locked []database.LoginFailures) {
+	for _, f := range locked {
+		log.Printf("Connexion verrouillée pour %s jusqu'à %s après %d échecs", f.Key, f.LockedUntil.Format(time.RFC3339), f.Failures)
+		h.recordAudit(r, nil, "login.lockout", f.Key, map[string]interface{}{
+			"failures":     f.Failures,
+			"locked_until": f.LockedUntil,
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"motzarella/database"
+)
+
+// Politique de test : seul le débit limite les tentatives
+var bucketOnlyPolicy = limitPolicy{burst: 2, refill: 10 * time.Second, delayAfter: 100, lockAfter: 100}
+
+func TestLoginLimiterBucketRefills(t *testing.T) {
+	l := newLoginLimiter()
+	keys := []limitKey{{key: "ip:test", policy: bucketOnlyPolicy}}
+	t0 := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if wait := l.allow(keys, t0); wait != 0 {
+			t.Fatalf("tentative %d: attente %v, aucune attendue", i+1, wait)
+		}
+	}
+	if wait := l.allow(keys, t0); wait != 10*time.Second {
+		t.Errorf("bucket vide: attente %v, 10s attendues", wait)
+	}
+	if wait := l.allow(keys, t0.Add(4*time.Second)); wait != 6*time.Second {
+		t.Errorf("bucket partiellement rempli: attente %v, 6s attendues", wait)
+	}
+	if wait := l.allow(keys, t0.Add(10*time.Second)); wait != 0 {
+		t.Errorf("après une période: attente %v, aucune attendue", wait)
+	}
+	if wait := l.allow(keys, t0.Add(10*time.Second)); wait == 0 {
+		t.Error("une seule tentative regagnée en une période, la deuxième doit attendre")
+	}
+}
+
+func TestLoginLimiterDelayDoubles(t *testing.T) {
+	l := newLoginLimiter()
+	policy := limitPolicy{burst: 100, refill: time.Second, delayAfter: 2, lockAfter: 100}
+	keys := []limitKey{{key: "user:test", policy: policy}}
+	t0 := time.Now()
+
+	expected := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		16 * time.Second, 32 * time.Second, maxLoginDelay, maxLoginDelay}
+	for failures, delay := range expected {
+		if wait := l.allow(keys, t0); wait != delay {
+			t.Errorf("après %d échecs: attente %v, %v attendue", failures, wait, delay)
+		}
+		l.fail(keys, t0)
+	}
+
+	// Le délai court depuis le dernier échec
+	if wait := l.allow(keys, t0.Add(maxLoginDelay-time.Second)); wait != time.Second {
+		t.Errorf("avant la fin du délai: attente %v, 1s attendue", wait)
+	}
+	if wait := l.allow(keys, t0.Add(maxLoginDelay)); wait != 0 {
+		t.Errorf("délai écoulé: attente %v, aucune attendue", wait)
+	}
+}
+
+func TestLoginLimiterLockoutExpires(t *testing.T) {
+	l := newLoginLimiter()
+	policy := limitPolicy{burst: 100, refill: time.Second, delayAfter: 100, lockAfter: 3}
+	keys := []limitKey{{key: "user:test", policy: policy}}
+	t0 := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if locked := l.fail(keys, t0); len(locked) != 0 {
+			t.Fatalf("échec %d: verrouillage prématuré", i+1)
+		}
+	}
+	locked := l.fail(keys, t0)
+	if len(locked) != 1 || locked[0].Key != "user:test" || !locked[0].LockedUntil.Equal(t0.Add(loginLockoutDuration)) {
+		t.Fatalf("troisième échec: verrouillages %+v", locked)
+	}
+
+	if wait := l.allow(keys, t0.Add(loginLockoutDuration-time.Minute)); wait != time.Minute {
+		t.Errorf("pendant le verrouillage: attente %v, 1m attendue", wait)
+	}
+	end := t0.Add(loginLockoutDuration)
+	if wait := l.allow(keys, end); wait != 0 {
+		t.Errorf("fin du verrouillage: attente %v, aucune attendue", wait)
+	}
+
+	// Le compteur repart de zéro après le verrouillage
+	if locked := l.fail(keys, end); len(locked) != 0 {
+		t.Errorf("premier échec après le verrouillage: verrouillages %+v", locked)
+	}
+}
+
+func TestLoginLimiterSucceedResetsAccountOnly(t *testing.T) {
+	l := newLoginLimiter()
+	keys := loginLimitKeys("192.0.2.1", "alice")
+	t0 := time.Now()
+	for i := 0; i < 3; i++ {
+		l.fail(keys, t0)
+	}
+
+	l.succeed("user:alice")
+	if f := l.failures["user:alice"]; f != nil {
+		t.Errorf("échecs du compte après une réussite: %+v", f)
+	}
+	if f := l.failures["ip:192.0.2.1"]; f == nil || f.Failures != 3 {
+		t.Errorf("échecs de l'adresse après une réussite: %+v, 3 attendus", f)
+	}
+}
+
+func TestLoginLimiterPersistence(t *testing.T) {
+	store := database.NewMemoryStore()
+	keys := loginLimitKeys("192.0.2.1", "alice")
+
+	t.Setenv("LOGIN_LIMIT_PERSIST", "true")
+	before := New(Config{Store: store})
+	if err := before.LoadLoginLimiter(); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < accountLimitPolicy.lockAfter; i++ {
+		before.loginLimits.fail(keys, now)
+	}
+
+	// Un nouveau serveur sur la même base reprend le verrouillage
+	after := New(Config{Store: store})
+	if err := after.LoadLoginLimiter(); err != nil {
+		t.Fatal(err)
+	}
+	if wait := after.loginLimits.allow(keys, now); wait < loginLockoutDuration-time.Minute {
+		t.Errorf("après redémarrage: attente %v, verrouillage attendu", wait)
+	}
+
+	// Sans LOGIN_LIMIT_PERSIST, les échecs restent en mémoire
+	t.Setenv("LOGIN_LIMIT_PERSIST", "")
+	memory := New(Config{Store: store})
+	if err := memory.LoadLoginLimiter(); err != nil {
+		t.Fatal(err)
+	}
+	if wait := memory.loginLimits.allow(keys, now); wait != 0 {
+		t.Errorf("sans persistance: attente %v, aucune attendue", wait)
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"motzarella/database"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Durée de vie d'un token d'accès
+	accessTokenTTL = 15 * time.Minute
+	// Durée de vie d'une session sans utilisation de son refresh token
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// newRefreshToken génère un refresh token de la forme "<session>.<secret>"
+// et le hash à conserver en base
+func newRefreshToken(sessionID string) (string, []byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	refreshToken := sessionID + "." + base64.RawURLEncoding.EncodeToString(secret)
+	return refreshToken, hashRefreshToken(refreshToken), nil
+}
+
+func hashRefreshToken(refreshToken string) []byte {
+	hash := sha256.Sum256([]byte(refreshToken))
+	return hash[:]
+}
+
+// issueSession ouvre une session pour l'utilisateur et renvoie ses tokens
+func (h *Handler) issueSession(w http.ResponseWriter, r *http.Request, user *database.User) error {
+	sessionID, refreshToken, err := h.openSession(r, user)
+	if err != nil {
+		return err
+	}
+	return h.writeTokens(w, user, sessionID, refreshToken)
+}
+
+// openSession enregistre une nouvelle session et renvoie son identifiant et
+// son refresh token
+func (h *Handler) openSession(r *http.Request, user *database.User) (string, string, error) {
+	now := time.Now()
+	session := &database.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		UserAgent:  r.UserAgent(),
+		IP:         clientIP(r),
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL),
+	}
+
+	refreshToken, hash, err := newRefreshToken(session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	session.RefreshTokenHash = hash
+
+	if err := h.store.CreateSession(session); err != nil {
+		return "", "", err
+	}
+	return session.ID, refreshToken, nil
+}
+
+func (h *Handler) writeTokens(w http.ResponseWriter, user *database.User, sessionID, refreshToken string) error {
+	tokenString, err := h.tokens.Issue(user.Username, user.IsAdmin, sessionID, accessTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":                tokenString,
+		"refresh_token":        refreshToken,
+		"expires_in":           int(accessTokenTTL.Seconds()),
+		"must_change_password": user.MustChangePassword,
+	})
+	return nil
+}
+
+// RefreshHandler échange un refresh token contre un nouveau token d'accès et
+// un nouveau refresh token. L'ancien refresh token devient inutilisable.
+func (h *Handler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	invalid := func() {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Session expirée ou révoquée",
+		})
+	}
+
+	sessionID, _, ok := strings.Cut(body.RefreshToken, ".")
+	if !ok {
+		invalid()
+		return
+	}
+
+	session, err := h.store.GetSession(sessionID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if session == nil || !session.Active() {
+		invalid()
+		return
+	}
+
+	// Un refresh token déjà utilisé signale un vol probable : la session est révoquée
+	reused := func() {
+		log.Printf("Réutilisation d'un refresh token, révocation de la session %s", session.ID)
+		h.store.RevokeSession(session.ID)
+		h.recordAudit(r, nil, "session.refresh_reused", "session:"+session.ID, map[string]interface{}{
+			"user_id": session.UserID,
+		})
+		invalid()
+	}
+	presentedHash := hashRefreshToken(body.RefreshToken)
+	if subtle.ConstantTimeCompare(session.RefreshTokenHash, presentedHash) != 1 {
+		reused()
+		return
+	}
+
+	user, err := h.store.GetUserByID(session.UserID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user == nil || user.DeletedAt != nil {
+		invalid()
+		return
+	}
+	if h.accountBlocked(w, user) {
+		return
+	}
+
+	refreshToken, hash, err := newRefreshToken(session.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// Deux requêtes présentant le même token ne peuvent renouveler la session
+	// qu'une fois : la seconde est traitée comme une réutilisation
+	err = h.store.RotateSession(session.ID, presentedHash, hash, time.Now().Add(refreshTokenTTL))
+	if err == sql.ErrNoRows {
+		reused()
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.writeTokens(w, user, session.ID, refreshToken); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// LogoutHandler révoque la session courante
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*database.User)
+	sessionID := r.Context().Value("session_id").(string)
+	if err := h.store.RevokeSession(sessionID); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, user, "logout", "session:"+sessionID, nil)
+	w.WriteHeader(http.StatusOK)
+}
+
+// SessionsHandler liste les sessions actives de l'utilisateur (GET /api/sessions)
+// et permet d'en révoquer une (DELETE /api/sessions/{id})
+func (h *Handler) SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value("user").(*database.User)
+	currentID := r.Context().Value("session_id").(string)
+
+	sessionID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/sessions"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && sessionID == "":
+		sessions, err := h.store.GetUserSessions(user.ID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		list := []map[string]interface{}{}
+		for _, session := range sessions {
+			list = append(list, map[string]interface{}{
+				"id":           session.ID,
+				"user_agent":   session.UserAgent,
+				"ip":           session.IP,
+				"created_at":   session.CreatedAt,
+				"last_used_at": session.LastUsedAt,
+				"expires_at":   session.ExpiresAt,
+				"current":      session.ID == currentID,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case r.Method == http.MethodDelete && sessionID != "":
+		// Ne révéler l'existence que des sessions de l'utilisateur
+		session, err := h.store.GetSession(sessionID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if session == nil || session.UserID != user.ID {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Session introuvable",
+			})
+			return
+		}
+
+		if err := h.store.RevokeSession(sessionID); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		h.recordAudit(r, user, "session.revoked", "session:"+sessionID, nil)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// clientIP renvoie l'adresse du client, sans le port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
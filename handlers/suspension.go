@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"motzarella/database"
+)
+
+// SuspendedError est renvoyée par AuthenticateToken pour un compte suspendu,
+// avec la suspension en cours pour en communiquer le motif
+type SuspendedError struct {
+	Suspension *database.Suspension
+}
+
+func (e *SuspendedError) Error() string {
+	return "account suspended"
+}
+
+// ConfigureDisconnect enregistre la fonction appelée pour déconnecter un
+// utilisateur suspendu ou supprimé de ses parties en cours
+func (h *Handler) ConfigureDisconnect(fn func(userID int, message map[string]interface{})) {
+	h.disconnectUser = fn
+}
+
+// DisconnectMessage renvoie le message de fermeture des WebSockets d'un
+// utilisateur suspendu ou supprimé, ou nil s'il peut rester connecté. Les
+// connexions ouvertes sur un serveur ne sont fermées immédiatement que par ce
+// serveur : les autres serveurs partageant la base l'appellent régulièrement.
+func (h *Handler) DisconnectMessage(userID int) (map[string]interface{}, error) {
+	suspension, err := h.store.GetActiveSuspension(userID)
+	if err != nil {
+		return nil, err
+	}
+	if suspension != nil {
+		return SuspensionMessage(suspension), nil
+	}
+
+	user, err := h.store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.DeletedAt != nil {
+		return accountDeletedMessage, nil
+	}
+	return nil, nil
+}
+
+// Message WebSocket envoyé à un utilisateur dont le compte a été supprimé
+var accountDeletedMessage = map[string]interface{}{
+	"type":    "error",
+	"code":    "account_deleted",
+	"message": "Ce compte a été supprimé.",
+}
+
+// suspensionDetails décrit une suspension pour le client
+func suspensionDetails(suspension *database.Suspension) map[string]interface{} {
+	return map[string]interface{}{
+		"error":           "Compte suspendu",
+		"reason":          suspension.Reason,
+		"suspended_until": suspension.ExpiresAt, // null pour un bannissement définitif
+	}
+}
+
+// SuspensionMessage est le message WebSocket envoyé à un joueur suspendu
+// avant la fermeture de sa connexion
+func SuspensionMessage(suspension *database.Suspension) map[string]interface{} {
+	message := suspensionDetails(suspension)
+	delete(message, "error")
+	message["type"] = "error"
+	message["code"] = "account_suspended"
+	message["message"] = "Compte suspendu : " + suspension.Reason
+	return message
+}
+
+// writeSuspended répond 403 avec le motif et la fin de la suspension
+func writeSuspended(w http.ResponseWriter, suspension *database.Suspension) {
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(suspensionDetails(suspension))
+}
+
+// accountBlocked refuse l'ouverture d'une session à un compte suspendu, en
+// renvoyant le motif et la fin de la suspension. Renvoie true si la réponse
+// d'erreur a été écrite.
+func (h *Handler) accountBlocked(w http.ResponseWriter, user *database.User) bool {
+	suspension, err := h.store.GetActiveSuspension(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	if suspension == nil {
+		return false
+	}
+
+	writeSuspended(w, suspension)
+	return true
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"motzarella/database"
+	"motzarella/dictionary"
+)
+
+const (
+	defaultReportsPerPage = 25
+	maxReportsPerPage     = 100
+	// Nombre maximal de signalements en attente par joueur
+	maxPendingReports = 20
+	// Longueur maximale du commentaire d'un signalement
+	maxReportCommentLength = 500
+)
+
+// ReportError est une erreur de signalement à afficher au joueur
+type ReportError string
+
+func (e ReportError) Error() string {
+	return string(e)
+}
+
+// ReportWord enregistre le signalement d'un mot par un joueur : kind vaut
+// "missing" pour un mot refusé à tort, "remove" pour un mot à retirer.
+// Renvoie le mot normalisé. Les erreurs de type ReportError sont destinées
+// au joueur.
+func (h *Handler) ReportWord(userID int, word, kind, comment string) (string, error) {
+	if kind != database.ReportMissing && kind != database.ReportRemove {
+		return "", ReportError("Type de signalement invalide")
+	}
+	word, err := dictionary.Normalize(word)
+	if err != nil {
+		return "", ReportError(wordErrorMessage(err))
+	}
+	comment = strings.TrimSpace(comment)
+	if utf8.RuneCountInString(comment) > maxReportCommentLength {
+		return "", ReportError("Commentaire trop long")
+	}
+
+	known := h.words.Contains(word)
+	if kind == database.ReportMissing && known {
+		return "", ReportError("Ce mot est déjà dans le dictionnaire")
+	}
+	if kind == database.ReportRemove && !known {
+		return "", ReportError("Ce mot n'est pas dans le dictionnaire")
+	}
+
+	pending, err := h.store.CountPendingReportsByUser(userID)
+	if err != nil {
+		return "", err
+	}
+	if pending >= maxPendingReports {
+		return "", ReportError("Trop de signalements en attente, réessayez plus tard")
+	}
+
+	err = h.store.AddWordReport(&database.WordReport{
+		Word:       word,
+		Kind:       kind,
+		Comment:    comment,
+		ReporterID: userID,
+	})
+	if err == database.ErrAlreadyReported {
+		return "", ReportError("Vous avez déjà signalé ce mot")
+	}
+	return word, err
+}
+
+// ReportWordHandler permet à un joueur de signaler un mot
+// (POST /api/words/report, corps {"word", "kind": "missing"|"remove", "comment"})
+func (h *Handler) ReportWordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	user := r.Context().Value("user").(*database.User)
+
+	var body struct {
+		Word    string `json:"word"`
+		Kind    string `json:"kind"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	word, err := h.ReportWord(user.ID, body.Word, body.Kind, body.Comment)
+	if reportErr, ok := err.(ReportError); ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": reportErr.Error(),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors du signalement du mot %s: %v", body.Word, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"word": word,
+		"kind": body.Kind,
+	})
+}
+
+// WordReportsHandler renvoie la file de modération des signalements
+// (/api/admin/word-reports?page=&limit=&kind=missing|remove), les mots les
+// plus signalés en premier
+func (h *Handler) WordReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	page := parsePositiveInt(query.Get("page"), 1)
+	limit := parsePositiveInt(query.Get("limit"), defaultReportsPerPage)
+	if limit > maxReportsPerPage {
+		limit = maxReportsPerPage
+	}
+	kind := query.Get("kind")
+	if kind != "" && kind != database.ReportMissing && kind != database.ReportRemove {
+		http.Error(w, "Type de signalement invalide", http.StatusBadRequest)
+		return
+	}
+
+	groups, total, err := h.store.ListWordReportGroups(kind, limit, (page-1)*limit)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des signalements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports": groups,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+// WordReportActionHandler traite les signalements en attente d'un mot
+// (POST /api/admin/word-reports/{kind}/{mot}/approve|reject). Approuver
+// ajoute le mot au dictionnaire ou l'en retire selon le type de signalement.
+func (h *Handler) WordReportActionHandler(w http.ResponseWriter, r *http.Request) {
+	// [, api, admin, word-reports, kind, mot, action]
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 7 {
+		http.Error(w, "Chemin invalide", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	kind, action := parts[4], parts[6]
+	if kind != database.ReportMissing && kind != database.ReportRemove {
+		http.Error(w, "Type de signalement invalide", http.StatusNotFound)
+		return
+	}
+	word, err := dictionary.Normalize(parts[5])
+	if err != nil {
+		http.Error(w, "Aucun signalement en attente pour ce mot", http.StatusNotFound)
+		return
+	}
+	if action != "approve" && action != "reject" {
+		http.Error(w, "Chemin invalide", http.StatusNotFound)
+		return
+	}
+	actor := r.Context().Value("user").(*database.User)
+
+	pending, err := h.store.HasPendingWordReports(word, kind)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des signalements", http.StatusInternalServerError)
+		return
+	}
+	if !pending {
+		http.Error(w, "Aucun signalement en attente pour ce mot", http.StatusNotFound)
+		return
+	}
+
+	status := database.ReportRejected
+	if action == "approve" {
+		status = database.ReportApproved
+		if kind == database.ReportMissing {
+			err = h.store.AddDictionaryWord(word, "", &actor.ID)
+			if err == database.ErrWordExists {
+				err = nil
+			}
+		} else {
+			err = h.store.RemoveDictionaryWord(word)
+			if err == sql.ErrNoRows {
+				err = nil
+			}
+		}
+		if err != nil {
+			writeDictionaryError(w, err)
+			return
+		}
+		if err := h.reloadDictionary(); err != nil {
+			log.Printf("Erreur lors du rechargement du dictionnaire: %v", err)
+		}
+	}
+
+	count, err := h.store.ResolveWordReports(word, kind, status, actor.ID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Aucun signalement en attente pour ce mot", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erreur lors du traitement des signalements", http.StatusInternalServerError)
+		return
+	}
+	h.recordAudit(r, actor, "word_report."+status, "word:"+word, map[string]interface{}{
+		"kind":    kind,
+		"reports": count,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"word":    word,
+		"kind":    kind,
+		"status":  status,
+		"reports": count,
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"motzarella/database"
+
+	"github.com/gorilla/websocket"
+)
+
+// Intervalle d'envoi de l'état du serveur sur /ws/admin/live
+const liveFeedInterval = 2 * time.Second
+
+// liveSnapshot décrit l'état du serveur pour le tableau de bord
+// d'administration : connexions ouvertes, file d'attente et parties en cours
+func liveSnapshot() map[string]interface{} {
+	// Partie de chaque joueur, pour l'afficher à côté de sa connexion
+	gameOf := make(map[string]string)
+	liveGames := []map[string]interface{}{}
+	for _, game := range games.List() {
+		info := game.AdminInfo()
+		for _, player := range info["players"].([]map[string]interface{}) {
+			gameOf[player["player_id"].(string)] = game.ID
+		}
+		liveGames = append(liveGames, info)
+	}
+
+	conns := []map[string]interface{}{}
+	for _, conn := range connections.List() {
+		conns = append(conns, map[string]interface{}{
+			"player_id":    conn.Player.ID,
+			"user_id":      conn.Player.UserID,
+			"username":     conn.Player.Name(),
+			"role":         conn.Role,
+			"remote_addr":  conn.RemoteAddr,
+			"connected_at": conn.ConnectedAt,
+			"game_id":      gameOf[conn.Player.ID],
+		})
+	}
+
+	waiting := []map[string]interface{}{}
+	for _, queued := range queue.List() {
+		waiting = append(waiting, map[string]interface{}{
+			"player_id":       queued.Player.ID,
+			"username":        queued.Player.Name(),
+			"settings":        queued.Settings,
+			"waiting_seconds": int(time.Since(queued.Since).Seconds()),
+		})
+	}
+
+	return map[string]interface{}{
+		"type":         "live",
+		"connections":  conns,
+		"queue_length": len(waiting),
+		"queue":        waiting,
+		"games":        liveGames,
+		"generated_at": time.Now(),
+	}
+}
+
+// adminLiveHandler renvoie l'état du serveur (GET /api/admin/live)
+func adminLiveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(liveSnapshot())
+}
+
+// adminLiveActionHandler arrête une partie
+// (POST /api/admin/live/games/{id}/end, corps facultatif {"reason"}) ou
+// déconnecte un joueur ou un spectateur (POST /api/admin/live/players/{id}/disconnect)
+func adminLiveActionHandler(w http.ResponseWriter, r *http.Request) {
+	// [, api, admin, live, games|players, ID, action]
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 7 {
+		http.Error(w, "Chemin invalide", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	actor := r.Context().Value("user").(*database.User)
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	reason := strings.TrimSpace(body.Reason)
+
+	switch parts[4] + " " + parts[6] {
+	case "games end":
+		game := games.Get(parts[5])
+		if reason == "" {
+			reason = "Partie arrêtée par un administrateur."
+		}
+		if game == nil || !game.Abort(reason) {
+			http.Error(w, "Partie introuvable ou terminée", http.StatusNotFound)
+			return
+		}
+		api.RecordAudit(r, actor, "match.force_ended", "match:"+game.ID, map[string]interface{}{
+			"reason": reason,
+		})
+
+	case "players disconnect":
+		conn := connections.Get(parts[5])
+		if conn == nil {
+			http.Error(w, "Connexion introuvable", http.StatusNotFound)
+			return
+		}
+		if reason == "" {
+			reason = "Vous avez été déconnecté par un administrateur."
+		}
+		conn.Player.Disconnect(map[string]interface{}{
+			"type":    "error",
+			"code":    "disconnected",
+			"message": reason,
+		})
+		api.RecordAudit(r, actor, "player.disconnected", "player:"+conn.Player.ID, map[string]interface{}{
+			"username": conn.Player.Username,
+			"role":     conn.Role,
+			"reason":   reason,
+		})
+
+	default:
+		http.Error(w, "Chemin invalide", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// liveAccess vérifie que le token donne accès à l'état du serveur et renvoie
+// le statut HTTP du refus, ou 0
+func liveAccess(tokenString string) int {
+	user, _, err := api.AuthenticateToken(tokenString)
+	if err != nil {
+		return http.StatusUnauthorized
+	}
+	if user.MustChangePassword || !user.Can(database.PermMatchesReadPrivate) {
+		return http.StatusForbidden
+	}
+	return 0
+}
+
+// handleAdminLive envoie l'état du serveur à intervalle régulier
+// (/ws/admin/live?token=...). Les navigateurs ne permettent pas d'envoyer
+// d'en-tête Authorization sur une WebSocket, d'où le token en paramètre.
+// L'accès est revérifié avant chaque envoi : la connexion est fermée dès que
+// le token expire, que la session est révoquée ou que le compte perd la
+// permission, et le tableau de bord se reconnecte avec un nouveau token.
+func handleAdminLive(w http.ResponseWriter, r *http.Request) {
+	tokenString := r.URL.Query().Get("token")
+	switch liveAccess(tokenString) {
+	case http.StatusUnauthorized:
+		http.Error(w, "Token invalide", http.StatusUnauthorized)
+		return
+	case http.StatusForbidden:
+		http.Error(w, "Accès non autorisé", http.StatusForbidden)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	// Détecter la fermeture de la connexion ; les messages reçus sont ignorés
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(liveFeedInterval)
+	defer ticker.Stop()
+	for {
+		if liveAccess(tokenString) != 0 {
+			conn.WriteJSON(map[string]interface{}{
+				"type":    "error",
+				"code":    "unauthorized",
+				"message": "Accès au suivi en direct révoqué ou expiré.",
+			})
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, ""), time.Now().Add(time.Second))
+			return
+		}
+		if err := conn.WriteJSON(liveSnapshot()); err != nil {
+			return
+		}
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+	}
+}
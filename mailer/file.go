@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer écrit chaque email dans un fichier .eml, pour le développement
+// et les tests
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+var fileCounter uint64
+
+func (m *FileMailer) Send(msg Message) error {
+	data, err := format(m.From, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Le destinataire est réduit à des caractères sûrs pour le nom du fichier
+	recipient := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '@' {
+			return r
+		}
+		return '_'
+	}, msg.To)
+	name := fmt.Sprintf("%s-%04d-%s.eml", time.Now().Format("20060102-150405"), atomic.AddUint64(&fileCounter, 1), recipient)
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0600)
+}
+
+// LogMailer affiche les emails dans les logs au lieu de les envoyer
+type LogMailer struct {
+	From string
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	if _, err := format(m.From, msg); err != nil {
+		return err
+	}
+	log.Printf("Email pour %s : %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package mailer envoie les emails de l'application (vérification d'adresse,
+// réinitialisation du mot de passe). L'implémentation est choisie par
+// configuration : SMTP en production, fichiers ou logs en développement.
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer envoie un message texte
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Expéditeur utilisé si MAIL_FROM n'est pas défini
+const defaultFrom = "Motzarella <no-reply@motzarella.com>"
+
+var errHeaderInjection = errors.New("mailer: retour à la ligne interdit dans les en-têtes")
+
+// FromEnv construit le mailer décrit par l'environnement :
+//
+//	MAIL_DRIVER    "smtp", "file" ou "log" (par défaut)
+//	MAIL_FROM      expéditeur, "Motzarella <no-reply@motzarella.com>" par défaut
+//	MAIL_DIR       dossier des emails du driver "file", "data/mails" par défaut
+//	SMTP_HOST, SMTP_PORT (587 par défaut), SMTP_USERNAME, SMTP_PASSWORD
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = defaultFrom
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("MAIL_FROM invalide: %w", err)
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, errors.New("SMTP_HOST est obligatoire avec MAIL_DRIVER=smtp")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "data/mails"
+		}
+		return &FileMailer{Dir: dir, From: from}, nil
+	case "", "log":
+		return &LogMailer{From: from}, nil
+	default:
+		return nil, fmt.Errorf("MAIL_DRIVER inconnu: %q", driver)
+	}
+}
+
+// format construit le message au format RFC 5322
+func format(from string, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errHeaderInjection
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
@@ -7,11 +7,15 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"motzarella/database"
+	"motzarella/dictionary"
 	"motzarella/handlers"
+	"motzarella/mailer"
+	"motzarella/token"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -24,44 +28,24 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-type Game struct {
-	Players map[*websocket.Conn]bool
-	Word    string
-	Guesses map[*websocket.Conn][]string // Stocke les tentatives de chaque joueur
+// Joueur en file d'attente avec les options de partie demandées
+type waitingPlayer struct {
+	player   *Player
+	settings GameSettings
 }
 
-var games = make(map[string]*Game)
-var waitingPlayers = make(chan *websocket.Conn)
-
-// Liste de mots pour le jeu
-var words = []string{
-	"ABRIER", "ABUSER", "ACCORD", "ADORER", "AFFUTS", "AGITER", "AIDER", "AIMER", "AJOUTS", "ALARME",
-	"BALADE", "BALISE", "BANANE", "BANCAL", "BANDIT", "BANQUE", "BARQUE", "BASSIN", "BATONS", "BEAUTE",
-	"CABANE", "CABINE", "CACHER", "CADEAU", "CAISSE", "CALMER", "CAMPER", "CANARD", "CANOTS", "CAPOTE",
-	"DANGER", "DANSER", "DATER", "DEBORD", "DECORS", "DEFAIT", "DEGATS", "DELICE", "DEMAIN", "DENIER",
-	"ECARTS", "ECHECS", "ECLATS", "ECOLES", "ECRANS", "ECRITS", "EDITER", "EFFETS", "EGARER", "ELEVER",
-	"FACILE", "FACTOR", "FADING", "FAIBLE", "FAIRES", "FALOTS", "FAMINE", "FANION", "FARDER", "FARINE",
-	"GACHER", "GADGET", "GAGNER", "GALETS", "GALONS", "GAMINS", "GARAGE", "GARDER", "GARCON", "GARER",
-	"HABILE", "HABITS", "HACHER", "HALETS", "HALLES", "HALTER", "HANCHE", "HANGAR", "HANTER", "HARDIS",
-	"IDEALS", "IDIOTS", "IGNARE", "IGNORE", "ILOTER", "IMAGE", "IMITER", "IMPACT", "IMPORT", "IMPOST",
-	"JABOTS", "JACHER", "JACOTS", "JADIS", "JALONS", "JAMBES", "JARDIN", "JARGON", "JASPER", "JETONS",
-	"KILOS", "KINNES", "KITCH", "KOTER", "KRAAL", "KRAFT", "KURDE", "KYRIE", "KYSTE", "KZAR",
-	"LABELS", "LABOUR", "LACETS", "LACHER", "LACTES", "LADITE", "LAGONS", "LAIDER", "LAITER", "LAMINE",
-	"MACHIN", "MACLER", "MADAME", "MAGOTS", "MAIGRE", "MAILLE", "MAINER", "MAISON", "MALADE", "MALICE",
-	"NAGER", "NAIFS", "NAINS", "NAITRE", "NANAS", "NANTES", "NARINE", "NATIFS", "NATURE", "NAVETS",
-	"OBLATS", "OBLIGE", "OBSCUR", "OBSEDE", "OBTENU", "OBTURE", "OBUSES", "OCELOT", "OCTETS", "OCULER",
-	"PACTES", "PADRES", "PAGODE", "PAIENS", "PAILLE", "PAIRES", "PALACE", "PALIER", "PALMER", "PALPER",
-	"QUAIRE", "QUAKER", "QUARTZ", "QUASAR", "QUATRE", "QUEBEC", "QUELER", "QUENNE", "QUERIR", "QUETES",
-	"RABATS", "RABIOT", "RACINE", "RADARS", "RADIER", "RADINS", "RADIOS", "RADIUM", "RADONS", "RAFALE",
-	"SABLER", "SABOTS", "SABRES", "SACHER", "SACRES", "SADITE", "SAFARI", "SAGACE", "SAGOUIN", "SAHARA",
-	"TABACS", "TABLES", "TABORS", "TABOUS", "TACHER", "TACLER", "TACTES", "TADJIK", "TAGUER", "TAILLE",
-	"UNIFIE", "UNIQUE", "UNIRAS", "UNISEX", "UNISSE", "UNITES", "UNIVER", "URBAIN", "URGENT", "URINER",
-	"VACANT", "VACHER", "VAGINS", "VAGUER", "VAINCS", "VAINES", "VAIRON", "VALETS", "VALIDE", "VALISE",
-	"WAGONS", "WALIS", "WALLON", "WATTS", "WEBER", "WELTER", "WHARF", "WHISKY", "WIDGET", "WILAYA",
-	"XENONS", "XERXES", "XHOSA", "XIPHO", "XYLENE", "XYLOSE", "XYSTES", "XYSTRE", "XYSTUS", "XENONS",
-	"YACHTS", "YACKS", "YAKAS", "YAMBA", "YANKS", "YARDS", "YAWLS", "YEBLES", "YEMEN", "YETIS",
-	"ZABRES", "ZAINES", "ZAMBIE", "ZANZIS", "ZAPPES", "ZEBRES", "ZELOTE", "ZENITH", "ZESTES", "ZIBELI",
-}
+var games = NewGameRegistry()
+var waitingPlayers = make(chan waitingPlayer)
+var queue = NewMatchQueue()
+var connections = NewConnectionRegistry()
+var chatLimits = NewChatLimiter()
+
+// Stockage des données et handlers de l'API, créés au démarrage
+var store database.Store
+var api *handlers.Handler
+
+// Mots jouables, chargés par les handlers de l'API et tirés par les parties
+var dictionaryWords = dictionary.NewStore()
 
 // Middleware pour gérer les en-têtes MIME des fichiers JavaScript
 func addJSMimeTypeMiddleware(next http.Handler) http.Handler {
@@ -79,31 +63,142 @@ func main() {
 		log.Println("Error loading .env file")
 	}
 
+	// Sous-commandes d'administration (go run . reset-admin ...)
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
+	// Clés de signature et claims attendus des tokens JWT
+	tokens, err := token.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
 	// Initialisation de la base de données
-	database.InitDB()
+	store = database.InitDB()
+	defer store.Close()
+
+	// Envoi des emails (vérification d'adresse, mot de passe oublié)
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	baseURL := os.Getenv("APP_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+	api = handlers.New(handlers.Config{
+		Store:     store,
+		Mailer:    mail,
+		Tokens:    tokens,
+		Words:     dictionaryWords,
+		BaseURL:   baseURL,
+		AvatarDir: filepath.Join("data", "avatars"),
+	})
+
+	// Dictionnaire des mots jouables
+	if err := api.LoadDictionary(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Limitation des tentatives de connexion
+	if err := api.LoadLoginLimiter(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Fournisseurs d'identité OpenID Connect
+	if err := api.LoadOIDCProviders(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Fermer les connexions d'un utilisateur suspendu ou supprimé
+	api.ConfigureDisconnect(func(userID int, message map[string]interface{}) {
+		for _, conn := range connections.ByUser(userID) {
+			conn.Player.Disconnect(message)
+		}
+	})
+
+	// Reprendre les modifications faites par les autres serveurs de la base
+	go syncSharedState()
+
+	// Liste des mots filtrés dans le chat (liste intégrée par défaut)
+	loadChatBlocklist(os.Getenv("CHAT_BLOCKLIST"))
 
 	// Routes statiques avec middleware pour les fichiers JS
 	fileServer := http.FileServer(http.Dir("static"))
 	http.Handle("/", addJSMimeTypeMiddleware(fileServer))
 
 	// Routes d'authentification
-	http.HandleFunc("/api/register", handlers.RegisterHandler)
-	http.HandleFunc("/api/login", handlers.LoginHandler)
+	http.HandleFunc("/api/register", api.RegisterHandler)
+	http.HandleFunc("/api/login", api.LoginHandler)
+	http.HandleFunc("/api/login/mfa", api.MFALoginHandler)
+	http.HandleFunc("/api/oidc/providers", api.OIDCProvidersHandler)
+	http.HandleFunc("/api/oidc/", api.OIDCHandler)
+	http.HandleFunc("/api/refresh", api.RefreshHandler)
 
 	// Routes protégées
-	http.HandleFunc("/api/profile", handlers.AuthMiddleware(handlers.ProfileHandler))
+	http.HandleFunc("/api/profile", api.AuthMiddleware(api.ProfileHandler))
+	http.HandleFunc("/api/logout", api.AuthMiddleware(api.LogoutHandler))
+	http.HandleFunc("/api/password/change", api.AuthMiddleware(api.ChangePasswordHandler))
+	http.HandleFunc("/api/password/forgot", api.ForgotPasswordHandler)
+	http.HandleFunc("/api/password/reset", api.ResetPasswordHandler)
+	http.HandleFunc("/api/verify-email", api.VerifyEmailHandler)
+	http.HandleFunc("/api/verify-email/resend", api.AuthMiddleware(api.ResendVerificationHandler))
+
+	// Gestion du compte par l'utilisateur
+	http.HandleFunc("/api/account", api.AuthMiddleware(api.DeleteAccountHandler))
+	http.HandleFunc("/api/account/username", api.AuthMiddleware(api.ChangeUsernameHandler))
+	http.HandleFunc("/api/account/email", api.AuthMiddleware(api.ChangeEmailHandler))
+	http.HandleFunc("/api/account/avatar", api.AuthMiddleware(api.AvatarUploadHandler))
+	http.HandleFunc("/avatars/", api.AvatarHandler)
+
+	// Double authentification (TOTP)
+	http.HandleFunc("/api/mfa/enroll", api.AuthMiddleware(api.MFAEnrollHandler))
+	http.HandleFunc("/api/mfa/verify", api.AuthMiddleware(api.MFAVerifyHandler))
+	http.HandleFunc("/api/mfa/recovery-codes", api.AuthMiddleware(api.MFARecoveryCodesHandler))
+	http.HandleFunc("/api/mfa/disable", api.AuthMiddleware(api.MFADisableHandler))
+	http.HandleFunc("/api/sessions", api.AuthMiddleware(api.SessionsHandler))
+	http.HandleFunc("/api/sessions/", api.AuthMiddleware(api.SessionsHandler))
 
 	// Routes d'administration
-	http.HandleFunc("/api/admin/users", handlers.AuthMiddleware(handlers.AdminMiddleware(handlers.ListUsersHandler)))
-	http.HandleFunc("/api/admin/users/delete/", handlers.AuthMiddleware(handlers.AdminMiddleware(handlers.DeleteUserHandler)))
-
-	// Route WebSocket
+	requireUsersRead := handlers.RequirePermission(database.PermUsersRead)
+	requireRolesManage := handlers.RequirePermission(database.PermRolesManage)
+	requireChatModerate := handlers.RequirePermission(database.PermChatModerate)
+	requireAuditRead := handlers.RequirePermission(database.PermAuditRead)
+	requireDictionaryWrite := handlers.RequirePermission(database.PermDictionaryWrite)
+	requireMatchesRead := handlers.RequirePermission(database.PermMatchesReadPrivate)
+	requireUsersManage := handlers.RequirePermission(database.PermUsersManage)
+	http.HandleFunc("/api/admin/users", api.AuthMiddleware(requireUsersRead(api.ListUsersHandler)))
+	// Chaque action sur un utilisateur vérifie sa propre permission
+	http.HandleFunc("/api/admin/users/", api.AuthMiddleware(api.AdminUserHandler))
+	http.HandleFunc("/api/admin/roles", api.AuthMiddleware(requireRolesManage(api.ListRolesHandler)))
+	http.HandleFunc("/api/admin/audit", api.AuthMiddleware(requireAuditRead(api.AuditLogHandler)))
+	http.HandleFunc("/api/admin/dictionary", api.AuthMiddleware(requireDictionaryWrite(api.DictionaryHandler)))
+	http.HandleFunc("/api/admin/dictionary/", api.AuthMiddleware(requireDictionaryWrite(api.DictionaryWordHandler)))
+	http.HandleFunc("/api/admin/word-reports", api.AuthMiddleware(requireDictionaryWrite(api.WordReportsHandler)))
+	http.HandleFunc("/api/admin/word-reports/", api.AuthMiddleware(requireDictionaryWrite(api.WordReportActionHandler)))
+	http.HandleFunc("/api/admin/live", api.AuthMiddleware(requireMatchesRead(adminLiveHandler)))
+	http.HandleFunc("/api/admin/live/", api.AuthMiddleware(requireUsersManage(adminLiveActionHandler)))
+	http.HandleFunc("/api/admin/matches/", api.AuthMiddleware(requireChatModerate(api.MatchChatHandler)))
+
+	// Parties en cours et historique
+	http.HandleFunc("/api/matches/live", liveMatchesHandler)
+	http.HandleFunc("/api/matches/", api.AuthMiddleware(api.MatchHandler))
+	http.HandleFunc("/api/users/", api.AuthMiddleware(api.UserMatchesHandler))
+
+	// Signalement de mots par les joueurs
+	http.HandleFunc("/api/words/report", api.AuthMiddleware(api.ReportWordHandler))
+
+	// Routes WebSocket
 	http.HandleFunc("/ws", handleWebSocket)
+	http.HandleFunc("/ws/spectate", handleSpectate)
+	http.HandleFunc("/ws/admin/live", handleAdminLive)
 
 	// Démarrer le matchmaking
 	go matchmaking()
@@ -120,8 +215,38 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
+	player := &Player{Conn: conn, ID: uuid.New().String()}
+
+	// Le token est facultatif : sans lui, le joueur apparaît comme invité.
+	// Un compte suspendu est refusé avec le motif de la suspension, et un
+	// compte dont le mot de passe doit être changé ne peut pas jouer.
+	if token := r.URL.Query().Get("token"); token != "" {
+		user, _, err := api.AuthenticateToken(token)
+		if suspended, ok := err.(*handlers.SuspendedError); ok {
+			player.Disconnect(handlers.SuspensionMessage(suspended.Suspension))
+			return
+		}
+		if err == nil && user.MustChangePassword {
+			player.Disconnect(map[string]interface{}{
+				"type":    "error",
+				"code":    "must_change_password",
+				"message": "Vous devez changer votre mot de passe",
+			})
+			return
+		}
+		if err == nil {
+			player.Username = user.Username
+			player.UserID = user.ID
+		}
+	}
+
+	connections.Add(&Connection{Player: player, Role: "player", RemoteAddr: r.RemoteAddr, ConnectedAt: time.Now()})
+	defer connections.Remove(player.ID)
+
 	// Ajouter le joueur à la file d'attente
-	waitingPlayers <- conn
+	waitingPlayers <- waitingPlayer{player: player, settings: parseGameSettings(r)}
+	defer queue.Remove(player)
+	defer leaveRematch(player)
 
 	// Gérer la connexion
 	for {
@@ -137,79 +262,83 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		switch data["type"] {
-		case "submit_guess":
-			gameID := data["game_id"].(string)
-			guess := data["guess"].(string)
-			game := games[gameID]
+		handlePlayerMessage(player, data)
+	}
+}
 
-			if game != nil {
-				// Vérifier si le joueur a déjà gagné ou perdu
-				if len(game.Guesses[conn]) > 0 && (game.Guesses[conn][len(game.Guesses[conn])-1] == game.Word || len(game.Guesses[conn]) >= 6) {
-					continue
-				}
+// handlePlayerMessage traite un message envoyé par un joueur. Les champs
+// viennent du client : un champ absent ou mal typé ne doit pas faire tomber
+// le serveur.
+func handlePlayerMessage(player *Player, data map[string]interface{}) {
+	switch data["type"] {
+	case "submit_guess":
+		gameID, _ := data["game_id"].(string)
+		guess, ok := data["guess"].(string)
+		if !ok {
+			player.WriteJSON(map[string]interface{}{
+				"type":    "error",
+				"message": "Proposition invalide.",
+			})
+			return
+		}
+		game := games.Get(gameID)
 
-				if !isValidWord(guess) {
-					conn.WriteJSON(map[string]interface{}{
-						"type":    "error",
-						"message": "Mot non reconnu dans le dictionnaire.",
-					})
-					continue
-				}
+		if game != nil {
+			game.SubmitGuess(player, guess)
+		}
+	case "chat":
+		gameID, _ := data["game_id"].(string)
+		message, _ := data["message"].(string)
+		emote, _ := data["emote"].(string)
+		game := games.Get(gameID)
+
+		if game != nil {
+			game.SendChat(player, message, emote)
+		}
+	case "report_word":
+		word, _ := data["word"].(string)
+		kind, _ := data["kind"].(string)
+		comment, _ := data["comment"].(string)
+		reportWord(player, word, kind, comment)
+	case "rematch_request":
+		requestRematch(player)
+	case "rematch_accept":
+		acceptRematch(player)
+	case "rematch_decline":
+		declineRematch(player)
+	}
+}
 
-				// Vérifier si le mot est valide (même longueur que le mot mystère)
-				if len(guess) != len(game.Word) {
-					conn.WriteJSON(map[string]interface{}{
-						"type":    "error",
-						"message": fmt.Sprintf("Le mot doit faire exactement %d lettres", len(game.Word)),
-					})
-					continue
-				}
+// reportWord enregistre le signalement d'un mot envoyé pendant une partie.
+// Seuls les joueurs connectés peuvent signaler un mot.
+func reportWord(player *Player, word, kind, comment string) {
+	if player.UserID == 0 {
+		player.WriteJSON(map[string]interface{}{
+			"type":    "error",
+			"message": "Connectez-vous pour signaler un mot.",
+		})
+		return
+	}
 
-				result := checkGuess(guess, game.Word)
-				game.Guesses[conn] = append(game.Guesses[conn], guess)
-
-				// Envoyer le résultat uniquement au joueur qui a fait la tentative
-				conn.WriteJSON(map[string]interface{}{
-					"type":     "guess_result",
-					"guess":    guess,
-					"result":   result,
-					"correct":  guess == game.Word,
-					"attempts": len(game.Guesses[conn]),
-				})
-
-				if guess == game.Word {
-					// Le joueur a gagné, envoyer game_over à tous les joueurs
-					for player := range game.Players {
-						winner := "none"
-						if player == conn {
-							winner = "you"
-						}
-						player.WriteJSON(map[string]interface{}{
-							"type":   "game_over",
-							"winner": winner,
-							"word":   game.Word,
-						})
-					}
-					delete(games, gameID)
-				} else if len(game.Guesses[conn]) >= 6 {
-					// Le joueur a perdu, l'autre joueur gagne
-					for player := range game.Players {
-						winner := "none"
-						if player != conn {
-							winner = "you"
-						}
-						player.WriteJSON(map[string]interface{}{
-							"type":   "game_over",
-							"winner": winner,
-							"word":   game.Word,
-						})
-					}
-					delete(games, gameID)
-				}
-			}
+	word, err := api.ReportWord(player.UserID, word, kind, comment)
+	if err != nil {
+		message := "Erreur lors du signalement"
+		if reportErr, ok := err.(handlers.ReportError); ok {
+			message = reportErr.Error()
+		} else {
+			log.Printf("Erreur lors du signalement d'un mot: %v", err)
 		}
+		player.WriteJSON(map[string]interface{}{
+			"type":    "error",
+			"message": message,
+		})
+		return
 	}
+	player.WriteJSON(map[string]interface{}{
+		"type": "word_reported",
+		"word": word,
+		"kind": kind,
+	})
 }
 
 func checkGuess(guess, word string) []string {
@@ -245,52 +374,112 @@ func checkGuess(guess, word string) []string {
 	return result
 }
 
+// parseGameSettings lit les options de partie demandées dans l'URL de connexion,
+// par exemple /ws?opponent_progress=hidden pour jouer en mode caché ou
+// /ws?private=1 pour une partie sans spectateurs
+func parseGameSettings(r *http.Request) GameSettings {
+	query := r.URL.Query()
+	settings := GameSettings{OpponentProgress: true}
+	switch query.Get("opponent_progress") {
+	case "hidden", "false", "0":
+		settings.OpponentProgress = false
+	}
+	switch query.Get("private") {
+	case "true", "1":
+		settings.Private = true
+	}
+	return settings
+}
+
+// Intervalle de synchronisation avec les autres serveurs partageant la base
+const sharedStateSyncInterval = 10 * time.Second
+
+// syncSharedState reprend régulièrement ce que les autres serveurs partageant
+// la base ont modifié : le dictionnaire, et les suspensions ou suppressions de
+// comptes dont les connexions sont ouvertes ici. Seul le serveur qui traite la
+// requête recharge ses mots et ferme les connexions immédiatement.
+func syncSharedState() {
+	ticker := time.NewTicker(sharedStateSyncInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := api.SyncDictionary(); err != nil {
+			log.Printf("Erreur lors de la synchronisation du dictionnaire: %v", err)
+		}
+
+		checked := make(map[int]bool)
+		for _, conn := range connections.List() {
+			userID := conn.Player.UserID
+			if userID == 0 || checked[userID] {
+				continue
+			}
+			checked[userID] = true
+
+			message, err := api.DisconnectMessage(userID)
+			if err != nil {
+				log.Printf("Erreur lors de la vérification du compte %d: %v", userID, err)
+				continue
+			}
+			if message != nil {
+				for _, conn := range connections.ByUser(userID) {
+					conn.Player.Disconnect(message)
+				}
+			}
+		}
+	}
+}
+
 func matchmaking() {
 	for {
-		player1 := <-waitingPlayers
-		player2 := <-waitingPlayers
-
-		// Sélectionner un mot aléatoire
-		rand.Seed(time.Now().UnixNano())
-		word := words[rand.Intn(len(words))]
-
-		// Créer une nouvelle partie
-		gameID := uuid.New().String()
-		game := &Game{
-			Players: make(map[*websocket.Conn]bool),
-			Word:    word,
-			Guesses: make(map[*websocket.Conn][]string),
+		waiting := <-waitingPlayers
+		player1, ok := queue.Pair(waiting.player, waiting.settings)
+		if !ok {
+			continue
 		}
-		game.Players[player1] = true
-		game.Players[player2] = true
-		game.Guesses[player1] = []string{}
-		game.Guesses[player2] = []string{}
 
-		games[gameID] = game
-
-		log.Printf("Nouvelle partie créée: %s, Mot: %s", gameID, word)
+		startGame(player1, waiting.player, waiting.settings)
+	}
+}
 
-		// Envoyer le début de partie à chaque joueur
-		for player := range game.Players {
+// startGame crée une partie entre deux joueurs et leur envoie game_start
+func startGame(player1, player2 *Player, settings GameSettings) {
+	// Sélectionner un mot aléatoire parmi les mots jouables du dictionnaire
+	rand.Seed(time.Now().UnixNano())
+	word, ok := dictionaryWords.Random()
+	if !ok {
+		log.Printf("Aucun mot jouable dans le dictionnaire, partie annulée")
+		for _, player := range []*Player{player1, player2} {
 			player.WriteJSON(map[string]interface{}{
-				"type":    "game_start",
-				"game_id": gameID,
-				"word":    word,
+				"type":    "error",
+				"message": "Aucun mot disponible, réessayez plus tard.",
 			})
 		}
+		return
+	}
+
+	// Créer une nouvelle partie
+	gameID := uuid.New().String()
+	game := NewGame(gameID, word, settings, player1, player2)
+
+	games.Add(game)
+
+	log.Printf("Nouvelle partie créée: %s, Mot: %s", gameID, word)
+
+	// Envoyer le début de partie à chaque joueur
+	for player := range game.Players {
+		player.WriteJSON(map[string]interface{}{
+			"type":     "game_start",
+			"game_id":  gameID,
+			"word":     word,
+			"settings": game.Settings,
+		})
 	}
 }
 
 func generateGameID() string {
 	rand.Seed(time.Now().UnixNano())
-	return "game-" + string(rand.Intn(1000))
+	return fmt.Sprintf("game-%d", rand.Intn(1000))
 }
 
 func isValidWord(guess string) bool {
-	for _, w := range words {
-		if guess == w {
-			return true
-		}
-	}
-	return false
+	return dictionaryWords.Contains(guess)
 }
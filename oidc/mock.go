@@ -0,0 +1,214 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Durée de validité d'un code d'autorisation du faux émetteur
+const mockCodeTTL = time.Minute
+
+// MockIssuer est un fournisseur d'identité minimal, pour le développement et
+// les tests : la page de connexion demande seulement un pseudo et un email,
+// sans mot de passe. Il vérifie le client, l'adresse de retour et PKCE comme
+// un vrai fournisseur.
+type MockIssuer struct {
+	Issuer   string
+	ClientID string
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+// mockGrant est une autorisation en attente d'échange contre les jetons
+type mockGrant struct {
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	username      string
+	email         string
+	expiresAt     time.Time
+}
+
+func NewMockIssuer(issuer, clientID string) (*MockIssuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &MockIssuer{
+		Issuer:   strings.TrimSuffix(issuer, "/"),
+		ClientID: clientID,
+		key:      key,
+		codes:    make(map[string]mockGrant),
+	}, nil
+}
+
+func (m *MockIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                m.Issuer,
+			"authorization_endpoint":                m.Issuer + "/authorize",
+			"token_endpoint":                        m.Issuer + "/token",
+			"jwks_uri":                              m.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/jwks":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []jsonWebKey{encodeKey("mock", &m.key.PublicKey)},
+		})
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var mockLoginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="fr"><head><meta charset="UTF-8"><title>Fournisseur d'identité de test</title></head>
+<body>
+<h1>Fournisseur d'identité de test</h1>
+<form method="POST">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{index $value 0}}">
+{{end}}<p><label>Pseudo <input name="username" value="dev" required></label></p>
+<p><label>Email <input name="email" type="email" value="dev@example.com" required></label></p>
+<button type="submit">Se connecter</button>
+</form>
+</body></html>`))
+
+// authorize affiche la page de connexion (GET) puis redirige vers le client
+// avec un code d'autorisation (POST)
+func (m *MockIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "requête invalide", http.StatusBadRequest)
+		return
+	}
+	params := r.Form
+
+	if params.Get("client_id") != m.ClientID || params.Get("response_type") != "code" ||
+		params.Get("redirect_uri") == "" || params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "" {
+		http.Error(w, "paramètres d'autorisation invalides", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		mockLoginPage.Execute(w, map[string]interface{}{"Params": r.URL.Query()})
+		return
+	}
+
+	code, err := RandomString()
+	if err != nil {
+		http.Error(w, "erreur interne", http.StatusInternalServerError)
+		return
+	}
+	m.mu.Lock()
+	m.codes[code] = mockGrant{
+		redirectURI:   params.Get("redirect_uri"),
+		codeChallenge: params.Get("code_challenge"),
+		nonce:         params.Get("nonce"),
+		username:      params.Get("username"),
+		email:         params.Get("email"),
+		expiresAt:     time.Now().Add(mockCodeTTL),
+	}
+	m.mu.Unlock()
+
+	redirect, err := url.Parse(params.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "redirect_uri invalide", http.StatusBadRequest)
+		return
+	}
+	query := redirect.Query()
+	query.Set("code", code)
+	query.Set("state", params.Get("state"))
+	redirect.RawQuery = query.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token échange un code d'autorisation contre un jeton d'identité signé
+func (m *MockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	m.mu.Lock()
+	grant, ok := m.codes[code]
+	delete(m.codes, code)
+	m.mu.Unlock()
+
+	clientID := r.PostForm.Get("client_id")
+	if user, _, hasBasic := r.BasicAuth(); hasBasic {
+		clientID, _ = url.QueryUnescape(user)
+	}
+
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	case !ok || time.Now().After(grant.expiresAt):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case clientID != m.ClientID:
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	case r.PostForm.Get("redirect_uri") != grant.redirectURI,
+		CodeChallenge(r.PostForm.Get("code_verifier")) != grant.codeChallenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := &IDClaims{
+		Nonce:             grant.nonce,
+		Email:             grant.email,
+		EmailVerified:     true,
+		PreferredUsername: grant.username,
+		Name:              grant.username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.Issuer,
+			Subject:   "mock|" + grant.username,
+			Audience:  jwt.ClaimStrings{m.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = "mock"
+	idToken, err := t.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": idToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
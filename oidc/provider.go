@@ -0,0 +1,196 @@
+// Package oidc implémente la connexion via un fournisseur d'identité OpenID
+// Connect : découverte de la configuration, flux "authorization code" avec
+// PKCE (S256) et vérification du jeton d'identité (signature RS256, iss, aud,
+// exp et nonce).
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Tolérance sur les horloges pour exp, iat et nbf
+const leeway = time.Minute
+
+var ErrInvalidIDToken = errors.New("oidc: jeton d'identité invalide")
+
+// Config décrit un fournisseur d'identité
+type Config struct {
+	// Identifiant interne du fournisseur, utilisé dans les URL et la base
+	Name string
+	// Nom affiché sur la page de connexion
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string // Vide pour un client public (PKCE seul)
+	RedirectURL  string
+	Scopes       []string
+}
+
+// discovery reprend les champs utiles de /.well-known/openid-configuration
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider est un fournisseur d'identité dont la configuration est chargée
+// à la première utilisation
+type Provider struct {
+	Config
+	client *http.Client
+
+	mu   sync.Mutex
+	doc  *discovery
+	keys *keySet
+}
+
+// IDClaims contient les informations d'identité renvoyées par le fournisseur
+type IDClaims struct {
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+func NewProvider(config Config) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		Config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// discover charge le document de découverte, une seule fois
+func (p *Provider) discover(ctx context.Context) (*discovery, *keySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.doc != nil {
+		return p.doc, p.keys, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	doc := &discovery{}
+	if err := getJSON(ctx, p.client, wellKnown, doc); err != nil {
+		return nil, nil, err
+	}
+	// L'émetteur annoncé doit être exactement celui configuré (OIDC Discovery 4.3)
+	if doc.Issuer != p.Issuer {
+		return nil, nil, fmt.Errorf("oidc: émetteur %q annoncé au lieu de %q", doc.Issuer, p.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, nil, errors.New("oidc: document de découverte incomplet")
+	}
+
+	p.doc = doc
+	p.keys = &keySet{uri: doc.JWKSURI, client: p.client}
+	return p.doc, p.keys, nil
+}
+
+// AuthCodeURL renvoie l'adresse de la page de connexion du fournisseur
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	doc, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", p.RedirectURL)
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange échange le code d'autorisation contre les jetons et renvoie
+// l'identité vérifiée
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDClaims, error) {
+	doc, keys, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return nil, fmt.Errorf("oidc: échange du code refusé (%s): %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+
+	return p.verifyIDToken(ctx, keys, body.IDToken, nonce)
+}
+
+// verifyIDToken vérifie la signature et les claims du jeton d'identité
+func (p *Provider) verifyIDToken(ctx context.Context, keys *keySet, raw, nonce string) (*IDClaims, error) {
+	claims := &IDClaims{}
+	t, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(leeway),
+	)
+	if err != nil || !t.Valid {
+		return nil, ErrInvalidIDToken
+	}
+	if claims.Subject == "" || claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, ErrInvalidIDToken
+	}
+	return claims, nil
+}
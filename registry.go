@@ -0,0 +1,176 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// GameRegistry référence les parties en cours. Il est partagé entre la
+// goroutine de matchmaking, les connexions WebSocket et les handlers HTTP,
+// d'où le verrou. Il ne verrouille jamais une partie lui-même.
+type GameRegistry struct {
+	mu    sync.RWMutex
+	games map[string]*Game
+}
+
+func NewGameRegistry() *GameRegistry {
+	return &GameRegistry{games: make(map[string]*Game)}
+}
+
+func (r *GameRegistry) Add(game *Game) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.games[game.ID] = game
+}
+
+func (r *GameRegistry) Get(id string) *Game {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.games[id]
+}
+
+func (r *GameRegistry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.games, id)
+}
+
+// List renvoie les parties en cours, de la plus ancienne à la plus récente
+func (r *GameRegistry) List() []*Game {
+	r.mu.RLock()
+	list := make([]*Game, 0, len(r.games))
+	for _, game := range r.games {
+		list = append(list, game)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.Before(list[j].StartedAt)
+	})
+	return list
+}
+
+// Connection est une connexion WebSocket ouverte, de joueur ou de spectateur
+type Connection struct {
+	Player      *Player
+	Role        string // "player" ou "spectator"
+	RemoteAddr  string
+	ConnectedAt time.Time
+}
+
+// ConnectionRegistry référence les connexions WebSocket ouvertes, indexées
+// par l'identifiant du joueur, pour le tableau de bord d'administration
+type ConnectionRegistry struct {
+	mu          sync.RWMutex
+	connections map[string]*Connection
+}
+
+func NewConnectionRegistry() *ConnectionRegistry {
+	return &ConnectionRegistry{connections: make(map[string]*Connection)}
+}
+
+func (r *ConnectionRegistry) Add(conn *Connection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connections[conn.Player.ID] = conn
+}
+
+func (r *ConnectionRegistry) Get(playerID string) *Connection {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.connections[playerID]
+}
+
+func (r *ConnectionRegistry) Remove(playerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.connections, playerID)
+}
+
+// List renvoie les connexions ouvertes, de la plus ancienne à la plus récente
+func (r *ConnectionRegistry) List() []*Connection {
+	r.mu.RLock()
+	list := make([]*Connection, 0, len(r.connections))
+	for _, conn := range r.connections {
+		list = append(list, conn)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ConnectedAt.Before(list[j].ConnectedAt)
+	})
+	return list
+}
+
+// ByUser renvoie les connexions ouvertes d'un utilisateur connecté
+func (r *ConnectionRegistry) ByUser(userID int) []*Connection {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var list []*Connection
+	for _, conn := range r.connections {
+		if conn.Player.UserID == userID {
+			list = append(list, conn)
+		}
+	}
+	return list
+}
+
+// queuedPlayer est un joueur en attente d'un adversaire
+type queuedPlayer struct {
+	Player   *Player
+	Settings GameSettings
+	Since    time.Time
+}
+
+// MatchQueue contient les joueurs en attente d'un adversaire, un au plus par
+// combinaison d'options. Elle est alimentée par la goroutine de matchmaking
+// et consultée par le tableau de bord d'administration.
+type MatchQueue struct {
+	mu      sync.Mutex
+	pending map[GameSettings]queuedPlayer
+}
+
+func NewMatchQueue() *MatchQueue {
+	return &MatchQueue{pending: make(map[GameSettings]queuedPlayer)}
+}
+
+// Pair renvoie l'adversaire qui attendait avec les mêmes options et le
+// retire de la file. S'il n'y en a pas, le joueur est mis en attente.
+func (q *MatchQueue) Pair(player *Player, settings GameSettings) (*Player, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	waiting, ok := q.pending[settings]
+	if !ok {
+		q.pending[settings] = queuedPlayer{Player: player, Settings: settings, Since: time.Now()}
+		return nil, false
+	}
+	delete(q.pending, settings)
+	return waiting.Player, true
+}
+
+// Remove retire un joueur de la file, par exemple lorsqu'il se déconnecte
+func (q *MatchQueue) Remove(player *Player) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for settings, waiting := range q.pending {
+		if waiting.Player == player {
+			delete(q.pending, settings)
+		}
+	}
+}
+
+// List renvoie les joueurs en attente, du plus ancien au plus récent
+func (q *MatchQueue) List() []queuedPlayer {
+	q.mu.Lock()
+	list := make([]queuedPlayer, 0, len(q.pending))
+	for _, waiting := range q.pending {
+		list = append(list, waiting)
+	}
+	q.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Since.Before(list[j].Since)
+	})
+	return list
+}
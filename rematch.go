@@ -0,0 +1,205 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Délai laissé à l'adversaire pour accepter une revanche avant que le
+// demandeur ne soit remis dans la file d'attente
+const rematchTimeout = 30 * time.Second
+
+// lastMatchup retient, pour un joueur, l'adversaire et les options de sa
+// dernière partie terminée
+type lastMatchup struct {
+	opponent *Player
+	settings GameSettings
+}
+
+// rematchOffer est une demande de revanche en attente de réponse
+type rematchOffer struct {
+	from     *Player
+	to       *Player
+	settings GameSettings
+	timer    rematchTimer
+}
+
+// rematchTimer est le minuteur d'expiration d'une demande de revanche
+type rematchTimer interface {
+	Stop() bool
+}
+
+// startRematchTimer appelle f après d. Les tests la remplacent pour faire
+// avancer le temps eux-mêmes.
+var startRematchTimer = func(d time.Duration, f func()) rematchTimer {
+	return time.AfterFunc(d, f)
+}
+
+var (
+	rematchMu     sync.Mutex
+	lastMatchups  = make(map[*Player]lastMatchup)
+	rematchOffers = make(map[*Player]*rematchOffer) // Indexées par le joueur invité
+)
+
+// recordFinishedGame rend possible une revanche entre les joueurs d'une partie terminée
+func recordFinishedGame(g *Game) {
+	rematchMu.Lock()
+	defer rematchMu.Unlock()
+
+	for p := range g.Players {
+		for opponent := range g.Players {
+			if opponent != p {
+				lastMatchups[p] = lastMatchup{opponent: opponent, settings: g.Settings}
+			}
+		}
+	}
+}
+
+// requestRematch propose une revanche à l'adversaire de la dernière partie.
+// Si celui-ci l'avait déjà demandée, la revanche démarre immédiatement.
+func requestRematch(player *Player) {
+	rematchMu.Lock()
+
+	matchup, ok := lastMatchups[player]
+	if !ok {
+		rematchMu.Unlock()
+		player.WriteJSON(map[string]interface{}{
+			"type":    "error",
+			"message": "Aucune partie à rejouer.",
+		})
+		return
+	}
+
+	if offer := rematchOffers[player]; offer != nil && offer.from == matchup.opponent {
+		rematchMu.Unlock()
+		acceptRematch(player)
+		return
+	}
+
+	// L'adversaire s'est déconnecté ou a déjà relancé une partie
+	if opponentMatchup, ok := lastMatchups[matchup.opponent]; !ok || opponentMatchup.opponent != player {
+		delete(lastMatchups, player)
+		rematchMu.Unlock()
+		player.WriteJSON(map[string]interface{}{
+			"type":    "rematch_unavailable",
+			"message": "Votre adversaire n'est plus disponible.",
+		})
+		requeue(player, matchup.settings)
+		return
+	}
+
+	if rematchOffers[matchup.opponent] != nil {
+		rematchMu.Unlock()
+		return
+	}
+
+	offer := &rematchOffer{from: player, to: matchup.opponent, settings: matchup.settings}
+	offer.timer = startRematchTimer(rematchTimeout, func() {
+		expireRematch(offer)
+	})
+	rematchOffers[matchup.opponent] = offer
+	rematchMu.Unlock()
+
+	matchup.opponent.WriteJSON(map[string]interface{}{
+		"type":    "rematch_request",
+		"from":    player.Name(),
+		"timeout": int(rematchTimeout.Seconds()),
+	})
+	player.WriteJSON(map[string]interface{}{
+		"type":    "rematch_pending",
+		"timeout": int(rematchTimeout.Seconds()),
+	})
+}
+
+// acceptRematch lance une nouvelle partie avec les mêmes options
+func acceptRematch(player *Player) {
+	rematchMu.Lock()
+	offer := rematchOffers[player]
+	if offer == nil {
+		rematchMu.Unlock()
+		player.WriteJSON(map[string]interface{}{
+			"type":    "error",
+			"message": "Aucune demande de revanche en attente.",
+		})
+		return
+	}
+	offer.timer.Stop()
+	delete(rematchOffers, player)
+	delete(lastMatchups, offer.from)
+	delete(lastMatchups, offer.to)
+	rematchMu.Unlock()
+
+	startGame(offer.from, offer.to, offer.settings)
+}
+
+// declineRematch refuse la revanche et renvoie le demandeur dans la file d'attente
+func declineRematch(player *Player) {
+	rematchMu.Lock()
+	offer := rematchOffers[player]
+	if offer == nil {
+		rematchMu.Unlock()
+		return
+	}
+	offer.timer.Stop()
+	delete(rematchOffers, player)
+	delete(lastMatchups, offer.from)
+	delete(lastMatchups, offer.to)
+	rematchMu.Unlock()
+
+	offer.from.WriteJSON(map[string]interface{}{
+		"type":    "rematch_declined",
+		"message": "Votre adversaire a refusé la revanche.",
+	})
+	requeue(offer.from, offer.settings)
+}
+
+func expireRematch(offer *rematchOffer) {
+	rematchMu.Lock()
+	if rematchOffers[offer.to] != offer {
+		rematchMu.Unlock()
+		return
+	}
+	delete(rematchOffers, offer.to)
+	delete(lastMatchups, offer.from)
+	delete(lastMatchups, offer.to)
+	rematchMu.Unlock()
+
+	offer.to.WriteJSON(map[string]interface{}{
+		"type": "rematch_expired",
+	})
+	offer.from.WriteJSON(map[string]interface{}{
+		"type":    "rematch_expired",
+		"message": "Votre adversaire n'a pas répondu.",
+	})
+	requeue(offer.from, offer.settings)
+}
+
+// leaveRematch oublie un joueur qui se déconnecte. Une demande qui lui
+// était adressée est considérée comme refusée.
+func leaveRematch(player *Player) {
+	declineRematch(player)
+
+	rematchMu.Lock()
+	delete(lastMatchups, player)
+	var cancelled []*Player
+	for to, offer := range rematchOffers {
+		if offer.from == player {
+			offer.timer.Stop()
+			delete(rematchOffers, to)
+			delete(lastMatchups, to)
+			cancelled = append(cancelled, to)
+		}
+	}
+	rematchMu.Unlock()
+
+	for _, to := range cancelled {
+		to.WriteJSON(map[string]interface{}{
+			"type": "rematch_expired",
+		})
+	}
+}
+
+// requeue remet un joueur dans la file d'attente du matchmaking
+func requeue(player *Player, settings GameSettings) {
+	waitingPlayers <- waitingPlayer{player: player, settings: settings}
+}
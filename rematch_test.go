@@ -0,0 +1,184 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock remplace startRematchTimer : le temps n'avance que par Advance
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Duration
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	at      time.Duration
+	f       func()
+	stopped bool
+	fired   bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := !t.stopped && !t.fired
+	t.stopped = true
+	return active
+}
+
+// useFakeClock installe une horloge de test pour les revanches
+func useFakeClock(t *testing.T) *fakeClock {
+	clock := &fakeClock{}
+	previous := startRematchTimer
+	startRematchTimer = func(d time.Duration, f func()) rematchTimer {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		timer := &fakeTimer{clock: clock, at: clock.now + d, f: f}
+		clock.timers = append(clock.timers, timer)
+		return timer
+	}
+	t.Cleanup(func() { startRematchTimer = previous })
+	return clock
+}
+
+// Advance fait avancer le temps et déclenche les minuteurs arrivés à échéance
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now += d
+	var due []func()
+	for _, timer := range c.timers {
+		if !timer.stopped && !timer.fired && timer.at <= c.now {
+			timer.fired = true
+			due = append(due, timer.f)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, f := range due {
+		f()
+	}
+}
+
+// onlyTimer renvoie l'unique minuteur programmé
+func (c *fakeClock) onlyTimer(t *testing.T) *fakeTimer {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.timers) != 1 {
+		t.Fatalf("%d minuteurs programmés, 1 attendu", len(c.timers))
+	}
+	return c.timers[0]
+}
+
+func (timer *fakeTimer) isStopped() bool {
+	timer.clock.mu.Lock()
+	defer timer.clock.mu.Unlock()
+	return timer.stopped
+}
+
+// expectRequeued attend que player soit remis dans la file d'attente
+func expectRequeued(t *testing.T, player *Player, settings GameSettings) {
+	t.Helper()
+	select {
+	case waiting := <-waitingPlayers:
+		if waiting.player != player || waiting.settings != settings {
+			t.Errorf("file d'attente: %s avec %+v, %s avec %+v attendu", waiting.player.ID, waiting.settings, player.ID, settings)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s n'a pas été remis dans la file d'attente", player.ID)
+	}
+}
+
+// offerRematch termine une partie entre deux joueurs et fait demander une
+// revanche par le premier
+func offerRematch(t *testing.T, settings GameSettings) (*Player, *testClient, *Player, *testClient) {
+	t.Helper()
+	setupGameTest(t)
+	alice, aliceClient := newTestPlayer(t, "alice")
+	bob, bobClient := newTestPlayer(t, "bob")
+	recordFinishedGame(NewGame("partie-terminee", "RACINE", settings, alice, bob))
+	t.Cleanup(func() {
+		leaveRematch(alice)
+		leaveRematch(bob)
+	})
+
+	requestRematch(alice)
+	request := bobClient.next(t, "rematch_request")
+	if request["from"] != "alice" || request["timeout"] != rematchTimeout.Seconds() {
+		t.Errorf("demande de revanche: %v", request)
+	}
+	aliceClient.next(t, "rematch_pending")
+	return alice, aliceClient, bob, bobClient
+}
+
+func TestRematchAccepted(t *testing.T) {
+	clock := useFakeClock(t)
+	settings := GameSettings{OpponentProgress: true}
+	alice, aliceClient, bob, bobClient := offerRematch(t, settings)
+	timer := clock.onlyTimer(t)
+	if timer.at != rematchTimeout {
+		t.Errorf("expiration programmée à %v, %v attendu", timer.at, rematchTimeout)
+	}
+
+	acceptRematch(bob)
+	for _, client := range []*testClient{aliceClient, bobClient} {
+		start := client.next(t, "game_start")
+		if got, _ := start["settings"].(map[string]interface{}); got["opponent_progress"] != true {
+			t.Errorf("revanche: options %v, celles de la partie précédente attendues", start["settings"])
+		}
+		games.Remove(start["game_id"].(string))
+	}
+	if !timer.isStopped() {
+		t.Error("revanche acceptée: minuteur toujours actif")
+	}
+
+	// La revanche est consommée
+	requestRematch(alice)
+	aliceClient.next(t, "error")
+}
+
+func TestRematchRequestedByBoth(t *testing.T) {
+	useFakeClock(t)
+	_, aliceClient, bob, bobClient := offerRematch(t, GameSettings{})
+
+	// Demander la revanche déjà proposée par l'adversaire revient à l'accepter
+	requestRematch(bob)
+	for _, client := range []*testClient{aliceClient, bobClient} {
+		games.Remove(client.next(t, "game_start")["game_id"].(string))
+	}
+}
+
+func TestRematchDeclined(t *testing.T) {
+	clock := useFakeClock(t)
+	settings := GameSettings{Private: true}
+	alice, aliceClient, bob, bobClient := offerRematch(t, settings)
+
+	go declineRematch(bob)
+	expectRequeued(t, alice, settings)
+	aliceClient.next(t, "rematch_declined")
+	bobClient.none(t)
+	if !clock.onlyTimer(t).isStopped() {
+		t.Error("revanche refusée: minuteur toujours actif")
+	}
+}
+
+func TestRematchExpires(t *testing.T) {
+	clock := useFakeClock(t)
+	settings := GameSettings{OpponentProgress: true}
+	alice, aliceClient, bob, bobClient := offerRematch(t, settings)
+
+	clock.Advance(rematchTimeout - time.Second)
+	aliceClient.none(t)
+
+	go clock.Advance(time.Second)
+	expectRequeued(t, alice, settings)
+	aliceClient.next(t, "rematch_expired")
+	bobClient.next(t, "rematch_expired")
+
+	// Trop tard pour accepter
+	acceptRematch(bob)
+	bobClient.next(t, "error")
+}
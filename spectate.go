@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// handleSpectate permet de suivre une partie publique en direct via
+// /ws/spectate?game=ID. Les messages reçus du spectateur sont ignorés.
+func handleSpectate(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	spectator := &Player{Conn: conn, ID: uuid.New().String()}
+	connections.Add(&Connection{Player: spectator, Role: "spectator", RemoteAddr: r.RemoteAddr, ConnectedAt: time.Now()})
+	defer connections.Remove(spectator.ID)
+
+	game := games.Get(r.URL.Query().Get("game"))
+	if game == nil {
+		spectator.WriteJSON(map[string]interface{}{
+			"type":    "error",
+			"message": "Partie introuvable ou terminée.",
+		})
+		return
+	}
+
+	if err := game.AddSpectator(spectator); err != nil {
+		spectator.WriteJSON(map[string]interface{}{
+			"type":    "error",
+			"message": "Cette partie ne peut pas être regardée.",
+		})
+		return
+	}
+	defer game.RemoveSpectator(spectator)
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// liveMatchesHandler liste les parties publiques en cours
+func liveMatchesHandler(w http.ResponseWriter, r *http.Request) {
+	matches := []map[string]interface{}{}
+	for _, game := range games.List() {
+		if game.Settings.Private {
+			continue
+		}
+		matches = append(matches, game.LiveInfo())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matches)
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestAddSpectator(t *testing.T) {
+	setupGameTest(t)
+	alice, _ := newTestPlayer(t, "alice")
+	bob, _ := newTestPlayer(t, "bob")
+
+	public := NewGame("partie-publique", "RACINE", GameSettings{}, alice, bob)
+	spectator, spectatorClient := newTestPlayer(t, "spectateur")
+	if err := public.AddSpectator(spectator); err != nil {
+		t.Fatalf("partie publique: %v", err)
+	}
+	start := spectatorClient.next(t, "spectate_start")
+	if start["game_id"] != "partie-publique" || start["word_length"] != float64(6) {
+		t.Errorf("début du suivi: %v", start)
+	}
+
+	private := NewGame("partie-privee", "RACINE", GameSettings{Private: true}, alice, bob)
+	refused, refusedClient := newTestPlayer(t, "curieux")
+	if err := private.AddSpectator(refused); err == nil {
+		t.Error("partie privée: spectateur accepté")
+	}
+	if len(private.Spectators) != 0 {
+		t.Errorf("partie privée: %d spectateurs inscrits", len(private.Spectators))
+	}
+	refusedClient.none(t)
+
+	// Une partie terminée n'accepte plus de spectateurs
+	if !public.Abort("Partie arrêtée") {
+		t.Fatal("Abort: partie déjà terminée")
+	}
+	late, lateClient := newTestPlayer(t, "retardataire")
+	if err := public.AddSpectator(late); err == nil {
+		t.Error("partie terminée: spectateur accepté")
+	}
+	if public.Spectators[late] {
+		t.Error("partie terminée: spectateur inscrit")
+	}
+	lateClient.none(t)
+}
@@ -1,124 +1,605 @@
-import { checkAuth } from './auth.js';
+import { checkAuth, getValidToken } from './auth.js';
 
-// Fonction pour charger la liste des utilisateurs
-async function loadUsers() {
-    const token = localStorage.getItem('token');
+const USERS_PER_PAGE = 25;
+const AUDIT_PER_PAGE = 50;
+const WORDS_PER_PAGE = 100;
+const REPORTS_PER_PAGE = 25;
+let currentPage = 1;
+let auditPage = 1;
+let dictionaryPage = 1;
+let reportsPage = 1;
+
+// Envoie une requête authentifiée à l'API d'administration
+async function adminRequest(path, method = 'GET', body) {
+    const token = await getValidToken();
     if (!token) {
         window.location.href = '/html/login.html';
+        return null;
+    }
+
+    const headers = { 'Authorization': `Bearer ${token}` };
+    if (body instanceof Blob) {
+        // Fichier envoyé tel quel (import du dictionnaire)
+        headers['Content-Type'] = body.type || 'text/plain';
+    } else if (body !== undefined) {
+        headers['Content-Type'] = 'application/json';
+        body = JSON.stringify(body);
+    }
+    const response = await fetch(path, { method, headers, body });
+    if (response.status === 401) {
+        localStorage.removeItem('token');
+        window.location.href = '/html/login.html';
+        return null;
+    }
+    if (!response.ok) {
+        alert(`Erreur: ${(await response.text()).trim()}`);
+        return null;
+    }
+    return response;
+}
+
+// Récupère les permissions de l'utilisateur connecté
+async function loadPermissions() {
+    const response = await adminRequest('/api/profile');
+    if (!response) {
+        return [];
+    }
+    const profile = await response.json();
+    return profile.permissions || [];
+}
+
+// Fonction pour charger la liste des utilisateurs
+async function loadUsers() {
+    const params = new URLSearchParams({
+        page: currentPage,
+        limit: USERS_PER_PAGE,
+        q: document.getElementById('users-search').value,
+        status: document.getElementById('users-status').value,
+        sort: document.getElementById('users-sort').value,
+        order: document.getElementById('users-order').value
+    });
+
+    const response = await adminRequest(`/api/admin/users?${params}`);
+    if (!response) {
         return;
     }
+    const data = await response.json();
+    const usersList = document.querySelector('.users-list');
 
-    try {
-        const response = await fetch('/api/admin/users', {
-            headers: {
-                'Authorization': `Bearer ${token}`
-            }
+    // Créer le tableau des utilisateurs
+    const table = document.createElement('table');
+    table.className = 'users-table';
+
+    // En-tête du tableau
+    const thead = document.createElement('thead');
+    thead.innerHTML = `
+        <tr>
+            <th>Pseudo</th>
+            <th>Email</th>
+            <th>Date d'inscription</th>
+            <th>Statut</th>
+            <th>Rôles</th>
+            <th>Actions</th>
+        </tr>
+    `;
+    table.appendChild(thead);
+
+    // Corps du tableau
+    const tbody = document.createElement('tbody');
+    const options = { year: 'numeric', month: 'long', day: 'numeric' };
+    data.users.forEach(user => {
+        const tr = document.createElement('tr');
+        const cells = [
+            user.username,
+            user.email,
+            new Date(user.created_at).toLocaleDateString('fr-FR', options),
+            userStatus(user),
+            (user.roles || []).join(', ')
+        ];
+        cells.forEach(text => {
+            const td = document.createElement('td');
+            td.textContent = text;
+            tr.appendChild(td);
+        });
+        if (user.deleted_at) {
+            tr.classList.add('user-deleted');
+        } else if (user.suspension) {
+            tr.classList.add('user-suspended');
+        }
+
+        const actions = document.createElement('td');
+        userActions(user).forEach(([label, className, handler]) => {
+            const button = document.createElement('button');
+            button.className = className;
+            button.textContent = label;
+            button.addEventListener('click', handler);
+            actions.appendChild(button);
         });
+        tr.appendChild(actions);
+        tbody.appendChild(tr);
+    });
+    table.appendChild(tbody);
+
+    // Remplacer le contenu existant par le nouveau tableau
+    usersList.innerHTML = '';
+    usersList.appendChild(table);
+
+    const pages = Math.max(1, Math.ceil(data.total / data.limit));
+    document.getElementById('users-page').textContent = `Page ${data.page} / ${pages} (${data.total} utilisateurs)`;
+    document.getElementById('users-prev').disabled = data.page <= 1;
+    document.getElementById('users-next').disabled = data.page >= pages;
+}
+
+function userStatus(user) {
+    if (user.deleted_at) {
+        return 'Supprimé';
+    }
+    if (user.suspension) {
+        const until = user.suspension.expires_at
+            ? `jusqu'au ${new Date(user.suspension.expires_at).toLocaleString('fr-FR')}`
+            : 'définitivement';
+        return `Suspendu ${until} : ${user.suspension.reason}`;
+    }
+    return user.is_admin ? 'Administrateur' : 'Actif';
+}
+
+// Boutons d'action d'une ligne : [libellé, classe, action]
+function userActions(user) {
+    const actions = [['Détails', 'btn-primary', () => showUserDetail(user.id)]];
+    if (user.deleted_at) {
+        actions.push(['Restaurer', 'btn-primary', () => userAction(user, 'restore', 'POST')]);
+        return actions;
+    }
 
-        if (!response.ok) {
-            throw new Error('Erreur lors de la récupération des utilisateurs');
+    actions.push(['Rôles', 'btn-primary', () => editRoles(user)]);
+    actions.push(user.is_admin
+        ? ['Rétrograder', 'btn-primary', () => userAction(user, 'admin', 'POST', { admin: false })]
+        : ['Promouvoir admin', 'btn-primary', () => userAction(user, 'admin', 'POST', { admin: true })]);
+    actions.push(user.suspension
+        ? ['Lever la suspension', 'btn-primary', () => userAction(user, 'suspend', 'DELETE')]
+        : ['Suspendre', 'btn-danger', () => suspendUser(user)]);
+    actions.push(['Réinitialiser le mot de passe', 'btn-danger', () => {
+        if (confirm(`Invalider le mot de passe de ${user.username} et lui envoyer un lien de réinitialisation ?`)) {
+            userAction(user, 'password-reset', 'POST');
+        }
+    }]);
+    actions.push(['Supprimer', 'btn-danger', () => {
+        if (confirm('Êtes-vous sûr de vouloir supprimer cet utilisateur ?')) {
+            userAction(user, '', 'DELETE');
         }
+    }]);
+    return actions;
+}
 
-        const users = await response.json();
-        const usersList = document.querySelector('.users-list');
-        
-        // Créer le tableau des utilisateurs
-        const table = document.createElement('table');
-        table.className = 'users-table';
-        
-        // En-tête du tableau
-        const thead = document.createElement('thead');
-        thead.innerHTML = `
+// Exécute une action sur un utilisateur puis recharge la liste
+async function userAction(user, action, method, body) {
+    const path = action ? `/api/admin/users/${user.id}/${action}` : `/api/admin/users/${user.id}`;
+    if (await adminRequest(path, method, body)) {
+        loadUsers();
+    }
+}
+
+// Remplace les rôles d'un utilisateur, saisis séparés par des virgules
+function editRoles(user) {
+    const input = prompt(`Rôles de ${user.username} (séparés par des virgules) :`, (user.roles || []).join(', '));
+    if (input === null) {
+        return;
+    }
+    const roles = input.split(',').map(role => role.trim()).filter(role => role !== '');
+    userAction(user, 'roles', 'PUT', { roles });
+}
+
+function suspendUser(user) {
+    const reason = prompt(`Motif de la suspension de ${user.username} :`);
+    if (!reason) {
+        return;
+    }
+    const duration = prompt('Durée en heures (laisser vide pour un bannissement définitif) :');
+    if (duration === null) {
+        return;
+    }
+    const body = { reason };
+    if (duration.trim() !== '') {
+        body.duration = `${parseFloat(duration)}h`;
+    }
+    userAction(user, 'suspend', 'POST', body);
+}
+
+// Affiche le détail et les statistiques d'un utilisateur
+async function showUserDetail(userId) {
+    const response = await adminRequest(`/api/admin/users/${userId}`);
+    if (!response) {
+        return;
+    }
+    const { user, stats } = await response.json();
+    const detail = document.getElementById('user-detail');
+    detail.innerHTML = '';
+
+    const title = document.createElement('h3');
+    title.textContent = user.username;
+    detail.appendChild(title);
+
+    const lines = [
+        `Email : ${user.email}${user.email_verified ? ' (vérifié)' : ''}`,
+        `Statut : ${userStatus(user)}`,
+        `Parties jouées : ${stats.matches_played}, gagnées : ${stats.matches_won}`,
+        `Tentatives : ${stats.guesses}, messages de chat : ${stats.chat_messages}`,
+        `Sessions actives : ${stats.active_sessions}`,
+        `Dernière connexion : ${stats.last_login_at ? new Date(stats.last_login_at).toLocaleString('fr-FR') : 'jamais'}`
+    ];
+    lines.forEach(text => {
+        const p = document.createElement('p');
+        p.textContent = text;
+        detail.appendChild(p);
+    });
+    detail.style.display = 'block';
+}
+
+// Charge une page du dictionnaire
+async function loadDictionary() {
+    const params = new URLSearchParams({
+        page: dictionaryPage,
+        limit: WORDS_PER_PAGE,
+        q: document.getElementById('dictionary-search').value.trim(),
+        flagged: document.getElementById('dictionary-flagged').value
+    });
+
+    const response = await adminRequest(`/api/admin/dictionary?${params}`);
+    if (!response) {
+        return;
+    }
+    const data = await response.json();
+    document.getElementById('dictionary-summary').textContent =
+        `${data.accepted} mots acceptés, dont ${data.playable} pouvant être tirés comme mot mystère.`;
+
+    const table = document.createElement('table');
+    table.className = 'users-table';
+    table.innerHTML = `
+        <thead>
             <tr>
-                <th>Pseudo</th>
-                <th>Email</th>
-                <th>Date d'inscription</th>
-                <th>Admin</th>
+                <th>Mot</th>
+                <th>Définition</th>
+                <th>Signalement</th>
                 <th>Actions</th>
             </tr>
-        `;
-        table.appendChild(thead);
-        
-        // Corps du tableau
-        const tbody = document.createElement('tbody');
-        users.forEach(user => {
-            const tr = document.createElement('tr');
-            const createdAt = new Date(user.created_at);
-            const options = { year: 'numeric', month: 'long', day: 'numeric' };
-            
-            // Créer le bouton de suppression séparément pour un meilleur contrôle
-            let deleteButton = '';
-            if (!user.is_admin) {
-                const button = document.createElement('button');
-                button.className = 'btn-danger delete-user';
-                button.dataset.id = user.id;
-                button.textContent = 'Supprimer';
-                deleteButton = button.outerHTML;
+        </thead>
+    `;
+    const tbody = document.createElement('tbody');
+    data.words.forEach(entry => {
+        const tr = document.createElement('tr');
+        [entry.word, entry.definition || '', entry.flagged ? entry.flag_reason : ''].forEach(text => {
+            const td = document.createElement('td');
+            td.textContent = text;
+            tr.appendChild(td);
+        });
+        if (entry.flagged) {
+            tr.classList.add('user-suspended');
+        }
+
+        const actions = document.createElement('td');
+        const flag = document.createElement('button');
+        flag.className = 'btn-primary';
+        flag.textContent = entry.flagged ? 'Retirer le signalement' : 'Signaler';
+        flag.addEventListener('click', () => flagWord(entry));
+        actions.appendChild(flag);
+        const remove = document.createElement('button');
+        remove.className = 'btn-danger';
+        remove.textContent = 'Supprimer';
+        remove.addEventListener('click', async () => {
+            if (confirm(`Retirer ${entry.word} du dictionnaire ?`)
+                && await adminRequest(`/api/admin/dictionary/${entry.word}`, 'DELETE')) {
+                loadDictionary();
             }
-            
-            tr.innerHTML = `
-                <td>${user.username}</td>
-                <td>${user.email}</td>
-                <td>${createdAt.toLocaleDateString('fr-FR', options)}</td>
-                <td>${user.is_admin ? '✅' : '❌'}</td>
-                <td>${deleteButton}</td>
-            `;
-            tbody.appendChild(tr);
         });
-        table.appendChild(tbody);
-        
-        // Remplacer le contenu existant par le nouveau tableau
-        usersList.innerHTML = '';
-        usersList.appendChild(table);
-        
-        // Ajouter les écouteurs d'événements pour les boutons de suppression
-        document.querySelectorAll('.delete-user').forEach(button => {
-            button.addEventListener('click', async (e) => {
-                const userId = parseInt(e.currentTarget.dataset.id, 10);
-                
-                // Vérifier que l'ID est bien un nombre
-                if (isNaN(userId) || userId <= 0) {
-                    alert('ID utilisateur invalide');
-                    return;
-                }
+        actions.appendChild(remove);
+        tr.appendChild(actions);
+        tbody.appendChild(tr);
+    });
+    table.appendChild(tbody);
+
+    const list = document.querySelector('.dictionary-list');
+    list.innerHTML = '';
+    list.appendChild(table);
+
+    const pages = Math.max(1, Math.ceil(data.total / data.limit));
+    document.getElementById('dictionary-page').textContent = `Page ${data.page} / ${pages} (${data.total} mots)`;
+    document.getElementById('dictionary-prev').disabled = data.page <= 1;
+    document.getElementById('dictionary-next').disabled = data.page >= pages;
+}
+
+// Signale un mot, qui n'est plus tiré comme mot mystère, ou retire le signalement
+async function flagWord(entry) {
+    let body;
+    if (!entry.flagged) {
+        const reason = prompt(`Motif du signalement de ${entry.word} :`);
+        if (!reason) {
+            return;
+        }
+        body = { reason };
+    }
+    const method = entry.flagged ? 'DELETE' : 'POST';
+    if (await adminRequest(`/api/admin/dictionary/${entry.word}/flag`, method, body)) {
+        loadDictionary();
+    }
+}
+
+// Importe un fichier texte (un mot par ligne) ou CSV (mot, définition)
+async function importDictionary(file) {
+    const response = await adminRequest('/api/admin/dictionary/import', 'POST', file);
+    if (!response) {
+        return;
+    }
+    const result = await response.json();
+    let message = `${result.added} mots ajoutés, ${result.updated} définitions mises à jour.`;
+    if (result.skipped.length > 0) {
+        message += `\n${result.skipped.length} lignes ignorées :\n`
+            + result.skipped.slice(0, 20).map(skip => `ligne ${skip.line} (${skip.word}) : ${skip.error}`).join('\n');
+    }
+    alert(message);
+    loadDictionary();
+}
 
-                if (confirm('Êtes-vous sûr de vouloir supprimer cet utilisateur ?')) {
-                    try {
-                        const response = await fetch(`/api/admin/users/delete/${userId}`, {
-                            method: 'DELETE',
-                            headers: {
-                                'Authorization': `Bearer ${token}`
-                            }
-                        });
-                        
-                        if (response.ok) {
-                            // Recharger la liste des utilisateurs
-                            loadUsers();
-                        } else {
-                            const errorText = await response.text();
-                            if (response.status === 403) {
-                                alert('Vous n\'avez pas les droits pour supprimer cet utilisateur');
-                            } else if (response.status === 404) {
-                                alert('Utilisateur non trouvé ou ne peut pas être supprimé');
-                                loadUsers(); // Recharger la liste pour mettre à jour l'affichage
-                            } else {
-                                alert(`Erreur lors de la suppression de l'utilisateur: ${errorText}`);
-                            }
-                        }
-                    } catch (error) {
-                        alert(`Erreur lors de la suppression de l'utilisateur: ${error.message}`);
-                    }
+// Charge la file de modération des signalements, mots les plus signalés en premier
+async function loadReports() {
+    const params = new URLSearchParams({
+        page: reportsPage,
+        limit: REPORTS_PER_PAGE,
+        kind: document.getElementById('reports-kind').value
+    });
+
+    const response = await adminRequest(`/api/admin/word-reports?${params}`);
+    if (!response) {
+        return;
+    }
+    const data = await response.json();
+
+    const table = document.createElement('table');
+    table.className = 'users-table';
+    table.innerHTML = `
+        <thead>
+            <tr>
+                <th>Mot</th>
+                <th>Demande</th>
+                <th>Signalements</th>
+                <th>Derniers commentaires</th>
+                <th>Actions</th>
+            </tr>
+        </thead>
+    `;
+    const tbody = document.createElement('tbody');
+    data.reports.forEach(group => {
+        const tr = document.createElement('tr');
+        const comments = group.latest
+            .map(report => report.comment ? `${report.reporter} : ${report.comment}` : report.reporter)
+            .join('\n');
+        [
+            group.word,
+            group.kind === 'missing' ? 'Ajouter au dictionnaire' : 'Retirer du dictionnaire',
+            group.reports,
+            comments
+        ].forEach(text => {
+            const td = document.createElement('td');
+            td.textContent = text;
+            td.style.whiteSpace = 'pre-line';
+            tr.appendChild(td);
+        });
+
+        const actions = document.createElement('td');
+        [['Approuver', 'btn-primary', 'approve'], ['Rejeter', 'btn-danger', 'reject']].forEach(([label, className, action]) => {
+            const button = document.createElement('button');
+            button.className = className;
+            button.textContent = label;
+            button.addEventListener('click', async () => {
+                if (await adminRequest(`/api/admin/word-reports/${group.kind}/${group.word}/${action}`, 'POST')) {
+                    loadReports();
+                    loadDictionary();
                 }
             });
+            actions.appendChild(button);
+        });
+        tr.appendChild(actions);
+        tbody.appendChild(tr);
+    });
+    table.appendChild(tbody);
+
+    const list = document.querySelector('.reports-list');
+    list.innerHTML = '';
+    list.appendChild(table);
+
+    const pages = Math.max(1, Math.ceil(data.total / data.limit));
+    document.getElementById('reports-page').textContent = `Page ${data.page} / ${pages} (${data.total} mots signalés)`;
+    document.getElementById('reports-prev').disabled = data.page <= 1;
+    document.getElementById('reports-next').disabled = data.page >= pages;
+}
+
+// Durée lisible à partir d'un nombre de secondes
+function formatDuration(seconds) {
+    const minutes = Math.floor(seconds / 60);
+    return `${minutes}:${(seconds % 60).toString().padStart(2, '0')}`;
+}
+
+// Construit un tableau à partir de lignes de cellules texte et de boutons
+// [libellé, classe, action] placés en dernière colonne
+function liveTable(headers, rows) {
+    const table = document.createElement('table');
+    table.className = 'users-table';
+    const thead = document.createElement('thead');
+    const headRow = document.createElement('tr');
+    headers.forEach(text => {
+        const th = document.createElement('th');
+        th.textContent = text;
+        headRow.appendChild(th);
+    });
+    thead.appendChild(headRow);
+    table.appendChild(thead);
+
+    const tbody = document.createElement('tbody');
+    rows.forEach(({ cells, actions }) => {
+        const tr = document.createElement('tr');
+        cells.forEach(text => {
+            const td = document.createElement('td');
+            td.textContent = text;
+            tr.appendChild(td);
+        });
+        const td = document.createElement('td');
+        actions.forEach(([label, className, handler]) => {
+            const button = document.createElement('button');
+            button.className = className;
+            button.textContent = label;
+            button.addEventListener('click', handler);
+            td.appendChild(button);
         });
-    } catch (error) {
-        console.error('Erreur:', error);
-        if (error.message.includes('401')) {
-            // Non autorisé, rediriger vers la page de connexion
-            localStorage.removeItem('token');
-            window.location.href = '/html/login.html';
+        tr.appendChild(td);
+        tbody.appendChild(tr);
+    });
+    table.appendChild(tbody);
+    return table;
+}
+
+// Affiche l'état du serveur reçu de /ws/admin/live
+function renderLive(data, canManage) {
+    const players = data.connections.filter(conn => conn.role === 'player').length;
+    document.getElementById('live-summary').textContent =
+        `${data.connections.length} connexions (${players} joueurs), ${data.queue_length} en file d'attente, ${data.games.length} parties en cours`;
+
+    const games = liveTable(['Partie', 'Joueurs', 'Spectateurs', 'Durée', 'Actions'], data.games.map(game => ({
+        cells: [
+            game.game_id.slice(0, 8) + (game.settings.private ? ' (privée)' : ''),
+            game.players.map(player => `${player.username} (${player.attempts} essais)`).join(', '),
+            game.spectators,
+            formatDuration(game.elapsed_seconds)
+        ],
+        actions: canManage ? [['Arrêter', 'btn-danger', () => {
+            const reason = prompt('Motif affiché aux joueurs (facultatif) :');
+            if (reason !== null) {
+                adminRequest(`/api/admin/live/games/${game.game_id}/end`, 'POST', { reason });
+            }
+        }]] : []
+    })));
+    const gamesList = document.querySelector('.live-games');
+    gamesList.innerHTML = '';
+    gamesList.appendChild(games);
+
+    const queued = new Set(data.queue.map(entry => entry.player_id));
+    const connections = liveTable(['Joueur', 'Rôle', 'Adresse', 'Connecté depuis', 'Partie', 'Actions'], data.connections.map(conn => ({
+        cells: [
+            conn.username,
+            conn.role === 'player' ? 'Joueur' : 'Spectateur',
+            conn.remote_addr,
+            new Date(conn.connected_at).toLocaleTimeString('fr-FR'),
+            conn.game_id ? conn.game_id.slice(0, 8) : (queued.has(conn.player_id) ? 'En attente' : '')
+        ],
+        actions: canManage ? [['Déconnecter', 'btn-danger', () => {
+            if (confirm(`Déconnecter ${conn.username} ?`)) {
+                adminRequest(`/api/admin/live/players/${conn.player_id}/disconnect`, 'POST', {});
+            }
+        }]] : []
+    })));
+    const connectionsList = document.querySelector('.live-connections');
+    connectionsList.innerHTML = '';
+    connectionsList.appendChild(connections);
+}
+
+// Suit l'état du serveur en direct, et se reconnecte en cas de coupure
+async function watchLive(canManage) {
+    const token = await getValidToken();
+    if (!token) {
+        return;
+    }
+    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const socket = new WebSocket(`${protocol}//${window.location.host}/ws/admin/live?token=${encodeURIComponent(token)}`);
+    socket.onmessage = (event) => {
+        const data = JSON.parse(event.data);
+        if (data.type === 'live') {
+            renderLive(data, canManage);
         }
+    };
+    socket.onclose = () => {
+        document.getElementById('live-summary').textContent = 'Connexion perdue, nouvelle tentative...';
+        setTimeout(() => watchLive(canManage), 5000);
+    };
+}
+
+// Paramètres de filtrage du journal d'audit
+function auditFilters() {
+    const params = new URLSearchParams();
+    ['action', 'actor', 'target', 'ip'].forEach(name => {
+        const value = document.getElementById(`audit-${name}`).value.trim();
+        if (value) {
+            params.set(name, value);
+        }
+    });
+    return params;
+}
+
+// Charge une page du journal d'audit
+async function loadAudit() {
+    const params = auditFilters();
+    params.set('page', auditPage);
+    params.set('limit', AUDIT_PER_PAGE);
+
+    const response = await adminRequest(`/api/admin/audit?${params}`);
+    if (!response) {
+        return;
     }
+    const data = await response.json();
+
+    const table = document.createElement('table');
+    table.className = 'users-table';
+    table.innerHTML = `
+        <thead>
+            <tr>
+                <th>Date</th>
+                <th>Action</th>
+                <th>Auteur</th>
+                <th>Cible</th>
+                <th>IP</th>
+                <th>Détails</th>
+            </tr>
+        </thead>
+    `;
+    const tbody = document.createElement('tbody');
+    data.entries.forEach(entry => {
+        const tr = document.createElement('tr');
+        [
+            new Date(entry.created_at).toLocaleString('fr-FR'),
+            entry.action,
+            entry.actor || 'système',
+            entry.target || '',
+            entry.ip || '',
+            entry.details ? JSON.stringify(entry.details) : ''
+        ].forEach(text => {
+            const td = document.createElement('td');
+            td.textContent = text;
+            tr.appendChild(td);
+        });
+        tbody.appendChild(tr);
+    });
+    table.appendChild(tbody);
+
+    const auditList = document.querySelector('.audit-list');
+    auditList.innerHTML = '';
+    auditList.appendChild(table);
+
+    const pages = Math.max(1, Math.ceil(data.total / data.limit));
+    document.getElementById('audit-page').textContent = `Page ${data.page} / ${pages} (${data.total} entrées)`;
+    document.getElementById('audit-prev').disabled = data.page <= 1;
+    document.getElementById('audit-next').disabled = data.page >= pages;
+}
+
+// Télécharge le journal filtré au format CSV
+async function exportAudit() {
+    const params = auditFilters();
+    params.set('format', 'csv');
+    const response = await adminRequest(`/api/admin/audit?${params}`);
+    if (!response) {
+        return;
+    }
+    const link = document.createElement('a');
+    link.href = URL.createObjectURL(await response.blob());
+    link.download = 'audit.csv';
+    link.click();
+    URL.revokeObjectURL(link.href);
 }
 
 // Initialisation
@@ -127,7 +608,99 @@ document.addEventListener('DOMContentLoaded', () => {
     if (!checkAuth()) {
         return; // checkAuth() redirigera déjà vers login.html si nécessaire
     }
-    
-    // Charger la liste des utilisateurs
-    loadUsers();
-}); 
\ No newline at end of file
+
+    document.getElementById('users-filter').addEventListener('submit', (e) => {
+        e.preventDefault();
+        currentPage = 1;
+        loadUsers();
+    });
+    document.getElementById('users-prev').addEventListener('click', () => {
+        currentPage--;
+        loadUsers();
+    });
+    document.getElementById('users-next').addEventListener('click', () => {
+        currentPage++;
+        loadUsers();
+    });
+
+    document.getElementById('dictionary-filter').addEventListener('submit', (e) => {
+        e.preventDefault();
+        dictionaryPage = 1;
+        loadDictionary();
+    });
+    document.getElementById('dictionary-add').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const word = document.getElementById('dictionary-word');
+        const definition = document.getElementById('dictionary-definition');
+        if (await adminRequest('/api/admin/dictionary', 'POST', { word: word.value, definition: definition.value })) {
+            word.value = '';
+            definition.value = '';
+            loadDictionary();
+        }
+    });
+    document.getElementById('dictionary-import').addEventListener('change', (e) => {
+        if (e.target.files.length > 0) {
+            importDictionary(e.target.files[0]);
+            e.target.value = '';
+        }
+    });
+    document.getElementById('dictionary-prev').addEventListener('click', () => {
+        dictionaryPage--;
+        loadDictionary();
+    });
+    document.getElementById('dictionary-next').addEventListener('click', () => {
+        dictionaryPage++;
+        loadDictionary();
+    });
+
+    document.getElementById('reports-filter').addEventListener('submit', (e) => {
+        e.preventDefault();
+        reportsPage = 1;
+        loadReports();
+    });
+    document.getElementById('reports-prev').addEventListener('click', () => {
+        reportsPage--;
+        loadReports();
+    });
+    document.getElementById('reports-next').addEventListener('click', () => {
+        reportsPage++;
+        loadReports();
+    });
+
+    document.getElementById('audit-filter').addEventListener('submit', (e) => {
+        e.preventDefault();
+        auditPage = 1;
+        loadAudit();
+    });
+    document.getElementById('audit-export').addEventListener('click', exportAudit);
+    document.getElementById('audit-prev').addEventListener('click', () => {
+        auditPage--;
+        loadAudit();
+    });
+    document.getElementById('audit-next').addEventListener('click', () => {
+        auditPage++;
+        loadAudit();
+    });
+
+    // N'afficher que les sections autorisées par les permissions de l'utilisateur
+    loadPermissions().then(permissions => {
+        if (permissions.includes('matches:read_private')) {
+            document.getElementById('live-section').style.display = '';
+            watchLive(permissions.includes('users:manage'));
+        }
+        if (permissions.includes('users:read')) {
+            document.getElementById('users-section').style.display = '';
+            loadUsers();
+        }
+        if (permissions.includes('dictionary:write')) {
+            document.getElementById('dictionary-section').style.display = '';
+            document.getElementById('reports-section').style.display = '';
+            loadDictionary();
+            loadReports();
+        }
+        if (permissions.includes('audit:read')) {
+            document.getElementById('audit-section').style.display = '';
+            loadAudit();
+        }
+    });
+});
@@ -1,4 +1,4 @@
-import { checkAuth } from './auth.js';
+import { checkAuth, getValidToken } from './auth.js';
 
 let socket;
 let gameId = null;
@@ -13,18 +13,46 @@ const gameStatus = document.getElementById("game-status");
 const attemptsDisplay = document.getElementById("attempts");
 const waitingScreen = document.getElementById("waiting-screen");
 const timer = document.getElementById("timer");
+const opponentProgress = document.getElementById("opponent-progress");
+const opponentBoard = document.getElementById("opponent-board");
+const opponentAttempts = document.getElementById("opponent-attempts");
+const chat = document.getElementById("chat");
+const chatMessages = document.getElementById("chat-messages");
+const chatForm = document.getElementById("chat-form");
+const chatInput = document.getElementById("chat-input");
 
 let startTime = null;
 let timerInterval = null;
+// Les invités ne peuvent pas signaler de mot
+let loggedIn = false;
+// Message affiché à la fermeture quand le serveur nous déconnecte (suspension...)
+let closeMessage = null;
 
 document.addEventListener('DOMContentLoaded', () => {
     initializeWebSocket();
     setupKeyboard();
+    setupChat();
 });
 
-function initializeWebSocket() {
+async function initializeWebSocket() {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws`;
+    // multi.html?opponent_progress=hidden permet de jouer en mode caché,
+    // multi.html?private=1 de refuser les spectateurs
+    const params = new URLSearchParams(window.location.search);
+    const query = new URLSearchParams();
+    if (params.get('opponent_progress') === 'hidden') {
+        query.set('opponent_progress', 'hidden');
+    }
+    if (params.get('private') === '1') {
+        query.set('private', '1');
+    }
+    // Le token permet d'afficher notre pseudo aux spectateurs
+    const token = await getValidToken();
+    if (token) {
+        query.set('token', token);
+    }
+    loggedIn = Boolean(token);
+    const wsUrl = `${protocol}//${window.location.host}/ws?${query.toString()}`;
     socket = new WebSocket(wsUrl);
 
     socket.onopen = () => {
@@ -37,9 +65,10 @@ function initializeWebSocket() {
         handleServerMessage(data);
     };
 
+    closeMessage = null;
     socket.onclose = () => {
         console.log('Déconnecté du serveur');
-        gameStatus.textContent = 'Connexion perdue. Rafraîchissez la page pour rejouer.';
+        gameStatus.textContent = closeMessage || 'Connexion perdue. Rafraîchissez la page pour rejouer.';
         gameStatus.classList.add('error');
     };
 }
@@ -52,19 +81,58 @@ function handleServerMessage(data) {
         case 'guess_result':
             handleGuessResult(data);
             break;
+        case 'opponent_progress':
+            handleOpponentProgress(data);
+            break;
         case 'game_over':
             handleGameOver(data);
             break;
+        case 'chat':
+            addChatMessage(data);
+            break;
+        case 'rematch_request':
+            showRematchPrompt(data);
+            break;
+        case 'rematch_pending':
+            gameStatus.textContent = 'Demande de revanche envoyée, en attente de votre adversaire...';
+            break;
+        case 'rematch_declined':
+        case 'rematch_expired':
+        case 'rematch_unavailable':
+            handleRematchEnd(data);
+            break;
+        case 'word_reported':
+            removeReportButton();
+            gameStatus.textContent = `Merci, votre signalement de ${data.word} a été transmis aux modérateurs.`;
+            gameStatus.className = 'info';
+            break;
         case 'error':
             showError(data.message);
+            if (data.code === 'unknown_word') {
+                showReportButton(data.word, 'missing', '🚩 Signaler un mot manquant');
+            } else if (data.code === 'account_suspended') {
+                closeMessage = data.suspended_until
+                    ? `${data.message} (jusqu'au ${new Date(data.suspended_until).toLocaleString('fr-FR')})`
+                    : `${data.message} (définitivement)`;
+            } else if (data.code === 'account_deleted' || data.code === 'disconnected') {
+                closeMessage = data.message;
+            } else if (data.code === 'must_change_password') {
+                window.location.href = '/html/change-password.html';
+            }
             break;
     }
 }
 
 function startGame(data) {
+    // Une revanche démarre sur la même connexion : repartir d'un plateau vierge
+    clearBoard();
     gameId = data.game_id;
     waitingScreen.classList.add('hidden');
     initializeBoard(data.word.length);
+    if (data.settings && data.settings.opponent_progress) {
+        initializeOpponentBoard(data.word.length);
+    }
+    chat.classList.remove('hidden');
     startTimer();
     gameStatus.textContent = 'Partie commencée !';
     gameStatus.classList.add('info');
@@ -86,6 +154,23 @@ function initializeBoard(wordLength) {
     }
 }
 
+function initializeOpponentBoard(wordLength) {
+    opponentBoard.innerHTML = '';
+    opponentAttempts.textContent = `Essai 0/${maxAttempts}`;
+
+    for (let i = 0; i < maxAttempts; i++) {
+        const row = document.createElement("div");
+        row.className = "word-row";
+        for (let j = 0; j < wordLength; j++) {
+            const cell = document.createElement("div");
+            cell.className = "letter-box small";
+            row.appendChild(cell);
+        }
+        opponentBoard.appendChild(row);
+    }
+    opponentProgress.classList.remove('hidden');
+}
+
 function updateCurrentRow() {
     const currentRow = guessesContainer.children[attempts];
     if (!currentRow) return;
@@ -123,7 +208,37 @@ function setupKeyboard() {
     });
 }
 
+function setupChat() {
+    chatForm.addEventListener('submit', (e) => {
+        e.preventDefault();
+        const message = chatInput.value.trim();
+        if (!message || !gameId) return;
+        socket.send(JSON.stringify({ type: 'chat', game_id: gameId, message }));
+        chatInput.value = '';
+    });
+
+    document.querySelectorAll('.emote').forEach(button => {
+        button.addEventListener('click', () => {
+            if (!gameId) return;
+            socket.send(JSON.stringify({ type: 'chat', game_id: gameId, emote: button.dataset.emote }));
+        });
+    });
+}
+
+function addChatMessage(data) {
+    const line = document.createElement('div');
+    line.className = 'chat-message';
+    const author = document.createElement('strong');
+    author.textContent = `${data.username} : `;
+    line.appendChild(author);
+    line.appendChild(document.createTextNode(data.message));
+    chatMessages.appendChild(line);
+    chatMessages.scrollTop = chatMessages.scrollHeight;
+}
+
 document.addEventListener('keydown', (e) => {
+    // Ne pas intercepter la saisie dans le chat
+    if (e.target === chatInput) return;
     if (!gameId || attempts >= maxAttempts) return;
 
     if (e.key === 'Enter') {
@@ -153,6 +268,7 @@ function submitGuess() {
 }
 
 function handleGuessResult(data) {
+    removeReportButton();
     const currentRow = guessesContainer.children[attempts];
     
     for (let i = 0; i < data.guess.length; i++) {
@@ -197,9 +313,24 @@ function handleGuessResult(data) {
     updateAttempts();
 }
 
+function handleOpponentProgress(data) {
+    const row = opponentBoard.children[data.attempts - 1];
+    if (!row) return;
+
+    // Seules les couleurs sont transmises, jamais les lettres de l'adversaire
+    for (let i = 0; i < data.result.length; i++) {
+        row.children[i].classList.add(data.result[i]);
+    }
+    opponentAttempts.textContent = `Essai ${data.attempts}/${maxAttempts}`;
+}
+
 function handleGameOver(data) {
     stopTimer();
-    if (data.winner === 'you') {
+    if (data.reason) {
+        // Partie arrêtée par un administrateur
+        gameStatus.textContent = `${data.reason} Le mot était : ${data.word}`;
+        gameStatus.classList.add('failure');
+    } else if (data.winner === 'you') {
         gameStatus.textContent = 'Félicitations ! Vous avez gagné !';
         gameStatus.classList.add('success');
     } else {
@@ -207,6 +338,37 @@ function handleGameOver(data) {
         gameStatus.classList.add('failure');
     }
     showReplayButton();
+    if (data.word) {
+        showReportButton(data.word, 'remove', '🚩 Signaler ce mot');
+    }
+}
+
+// Propose de signaler un mot aux modérateurs : refusé à tort (missing) ou
+// à retirer du dictionnaire (remove)
+function showReportButton(word, kind, label) {
+    removeReportButton();
+    if (!loggedIn) {
+        return;
+    }
+    const reportButton = document.createElement('button');
+    reportButton.id = 'report-button';
+    reportButton.className = 'replay-button';
+    reportButton.textContent = label;
+    reportButton.onclick = () => {
+        const comment = prompt(`Pourquoi signaler ${word} ? (facultatif)`);
+        if (comment === null) {
+            return;
+        }
+        socket.send(JSON.stringify({ type: 'report_word', word, kind, comment }));
+    };
+    gameStatus.parentNode.insertBefore(reportButton, gameStatus.nextSibling);
+}
+
+function removeReportButton() {
+    const reportButton = document.getElementById('report-button');
+    if (reportButton) {
+        reportButton.remove();
+    }
 }
 
 function showReplayButton() {
@@ -218,10 +380,54 @@ function showReplayButton() {
         replayButton.textContent = '🔄 Nouvelle partie';
         replayButton.onclick = resetGame;
         gameStatus.parentNode.insertBefore(replayButton, gameStatus.nextSibling);
+
+        const rematchButton = document.createElement('button');
+        rematchButton.id = 'rematch-button';
+        rematchButton.className = 'replay-button';
+        rematchButton.textContent = '⚔️ Revanche';
+        rematchButton.onclick = requestRematch;
+        gameStatus.parentNode.insertBefore(rematchButton, replayButton);
     }
 }
 
-function resetGame() {
+function requestRematch() {
+    const rematchButton = document.getElementById('rematch-button');
+    if (rematchButton) {
+        rematchButton.disabled = true;
+    }
+    socket.send(JSON.stringify({ type: 'rematch_request' }));
+}
+
+function showRematchPrompt(data) {
+    gameStatus.textContent = `${data.from} vous propose une revanche !`;
+
+    const rematchButton = document.getElementById('rematch-button');
+    if (rematchButton) {
+        rematchButton.disabled = false;
+        rematchButton.textContent = '⚔️ Accepter la revanche';
+        rematchButton.onclick = () => socket.send(JSON.stringify({ type: 'rematch_accept' }));
+    }
+}
+
+function handleRematchEnd(data) {
+    const rematchButton = document.getElementById('rematch-button');
+    if (rematchButton) {
+        rematchButton.remove();
+    }
+
+    // Sans message, la demande qui nous était adressée a simplement expiré
+    if (!data.message) {
+        return;
+    }
+
+    // Le serveur nous a remis dans la file d'attente
+    clearBoard();
+    gameStatus.textContent = data.message;
+    gameStatus.classList.add('info');
+    waitingScreen.classList.remove('hidden');
+}
+
+function clearBoard() {
     // Réinitialiser les variables
     attempts = 0;
     currentGuess = '';
@@ -232,6 +438,10 @@ function resetGame() {
     guessesContainer.innerHTML = '';
     gameStatus.textContent = '';
     gameStatus.className = '';
+    opponentBoard.innerHTML = '';
+    opponentProgress.classList.add('hidden');
+    chatMessages.innerHTML = '';
+    chat.classList.add('hidden');
     
     // Réinitialiser le timer
     if (timerInterval) {
@@ -248,12 +458,22 @@ function resetGame() {
         key.style.color = '';
     });
     
-    // Supprimer le bouton rejouer
+    // Supprimer les boutons rejouer, revanche et signalement
+    removeReportButton();
     const replayButton = document.getElementById('replay-button');
     if (replayButton) {
         replayButton.remove();
     }
-    
+    const rematchButton = document.getElementById('rematch-button');
+    if (rematchButton) {
+        rematchButton.remove();
+    }
+    updateAttempts();
+}
+
+function resetGame() {
+    clearBoard();
+
     // Réinitialiser la connexion WebSocket et commencer une nouvelle partie
     if (socket && socket.readyState === WebSocket.OPEN) {
         socket.close();
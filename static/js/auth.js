@@ -2,7 +2,7 @@
 export function checkAuth() {
     const token = localStorage.getItem('token');
     const currentPage = window.location.pathname.split('/').pop();
-    const protectedPages = ['solo.html', 'multi.html', 'word-of-day.html', 'profile.html'];
+    const protectedPages = ['solo.html', 'multi.html', 'word-of-day.html', 'profile.html', 'change-password.html'];
     
     console.log('Checking auth:', { token: !!token, currentPage, isProtected: protectedPages.includes(currentPage) });
     
@@ -43,11 +43,10 @@ async function handleRegister(event) {
         const data = await response.json();
 
         if (response.ok) {
-            // Stockage du token JWT
-            localStorage.setItem('token', data.token);
+            storeTokens(data);
             window.location.href = '/html/home.html';
         } else {
-            showError(data.error || "Erreur lors de l'inscription");
+            showError(formatErrors(data) || "Erreur lors de l'inscription");
         }
     } catch (error) {
         showError("Erreur de connexion au serveur");
@@ -75,19 +74,294 @@ async function handleLogin(event) {
 
         const data = await response.json();
 
+        if (response.ok && data.mfa_required) {
+            // Double authentification : le code est demandé dans un second temps
+            showMFAForm(data.mfa_token);
+        } else if (response.ok) {
+            completeLogin(data);
+        } else {
+            showError(formatErrors(data) || "Identifiants invalides");
+        }
+    } catch (error) {
+        showError("Erreur de connexion au serveur");
+    }
+}
+
+// Affiche la saisie du code de double authentification
+function showMFAForm(mfaToken) {
+    document.getElementById('login-form').style.display = 'none';
+    const mfaForm = document.getElementById('mfa-form');
+    mfaForm.style.display = '';
+    document.getElementById('mfa-code').focus();
+
+    mfaForm.onsubmit = async (event) => {
+        event.preventDefault();
+        const value = document.getElementById('mfa-code').value.trim();
+        // Les codes TOTP ne contiennent que des chiffres, les codes de secours des lettres
+        const body = /^[0-9 ]+$/.test(value)
+            ? { mfa_token: mfaToken, code: value }
+            : { mfa_token: mfaToken, recovery_code: value };
+
+        try {
+            const response = await fetch('/api/login/mfa', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify(body)
+            });
+
+            const data = await response.json();
+            if (response.ok) {
+                completeLogin(data);
+            } else {
+                showError(formatErrors(data) || "Code de vérification invalide");
+            }
+        } catch (error) {
+            showError("Erreur de connexion au serveur");
+        }
+    };
+}
+
+// Affiche les boutons de connexion des fournisseurs d'identité configurés
+async function loadOIDCProviders() {
+    const container = document.getElementById('oidc-providers');
+    try {
+        const response = await fetch('/api/oidc/providers');
+        const providers = await response.json();
+        providers.forEach(provider => {
+            const link = document.createElement('a');
+            link.href = provider.login_url;
+            link.className = 'oidc-button';
+            link.textContent = `Se connecter avec ${provider.display_name}`;
+            container.appendChild(link);
+        });
+    } catch (error) {
+        console.error('Erreur lors du chargement des fournisseurs:', error);
+    }
+}
+
+// Termine une connexion via un fournisseur d'identité : les tokens (ou le
+// token de double authentification) arrivent dans le fragment de l'URL
+function handleOIDCRedirect() {
+    const error = new URLSearchParams(window.location.search).get('error');
+    if (error) {
+        showError(error);
+    }
+
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    history.replaceState(null, '', window.location.pathname);
+    if (params.get('mfa_token')) {
+        showMFAForm(params.get('mfa_token'));
+    } else if (params.get('token')) {
+        completeLogin({
+            token: params.get('token'),
+            refresh_token: params.get('refresh_token'),
+            must_change_password: params.get('must_change_password') === 'true'
+        });
+    }
+}
+
+// Enregistre les tokens d'une connexion réussie et redirige
+function completeLogin(data) {
+    storeTokens(data);
+    // Un mot de passe provisoire doit être changé avant de continuer
+    if (data.must_change_password) {
+        window.location.href = '/html/change-password.html';
+        return;
+    }
+    // Redirection vers la page d'accueil
+    window.location.href = '/html/home.html';
+}
+
+// Fonction pour gérer le changement de mot de passe
+async function handleChangePassword(event) {
+    event.preventDefault();
+
+    const currentPassword = document.getElementById('current-password').value;
+    const newPassword = document.getElementById('new-password').value;
+    const confirmPassword = document.getElementById('confirm-password').value;
+
+    if (newPassword !== confirmPassword) {
+        showError("Les mots de passe ne correspondent pas");
+        return;
+    }
+
+    const token = await getValidToken();
+    if (!token) {
+        window.location.href = '/html/login.html';
+        return;
+    }
+
+    try {
+        const response = await fetch('/api/password/change', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'Authorization': `Bearer ${token}`
+            },
+            body: JSON.stringify({
+                current_password: currentPassword,
+                new_password: newPassword
+            })
+        });
+
         if (response.ok) {
-            // Stockage du token JWT
-            localStorage.setItem('token', data.token);
-            // Redirection vers la page d'accueil
             window.location.href = '/html/home.html';
         } else {
-            showError(data.error || "Identifiants invalides");
+            const data = await response.json().catch(() => ({}));
+            showError(formatErrors(data) || "Erreur lors du changement de mot de passe");
+        }
+    } catch (error) {
+        showError("Erreur de connexion au serveur");
+    }
+}
+
+// Fonction pour demander un lien de réinitialisation du mot de passe
+async function handleForgotPassword(event) {
+    event.preventDefault();
+
+    const email = document.getElementById('email').value;
+
+    try {
+        const response = await fetch('/api/password/forgot', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({ email })
+        });
+
+        const data = await response.json().catch(() => ({}));
+        if (response.ok) {
+            showMessage(data.message);
+        } else {
+            showError(data.error || "Erreur lors de la demande");
+        }
+    } catch (error) {
+        showError("Erreur de connexion au serveur");
+    }
+}
+
+// Fonction pour choisir un nouveau mot de passe depuis le lien reçu par email
+async function handleResetPassword(event) {
+    event.preventDefault();
+
+    const newPassword = document.getElementById('new-password').value;
+    const confirmPassword = document.getElementById('confirm-password').value;
+
+    if (newPassword !== confirmPassword) {
+        showError("Les mots de passe ne correspondent pas");
+        return;
+    }
+
+    try {
+        const response = await fetch('/api/password/reset', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({
+                token: new URLSearchParams(window.location.search).get('token'),
+                new_password: newPassword
+            })
+        });
+
+        if (response.ok) {
+            window.location.href = '/html/login.html';
+        } else {
+            const data = await response.json().catch(() => ({}));
+            showError(formatErrors(data) || "Erreur lors de la réinitialisation");
         }
     } catch (error) {
         showError("Erreur de connexion au serveur");
     }
 }
 
+// Fonction pour confirmer l'adresse email depuis le lien reçu par email
+async function verifyEmail(status) {
+    try {
+        const response = await fetch('/api/verify-email', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({
+                token: new URLSearchParams(window.location.search).get('token')
+            })
+        });
+
+        if (response.ok) {
+            status.textContent = "Votre adresse email est confirmée.";
+        } else {
+            const data = await response.json().catch(() => ({}));
+            status.textContent = data.error || "Lien invalide ou expiré";
+        }
+    } catch (error) {
+        status.textContent = "Erreur de connexion au serveur";
+    }
+}
+
+// Stockage du token d'accès JWT et du refresh token
+function storeTokens(data) {
+    localStorage.setItem('token', data.token);
+    localStorage.setItem('refresh_token', data.refresh_token);
+}
+
+// Renvoie un token d'accès valide, renouvelé via le refresh token s'il expire
+// dans moins de 30 secondes. Renvoie null si la session n'est plus valide.
+export async function getValidToken() {
+    const token = localStorage.getItem('token');
+    if (!token) {
+        return null;
+    }
+
+    const payload = JSON.parse(atob(token.split('.')[1]));
+    if (payload.exp * 1000 - Date.now() > 30000) {
+        return token;
+    }
+
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) {
+        return null;
+    }
+
+    try {
+        const response = await fetch('/api/refresh', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({ refresh_token: refreshToken })
+        });
+        if (!response.ok) {
+            localStorage.removeItem('token');
+            localStorage.removeItem('refresh_token');
+            return null;
+        }
+        const data = await response.json();
+        storeTokens(data);
+        return data.token;
+    } catch (error) {
+        return null;
+    }
+}
+
+// Regroupe les erreurs par champ renvoyées par l'API en un seul message
+function formatErrors(data) {
+    if (data.fields) {
+        return Object.values(data.fields).join('. ');
+    }
+    if (data.reason) {
+        // Compte suspendu : motif et date de fin éventuelle
+        const until = data.suspended_until
+            ? ` jusqu'au ${new Date(data.suspended_until).toLocaleString('fr-FR')}`
+            : '';
+        return `${data.error}${until} : ${data.reason}`;
+    }
+    return data.error;
+}
+
 // Fonction pour afficher les messages d'erreur
 function showError(message) {
     let errorDiv = document.querySelector('.error-message');
@@ -101,6 +375,15 @@ function showError(message) {
     errorDiv.classList.add('visible');
 }
 
+// Fonction pour afficher un message d'information à la place du formulaire
+function showMessage(message) {
+    const form = document.querySelector('form');
+    const messageP = document.createElement('p');
+    messageP.className = 'auth-redirect';
+    messageP.textContent = message;
+    form.replaceWith(messageP);
+}
+
 // Fonction pour mettre à jour l'affichage du profil
 function updateProfileDisplay() {
     const token = localStorage.getItem('token');
@@ -148,9 +431,23 @@ function updateProfileDisplay() {
     }
 }
 
-// Fonction pour se déconnecter
-export function logout() {
+// Fonction pour se déconnecter : la session est révoquée côté serveur
+export async function logout() {
+    const token = await getValidToken();
+    if (token) {
+        try {
+            await fetch('/api/logout', {
+                method: 'POST',
+                headers: {
+                    'Authorization': `Bearer ${token}`
+                }
+            });
+        } catch (error) {
+            console.error('Erreur lors de la déconnexion:', error);
+        }
+    }
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     window.location.href = '/html/home.html';
 }
 
@@ -160,6 +457,10 @@ document.addEventListener('DOMContentLoaded', () => {
     
     const registerForm = document.getElementById('register-form');
     const loginForm = document.getElementById('login-form');
+    const changePasswordForm = document.getElementById('change-password-form');
+    const forgotPasswordForm = document.getElementById('forgot-password-form');
+    const resetPasswordForm = document.getElementById('reset-password-form');
+    const verifyEmailStatus = document.getElementById('verify-email-status');
 
     if (registerForm) {
         registerForm.addEventListener('submit', handleRegister);
@@ -167,6 +468,24 @@ document.addEventListener('DOMContentLoaded', () => {
 
     if (loginForm) {
         loginForm.addEventListener('submit', handleLogin);
+        loadOIDCProviders();
+        handleOIDCRedirect();
+    }
+
+    if (changePasswordForm) {
+        changePasswordForm.addEventListener('submit', handleChangePassword);
+    }
+
+    if (forgotPasswordForm) {
+        forgotPasswordForm.addEventListener('submit', handleForgotPassword);
+    }
+
+    if (resetPasswordForm) {
+        resetPasswordForm.addEventListener('submit', handleResetPassword);
+    }
+
+    if (verifyEmailStatus) {
+        verifyEmail(verifyEmailStatus);
     }
 
     // Mettre à jour l'affichage du profil
@@ -1,8 +1,8 @@
-import { checkAuth, logout } from './auth.js';
+import { checkAuth, logout, getValidToken } from './auth.js';
 
 // Récupérer les informations du profil depuis l'API
 async function updateProfileInfo() {
-    const token = localStorage.getItem('token');
+    const token = await getValidToken();
     if (!token) {
         window.location.href = '/html/login.html';
         return;
@@ -25,6 +25,15 @@ async function updateProfileInfo() {
         // Mettre à jour les informations du profil
         document.getElementById('profile-username').textContent = profileData.username;
         document.getElementById('profile-email').textContent = profileData.email;
+        if (profileData.avatar_url) {
+            const avatar = document.getElementById('profile-avatar');
+            avatar.src = profileData.avatar_url;
+            avatar.style.display = 'block';
+        }
+        showMFAStatus(profileData.mfa_enabled);
+        if (!profileData.email_verified) {
+            showEmailNotVerified(token);
+        }
         
         // Formater la date de création
         const createdAt = new Date(profileData.created_at);
@@ -37,9 +46,10 @@ async function updateProfileInfo() {
             profileLink.innerHTML = `👤 ${profileData.username}`;
         }
 
-        // Gérer l'affichage du bouton admin
+        // Gérer l'affichage du bouton admin : toute permission donne accès
+        // à la page d'administration, qui n'affiche que ce qui est autorisé
         const adminButton = document.getElementById('admin-button');
-        if (adminButton && profileData.is_admin) {
+        if (adminButton && profileData.permissions?.length) {
             adminButton.style.display = 'block';
             adminButton.addEventListener('click', () => {
                 window.location.href = '/html/admin.html';
@@ -52,6 +62,158 @@ async function updateProfileInfo() {
     }
 }
 
+// Signale une adresse non vérifiée et propose de renvoyer le lien
+function showEmailNotVerified(token) {
+    const emailInfo = document.getElementById('profile-email');
+    if (emailInfo.nextElementSibling?.classList.contains('email-unverified')) {
+        return;
+    }
+    const notice = document.createElement('p');
+    notice.className = 'email-unverified';
+    notice.textContent = 'Adresse non vérifiée. ';
+
+    const resend = document.createElement('a');
+    resend.href = '#';
+    resend.textContent = 'Renvoyer le lien';
+    resend.addEventListener('click', async (e) => {
+        e.preventDefault();
+        const response = await fetch('/api/verify-email/resend', {
+            method: 'POST',
+            headers: {
+                'Authorization': `Bearer ${token}`
+            }
+        });
+        notice.textContent = response.ok ? 'Lien envoyé, consultez votre boîte mail.' : "Impossible d'envoyer le lien pour le moment.";
+    });
+
+    notice.appendChild(resend);
+    emailInfo.after(notice);
+}
+
+// Affiche l'erreur renvoyée par une requête de gestion du compte
+async function showAccountError(response) {
+    const data = await response.json().catch(() => ({}));
+    const errorDiv = document.getElementById('account-error');
+    errorDiv.textContent = data.fields ? Object.values(data.fields).join('. ') : (data.error || 'Erreur lors de la mise à jour du compte');
+    errorDiv.classList.add('visible');
+}
+
+// Envoie une requête authentifiée de gestion du compte
+async function accountRequest(path, method, body) {
+    const token = await getValidToken();
+    if (!token) {
+        window.location.href = '/html/login.html';
+        return null;
+    }
+
+    const headers = { 'Authorization': `Bearer ${token}` };
+    if (!(body instanceof FormData)) {
+        headers['Content-Type'] = 'application/json';
+        body = JSON.stringify(body);
+    }
+    const response = await fetch(path, { method, headers, body });
+    if (!response.ok) {
+        await showAccountError(response);
+        return null;
+    }
+    return response;
+}
+
+function initAccountForms() {
+    document.getElementById('avatar-form').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const formData = new FormData();
+        formData.append('avatar', document.getElementById('avatar').files[0]);
+        if (await accountRequest('/api/account/avatar', 'POST', formData)) {
+            updateProfileInfo();
+        }
+    });
+
+    document.getElementById('username-form').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const username = document.getElementById('new-username').value;
+        const response = await accountRequest('/api/account/username', 'POST', { username });
+        if (response) {
+            // Le token d'accès porte le nouveau pseudo
+            const data = await response.json();
+            localStorage.setItem('token', data.token);
+            updateProfileInfo();
+        }
+    });
+
+    document.getElementById('email-form').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const email = document.getElementById('new-email').value;
+        const password = document.getElementById('email-password').value;
+        if (await accountRequest('/api/account/email', 'POST', { email, password })) {
+            window.location.reload();
+        }
+    });
+
+    document.getElementById('delete-account-form').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        if (!confirm('Supprimer définitivement votre compte ?')) {
+            return;
+        }
+        const confirmUsername = document.getElementById('delete-confirm').value;
+        const password = document.getElementById('delete-password').value;
+        if (await accountRequest('/api/account', 'DELETE', { password, confirm: confirmUsername })) {
+            localStorage.removeItem('token');
+            localStorage.removeItem('refresh_token');
+            window.location.href = '/html/home.html';
+        }
+    });
+}
+
+// Affiche l'état de la double authentification
+function showMFAStatus(enabled) {
+    document.getElementById('mfa-status').textContent = enabled ? 'Activée' : 'Désactivée';
+    document.getElementById('mfa-enroll-form').style.display = enabled ? 'none' : '';
+    document.getElementById('mfa-disable-form').style.display = enabled ? '' : 'none';
+    document.getElementById('mfa-verify-form').style.display = 'none';
+}
+
+function initMFAForms() {
+    document.getElementById('mfa-enroll-form').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const password = document.getElementById('mfa-enroll-password').value;
+        const response = await accountRequest('/api/mfa/enroll', 'POST', { password });
+        if (!response) {
+            return;
+        }
+        const data = await response.json();
+        document.getElementById('mfa-uri').href = data.otpauth_uri;
+        document.getElementById('mfa-secret').textContent = data.secret;
+        document.getElementById('mfa-enroll-password').value = '';
+        document.getElementById('mfa-enroll-form').style.display = 'none';
+        document.getElementById('mfa-verify-form').style.display = '';
+    });
+
+    document.getElementById('mfa-verify-form').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const code = document.getElementById('mfa-verify-code').value.trim();
+        const response = await accountRequest('/api/mfa/verify', 'POST', { code });
+        if (!response) {
+            return;
+        }
+        const data = await response.json();
+        showMFAStatus(true);
+        document.getElementById('mfa-recovery-codes').textContent = data.recovery_codes.join('\n');
+        document.getElementById('mfa-recovery').style.display = '';
+    });
+
+    document.getElementById('mfa-disable-form').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const password = document.getElementById('mfa-disable-password').value;
+        const value = document.getElementById('mfa-disable-code').value.trim();
+        const body = /^[0-9 ]+$/.test(value) ? { password, code: value } : { password, recovery_code: value };
+        if (await accountRequest('/api/mfa/disable', 'POST', body)) {
+            showMFAStatus(false);
+            document.getElementById('mfa-recovery').style.display = 'none';
+        }
+    });
+}
+
 // Initialisation
 document.addEventListener('DOMContentLoaded', () => {
     // Vérifier l'authentification
@@ -62,6 +224,10 @@ document.addEventListener('DOMContentLoaded', () => {
     // Mettre à jour les informations du profil
     updateProfileInfo();
     
+    // Formulaires de gestion du compte
+    initAccountForms();
+    initMFAForms();
+
     // Ajouter l'écouteur d'événement pour le bouton de déconnexion
     const logoutButton = document.getElementById('logout-button');
     if (logoutButton) {
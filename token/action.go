@@ -0,0 +1,71 @@
+package token
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Usages des tokens d'action
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposePasswordReset = "password_reset"
+	// Connexion en attente du code de double authentification
+	PurposeMFAPending = "mfa_pending"
+)
+
+// ActionClaims identifie une action : Subject porte l'id de l'utilisateur et
+// ID l'identifiant du token (enregistré en base pour les tokens à usage unique)
+type ActionClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// UserID renvoie l'identifiant de l'utilisateur concerné
+func (c *ActionClaims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// L'audience dépend de l'usage : un token d'action n'est jamais accepté comme
+// token d'accès, ni pour un autre usage
+func (i *Issuer) actionAudience(purpose string) string {
+	return i.audience + ":" + purpose
+}
+
+// IssueAction signe un token d'action avec la clé active
+func (i *Issuer) IssueAction(purpose string, userID int, tokenID string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &ActionClaims{
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.issuer,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwt.ClaimStrings{i.actionAudience(purpose)},
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	t := jwt.NewWithClaims(signingMethod, claims)
+	t.Header["kid"] = i.activeKeyID
+	return t.SignedString(i.keys[i.activeKeyID])
+}
+
+// ParseAction vérifie un token d'action destiné à l'usage indiqué
+func (i *Issuer) ParseAction(purpose, tokenString string) (*ActionClaims, error) {
+	claims := &ActionClaims{}
+	t, err := jwt.ParseWithClaims(tokenString, claims, i.keyFunc,
+		jwt.WithValidMethods([]string{signingMethod.Alg()}),
+		jwt.WithIssuer(i.issuer),
+		jwt.WithAudience(i.actionAudience(purpose)),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	)
+	if err != nil || !t.Valid || claims.Purpose != purpose || claims.ID == "" {
+		return nil, ErrInvalid
+	}
+	return claims, nil
+}
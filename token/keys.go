@@ -0,0 +1,88 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Clé historique, utilisée uniquement en développement si aucune clé n'est configurée
+const defaultJWTKey = "votre_clé_secrète_jwt"
+
+// Identifiant de la clé définie par JWT_SECRET, également utilisé pour
+// vérifier les tokens émis sans en-tête kid
+const defaultKeyID = "default"
+
+// FromEnv crée l'émetteur des tokens d'après l'environnement :
+//
+//	JWT_SECRET      clé unique (kid "default")
+//	JWT_KEYS        plusieurs clés, "kid1:secret1,kid2:secret2"
+//	JWT_ACTIVE_KID  clé utilisée pour signer, par défaut la première de JWT_KEYS
+//	JWT_ISSUER      émetteur (iss) des tokens, "motzarella" par défaut
+//	JWT_AUDIENCE    audience (aud) des tokens, "motzarella-api" par défaut
+//
+// Pour faire tourner les clés, on ajoute la nouvelle clé dans JWT_KEYS et on
+// la désigne dans JWT_ACTIVE_KID : les tokens signés avec l'ancienne restent
+// valides jusqu'à ce qu'elle soit retirée. En production (APP_ENV=production),
+// le démarrage est refusé si seule la clé par défaut est disponible.
+func FromEnv() (*Issuer, error) {
+	issuer := NewIssuer(map[string][]byte{defaultKeyID: []byte(defaultJWTKey)}, defaultKeyID)
+	if value := os.Getenv("JWT_ISSUER"); value != "" {
+		issuer.issuer = value
+	}
+	if value := os.Getenv("JWT_AUDIENCE"); value != "" {
+		issuer.audience = value
+	}
+
+	keys := make(map[string][]byte)
+	activeKeyID := ""
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		keys[defaultKeyID] = []byte(secret)
+		activeKeyID = defaultKeyID
+	}
+
+	if list := os.Getenv("JWT_KEYS"); list != "" {
+		for i, entry := range strings.Split(list, ",") {
+			kid, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok || kid == "" || secret == "" {
+				return nil, fmt.Errorf("JWT_KEYS: entrée %d invalide, format attendu kid:secret", i+1)
+			}
+			if _, exists := keys[kid]; exists {
+				return nil, fmt.Errorf("JWT_KEYS: identifiant de clé %q en double", kid)
+			}
+			keys[kid] = []byte(secret)
+			if i == 0 {
+				activeKeyID = kid
+			}
+		}
+	}
+
+	if kid := os.Getenv("JWT_ACTIVE_KID"); kid != "" {
+		if _, ok := keys[kid]; !ok {
+			return nil, fmt.Errorf("JWT_ACTIVE_KID: clé %q inconnue", kid)
+		}
+		activeKeyID = kid
+	}
+
+	production := os.Getenv("APP_ENV") == "production"
+	for kid, secret := range keys {
+		if string(secret) == defaultJWTKey && production {
+			return nil, fmt.Errorf("la clé %q utilise la valeur par défaut, interdite en production", kid)
+		}
+	}
+
+	if len(keys) == 0 {
+		if production {
+			return nil, errors.New("aucune clé JWT configurée (JWT_SECRET ou JWT_KEYS), obligatoire en production")
+		}
+		log.Println("Attention : aucune clé JWT configurée, utilisation de la clé par défaut (développement uniquement)")
+		return issuer, nil
+	}
+
+	issuer.keys = keys
+	issuer.activeKeyID = activeKeyID
+	return issuer, nil
+}
@@ -0,0 +1,116 @@
+// Package token émet et vérifie les tokens d'accès JWT. Il fixe l'algorithme
+// de signature (HS256) et impose les claims iss, aud, exp et nbf : un token
+// signé avec un autre algorithme ou destiné à un autre service est refusé.
+package token
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims iss et aud par défaut
+const (
+	defaultIssuer   = "motzarella"
+	defaultAudience = "motzarella-api"
+)
+
+// Seul algorithme accepté, pour empêcher toute confusion d'algorithme
+var signingMethod = jwt.SigningMethodHS256
+
+// Tolérance sur les horloges pour exp et nbf
+const leeway = 30 * time.Second
+
+var ErrInvalid = errors.New("invalid token")
+
+type Claims struct {
+	Username  string `json:"username"`
+	IsAdmin   bool   `json:"is_admin"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// Issuer émet et vérifie les tokens avec ses clés de signature et ses claims
+// iss et aud. Il est créé par FromEnv au démarrage, ou par NewIssuer.
+type Issuer struct {
+	issuer   string
+	audience string
+	// Secrets de signature indexés par identifiant de clé (kid)
+	keys map[string][]byte
+	// Clé utilisée pour signer les nouveaux tokens
+	activeKeyID string
+}
+
+// NewIssuer crée un émetteur qui signe avec la clé activeKeyID de keys, avec
+// les claims iss et aud par défaut
+func NewIssuer(keys map[string][]byte, activeKeyID string) *Issuer {
+	return &Issuer{
+		issuer:      defaultIssuer,
+		audience:    defaultAudience,
+		keys:        keys,
+		activeKeyID: activeKeyID,
+	}
+}
+
+// Issue signe un token d'accès avec la clé active
+func (i *Issuer) Issue(username string, isAdmin bool, sessionID string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		Username:  username,
+		IsAdmin:   isAdmin,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.issuer,
+			Subject:   username,
+			Audience:  jwt.ClaimStrings{i.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	t := jwt.NewWithClaims(signingMethod, claims)
+	t.Header["kid"] = i.activeKeyID
+	return t.SignedString(i.keys[i.activeKeyID])
+}
+
+// Parse vérifie la signature, l'algorithme et les claims d'un token
+func (i *Issuer) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	t, err := jwt.ParseWithClaims(tokenString, claims, i.keyFunc,
+		jwt.WithValidMethods([]string{signingMethod.Alg()}),
+		jwt.WithIssuer(i.issuer),
+		jwt.WithAudience(i.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	)
+	if err != nil || !t.Valid {
+		return nil, ErrInvalid
+	}
+
+	// La bibliothèque ne vérifie nbf que s'il est présent : on l'exige
+	if claims.NotBefore == nil {
+		return nil, ErrInvalid
+	}
+	return claims, nil
+}
+
+func (i *Issuer) keyFunc(t *jwt.Token) (interface{}, error) {
+	// Double vérification de l'algorithme, en plus de WithValidMethods
+	if t.Method != signingMethod {
+		return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+	}
+
+	// Les tokens émis avant la rotation des clés n'ont pas de kid
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		kid = defaultKeyID
+	}
+	key, ok := i.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
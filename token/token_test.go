@@ -0,0 +1,230 @@
+package token_test
+
+import (
+	"testing"
+	"time"
+
+	"motzarella/token"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testKey = []byte("cle-de-test")
+
+// validClaims renvoie les claims d'un token d'accès valide, à modifier par cas
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"username": "alice",
+		"sid":      "session",
+		"iss":      "motzarella",
+		"sub":      "alice",
+		"aud":      []string{"motzarella-api"},
+		"iat":      now.Unix(),
+		"nbf":      now.Unix(),
+		"exp":      now.Add(time.Minute).Unix(),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key interface{}) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	signed, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestParse(t *testing.T) {
+	issuer := token.NewIssuer(map[string][]byte{"k1": testKey}, "k1")
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		key    interface{}
+		modify func(jwt.MapClaims)
+		valid  bool
+	}{
+		{name: "valide", valid: true},
+		{name: "alg none", method: jwt.SigningMethodNone, key: jwt.UnsafeAllowNoneSignatureType},
+		{name: "HS512", method: jwt.SigningMethodHS512},
+		{name: "mauvaise clé", key: []byte("autre-cle")},
+		{name: "kid inconnu", kid: "k2"},
+		{name: "iss différent", modify: func(c jwt.MapClaims) { c["iss"] = "autre-service" }},
+		{name: "aud différente", modify: func(c jwt.MapClaims) { c["aud"] = []string{"autre-api"} }},
+		{name: "aud d'un token d'action", modify: func(c jwt.MapClaims) { c["aud"] = []string{"motzarella-api:" + token.PurposePasswordReset} }},
+		{name: "sans nbf", modify: func(c jwt.MapClaims) { delete(c, "nbf") }},
+		{name: "nbf futur", modify: func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }},
+		{name: "sans exp", modify: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "expiré", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "expiré dans la tolérance", modify: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-10 * time.Second).Unix() }, valid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method, kid, key := test.method, test.kid, test.key
+			if method == nil {
+				method = jwt.SigningMethodHS256
+			}
+			if kid == "" {
+				kid = "k1"
+			}
+			if key == nil {
+				key = testKey
+			}
+			claims := validClaims()
+			if test.modify != nil {
+				test.modify(claims)
+			}
+
+			parsed, err := issuer.Parse(sign(t, method, kid, claims, key))
+			if test.valid && (err != nil || parsed.Username != "alice") {
+				t.Errorf("token refusé: %v", err)
+			}
+			if !test.valid && err != token.ErrInvalid {
+				t.Errorf("token accepté, ErrInvalid attendue (erreur %v)", err)
+			}
+		})
+	}
+}
+
+func TestIssuedTokensAreNotInterchangeable(t *testing.T) {
+	issuer := token.NewIssuer(map[string][]byte{"k1": testKey}, "k1")
+
+	access, err := issuer.Issue("alice", false, "session", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	action, err := issuer.IssueAction(token.PurposeVerifyEmail, 42, "id", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims, err := issuer.Parse(access); err != nil || claims.Username != "alice" || claims.SessionID != "session" {
+		t.Errorf("token d'accès: %+v, %v", claims, err)
+	}
+	if claims, err := issuer.ParseAction(token.PurposeVerifyEmail, action); err != nil || claims.Subject != "42" {
+		t.Errorf("token d'action: %+v, %v", claims, err)
+	}
+
+	if _, err := issuer.Parse(action); err != token.ErrInvalid {
+		t.Errorf("token d'action accepté comme token d'accès (erreur %v)", err)
+	}
+	if _, err := issuer.ParseAction(token.PurposeVerifyEmail, access); err != token.ErrInvalid {
+		t.Errorf("token d'accès accepté comme token d'action (erreur %v)", err)
+	}
+	if _, err := issuer.ParseAction(token.PurposePasswordReset, action); err != token.ErrInvalid {
+		t.Errorf("token d'action accepté pour un autre usage (erreur %v)", err)
+	}
+
+	// Un autre service partageant la clé refuse nos tokens
+	setTokenEnv(t, map[string]string{"JWT_KEYS": "k1:" + string(testKey), "JWT_AUDIENCE": "autre-api"})
+	other, err := token.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Parse(access); err != token.ErrInvalid {
+		t.Errorf("token accepté par une autre audience (erreur %v)", err)
+	}
+}
+
+// setTokenEnv remplace la configuration des tokens par env
+func setTokenEnv(t *testing.T, env map[string]string) {
+	for _, name := range []string{"JWT_SECRET", "JWT_KEYS", "JWT_ACTIVE_KID", "JWT_ISSUER", "JWT_AUDIENCE", "APP_ENV"} {
+		t.Setenv(name, env[name])
+	}
+}
+
+// headerKeyID renvoie le kid de l'en-tête d'un token
+func headerKeyID(t *testing.T, signed string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestKeyRotation(t *testing.T) {
+	setTokenEnv(t, map[string]string{"JWT_KEYS": "2024:ancienne-cle"})
+	before, err := token.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := before.Issue("alice", false, "session", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := headerKeyID(t, old); kid != "2024" {
+		t.Errorf("kid avant la rotation: %q, 2024 attendu", kid)
+	}
+
+	// Nouvelle clé active, l'ancienne reste acceptée
+	setTokenEnv(t, map[string]string{"JWT_KEYS": "2024:ancienne-cle,2025:nouvelle-cle", "JWT_ACTIVE_KID": "2025"})
+	after, err := token.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := after.Parse(old); err != nil {
+		t.Errorf("token signé avec l'ancienne clé refusé: %v", err)
+	}
+	current, err := after.Issue("alice", false, "session", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := headerKeyID(t, current); kid != "2025" {
+		t.Errorf("kid après la rotation: %q, 2025 attendu", kid)
+	}
+	if _, err := after.Parse(current); err != nil {
+		t.Errorf("token signé avec la nouvelle clé refusé: %v", err)
+	}
+
+	// Ancienne clé retirée : ses tokens sont refusés
+	setTokenEnv(t, map[string]string{"JWT_KEYS": "2025:nouvelle-cle"})
+	retired, err := token.FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := retired.Parse(old); err != token.ErrInvalid {
+		t.Errorf("token d'une clé retirée accepté (erreur %v)", err)
+	}
+	if _, err := retired.Parse(current); err != nil {
+		t.Errorf("token de la clé active refusé: %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		valid bool
+	}{
+		{"développement sans clé", map[string]string{}, true},
+		{"production sans clé", map[string]string{"APP_ENV": "production"}, false},
+		{"production avec la clé par défaut", map[string]string{"APP_ENV": "production", "JWT_SECRET": "votre_clé_secrète_jwt"}, false},
+		{"production avec la clé par défaut dans JWT_KEYS", map[string]string{"APP_ENV": "production", "JWT_KEYS": "a:votre_clé_secrète_jwt"}, false},
+		{"production avec une clé", map[string]string{"APP_ENV": "production", "JWT_SECRET": "une-vraie-cle"}, true},
+		{"JWT_KEYS mal formé", map[string]string{"JWT_KEYS": "sans-secret"}, false},
+		{"kid en double", map[string]string{"JWT_KEYS": "a:un,a:deux"}, false},
+		{"JWT_ACTIVE_KID inconnu", map[string]string{"JWT_KEYS": "a:un", "JWT_ACTIVE_KID": "b"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setTokenEnv(t, test.env)
+			issuer, err := token.FromEnv()
+			if test.valid && (err != nil || issuer == nil) {
+				t.Errorf("configuration refusée: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("configuration acceptée, erreur attendue")
+			}
+		})
+	}
+}
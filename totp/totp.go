@@ -0,0 +1,106 @@
+// Package totp implémente les mots de passe à usage unique basés sur le
+// temps (RFC 6238) utilisés pour la double authentification : HMAC-SHA1,
+// codes à 6 chiffres renouvelés toutes les 30 secondes.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30
+	// Nombre de périodes acceptées avant et après l'heure courante, pour
+	// tolérer le décalage des horloges
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// modulus vaut 10^Digits : le code garde les Digits derniers chiffres
+var modulus = func() uint32 {
+	m := uint32(1)
+	for i := 0; i < Digits; i++ {
+		m *= 10
+	}
+	return m
+}()
+
+// GenerateSecret génère un secret de 160 bits encodé en base32
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// URI renvoie l'URI otpauth:// à importer dans une application
+// d'authentification (directement ou via un QR code)
+func URI(secret, issuer, account string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(Digits))
+	params.Set("period", fmt.Sprint(Period))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Step renvoie le numéro de période correspondant à un instant
+func Step(t time.Time) int64 {
+	return t.Unix() / Period
+}
+
+// Code calcule le code d'une période (RFC 4226, section 5.3)
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%modulus), nil
+}
+
+// Validate vérifie un code autour de l'instant now et renvoie la période
+// correspondante. Les périodes inférieures ou égales à lastStep sont
+// refusées, pour qu'un code ne serve qu'une fois.
+func Validate(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	current := Step(now)
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,91 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"motzarella/totp"
+)
+
+// Secret des vecteurs de test de la RFC 6238 (annexe B, SHA-1)
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238(t *testing.T) {
+	// Codes à 8 chiffres de la RFC, dont on garde les 6 derniers
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, test := range tests {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := test.code[len(test.code)-totp.Digits:]; code != expected {
+			t.Errorf("T=%d: code %s, %s attendu", test.unix, code, expected)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := totp.Step(now)
+	codeAt := func(step int64) string {
+		code, err := totp.Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		step     int64
+		valid    bool
+	}{
+		{"période courante", codeAt(current), 0, current, true},
+		{"période précédente", codeAt(current - 1), 0, current - 1, true},
+		{"période suivante", codeAt(current + 1), 0, current + 1, true},
+		{"deux périodes avant", codeAt(current - 2), 0, 0, false},
+		{"deux périodes après", codeAt(current + 2), 0, 0, false},
+		{"avec des espaces", codeAt(current)[:3] + " " + codeAt(current)[3:], 0, current, true},
+		{"longueur invalide", codeAt(current)[:totp.Digits-1], 0, 0, false},
+		{"code déjà utilisé", codeAt(current), current, 0, false},
+		{"période antérieure au dernier code", codeAt(current - 1), current - 1, 0, false},
+		{"période postérieure au dernier code", codeAt(current + 1), current, current + 1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := totp.Validate(rfcSecret, test.code, now, test.lastStep)
+			if ok != test.valid || (ok && step != test.step) {
+				t.Errorf("totp.Validate: période %d, %v ; %d, %v attendus", step, ok, test.step, test.valid)
+			}
+		})
+	}
+}
+
+func TestValidateRefusesReplay(t *testing.T) {
+	now := time.Now()
+	code, err := totp.Code(rfcSecret, totp.Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := totp.Validate(rfcSecret, code, now, 0)
+	if !ok {
+		t.Fatal("premier usage refusé")
+	}
+	if _, ok := totp.Validate(rfcSecret, code, now, step); ok {
+		t.Error("code réutilisé accepté")
+	}
+}
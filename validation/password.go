@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"bufio"
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MinPasswordLength = 8
+	// bcrypt ignore silencieusement les octets au-delà du 72e
+	MaxPasswordBytes = 72
+	// Entropie minimale estimée, en bits
+	minPasswordEntropy = 40
+)
+
+// Mots de passe les plus courants, refusés quelle que soit leur longueur
+//
+//go:embed common-passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = loadCommonPasswords(commonPasswordsFile)
+
+func loadCommonPasswords(list string) map[string]bool {
+	passwords := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			passwords[strings.ToLower(line)] = true
+		}
+	}
+	return passwords
+}
+
+// Password vérifie un mot de passe : longueur, limite de bcrypt, liste des
+// mots de passe courants, ressemblance avec l'identité de l'utilisateur et
+// entropie estimée. username et email peuvent être vides.
+func Password(password, username, email string) string {
+	if password == "" {
+		return "Le mot de passe est obligatoire"
+	}
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return "Le mot de passe doit contenir au moins 8 caractères"
+	}
+	if len(password) > MaxPasswordBytes {
+		return "Le mot de passe ne doit pas dépasser 72 octets"
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return "Ce mot de passe est trop courant"
+	}
+
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, identity := range []string{strings.ToLower(username), localPart} {
+		if len(identity) >= MinUsernameLength && strings.Contains(lower, identity) {
+			return "Le mot de passe ne doit pas contenir votre nom d'utilisateur ou votre email"
+		}
+	}
+
+	if passwordEntropy(password) < minPasswordEntropy {
+		return "Le mot de passe est trop simple : allongez-le ou variez les caractères"
+	}
+	return ""
+}
+
+// passwordEntropy estime l'entropie d'un mot de passe à partir des classes de
+// caractères utilisées. Les caractères répétés à la suite ne comptent qu'une
+// fois, pour pénaliser les mots de passe comme "aaaaaaaa".
+func passwordEntropy(password string) float64 {
+	var lower, upper, digit, other bool
+	length := 0
+	var previous rune = -1
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+		if r != previous {
+			length++
+		}
+		previous = r
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if other {
+		pool += 33
+	}
+	return float64(length) * math.Log2(float64(pool))
+}
@@ -0,0 +1,91 @@
+// Package validation vérifie les données saisies par les utilisateurs
+// (inscription, modification du profil) et produit des erreurs par champ.
+package validation
+
+import (
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 20
+	// Longueur maximale d'une adresse email (RFC 5321)
+	MaxEmailLength = 254
+)
+
+// Errors associe à chaque champ invalide un message destiné à l'utilisateur
+type Errors map[string]string
+
+// Add enregistre l'erreur d'un champ si message n'est pas vide
+func (e Errors) Add(field, message string) {
+	if message != "" {
+		e[field] = message
+	}
+}
+
+// Empty indique si aucune erreur n'a été relevée
+func (e Errors) Empty() bool {
+	return len(e) == 0
+}
+
+// Noms réservés, quelle que soit la casse : ils pourraient faire passer un
+// joueur pour l'équipe du site
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrateur": true, "administrator": true,
+	"moderateur": true, "moderator": true, "root": true,
+	"support": true, "system": true, "systeme": true, "motzarella": true,
+}
+
+// Username vérifie un nom d'utilisateur : 3 à 20 caractères parmi les
+// lettres non accentuées, les chiffres, "_", "-" et ".", hors noms réservés
+func Username(username string) string {
+	length := utf8.RuneCountInString(username)
+	if length == 0 {
+		return "Le nom d'utilisateur est obligatoire"
+	}
+	if length < MinUsernameLength || length > MaxUsernameLength {
+		return "Le nom d'utilisateur doit contenir entre 3 et 20 caractères"
+	}
+	for _, r := range username {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+		default:
+			return "Le nom d'utilisateur ne peut contenir que des lettres, des chiffres, « _ », « - » et « . »"
+		}
+	}
+	if reservedUsernames[strings.ToLower(username)] {
+		return "Ce nom d'utilisateur est réservé"
+	}
+	return ""
+}
+
+// Email vérifie une adresse email selon la RFC 5322, sans nom d'affichage
+func Email(email string) string {
+	if email == "" {
+		return "L'email est obligatoire"
+	}
+	if len(email) > MaxEmailLength {
+		return "L'email est trop long"
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return "L'email n'est pas valide"
+	}
+	// mail.ParseAddress accepte les domaines sans point ("user@localhost")
+	_, domain, _ := strings.Cut(address.Address, "@")
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "L'email n'est pas valide"
+	}
+	return ""
+}
+
+// Registration vérifie les champs d'une inscription
+func Registration(username, email, password string) Errors {
+	errs := Errors{}
+	errs.Add("username", Username(username))
+	errs.Add("email", Email(email))
+	errs.Add("password", Password(password, username, email))
+	return errs
+}
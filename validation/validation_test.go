@@ -0,0 +1,119 @@
+package validation_test
+
+import (
+	"strings"
+	"testing"
+
+	"motzarella/validation"
+)
+
+func TestUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		valid    bool
+	}{
+		{"", false},
+		{"ab", false},
+		{"abc", true},
+		{strings.Repeat("a", validation.MaxUsernameLength), true},
+		{strings.Repeat("a", validation.MaxUsernameLength+1), false},
+		{"jean_luc-2.0", true},
+		{"jean luc", false},
+		{"jean@luc", false},
+		// Les lettres accentuées et les autres alphabets sont refusés, même
+		// quand leur nombre de caractères est valide
+		{"Zoé", false},
+		{"éèà", false},
+		{"Дмитрий", false},
+		{"名前です", false},
+		{"ab́", false},
+		// Noms réservés, quelle que soit la casse
+		{"admin", false},
+		{"Admin", false},
+		{"MODERATEUR", false},
+		{"support", false},
+		{"admin2", true},
+	}
+	for _, test := range tests {
+		if message := validation.Username(test.username); (message == "") != test.valid {
+			t.Errorf("Username(%q) = %q, valide attendu: %v", test.username, message, test.valid)
+		}
+	}
+}
+
+func TestEmail(t *testing.T) {
+	local := strings.Repeat("a", 64)
+	domain := strings.Repeat("b", 63) + "." + strings.Repeat("c", 63) + "." + strings.Repeat("d", 50) + ".fr"
+	long := local + "@" + domain
+
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"", false},
+		{"alice@example.com", true},
+		{"alice.dupont+jeu@mail.example.fr", true},
+		{"alice", false},
+		{"alice@", false},
+		{"@example.com", false},
+		{"alice@localhost", false},
+		{"alice@.example.com", false},
+		{"alice@example.com.", false},
+		{"Alice <alice@example.com>", false},
+		{" alice@example.com", false},
+		{long, len(long) <= validation.MaxEmailLength},
+		{strings.Repeat("a", validation.MaxEmailLength) + "@example.com", false},
+	}
+	for _, test := range tests {
+		if message := validation.Email(test.email); (message == "") != test.valid {
+			t.Errorf("Email(%q) = %q, valide attendu: %v", test.email, message, test.valid)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	tests := []struct {
+		name, password string
+		valid          bool
+	}{
+		{"vide", "", false},
+		{"7 caractères", "Ab3$xYz", false},
+		{"8 caractères variés", "Ab3$xYz!", true},
+		{"8 caractères accentués", "éÀ3$xYz!", true},
+		{"72 octets", strings.Repeat("Ab3$", 18), true},
+		{"73 octets", strings.Repeat("Ab3$", 18) + "x", false},
+		{"trop courant", "password123", false},
+		{"trop courant en majuscules", "PASSWORD123", false},
+		{"contient le nom d'utilisateur", "xx-Alice-Z9!", false},
+		{"contient l'email", "xx-alice.d-Z9!", false},
+		{"caractères répétés", "aaaaaaaaaaaa", false},
+		{"trop simple", "abcdefgh", false},
+		{"longue phrase en minuscules", "cheval correct pile agrafe", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message := validation.Password(test.password, "alice", "alice.d@example.com")
+			if (message == "") != test.valid {
+				t.Errorf("Password(%q) = %q, valide attendu: %v", test.password, message, test.valid)
+			}
+		})
+	}
+}
+
+func TestRegistrationReportsEveryField(t *testing.T) {
+	errs := validation.Registration("a", "pas-un-email", "court")
+	for _, field := range []string{"username", "email", "password"} {
+		if errs[field] == "" {
+			t.Errorf("champ %s: erreur attendue dans %v", field, errs)
+		}
+	}
+
+	errs = validation.Registration("alice", "pas-un-email", "Ab3$xYz!-long")
+	if len(errs) != 1 || errs["email"] == "" {
+		t.Errorf("seul l'email est invalide: %v", errs)
+	}
+
+	if errs := validation.Registration("alice", "alice@example.com", "Ab3$xYz!-long"); !errs.Empty() {
+		t.Errorf("inscription valide: %v", errs)
+	}
+}